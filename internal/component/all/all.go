@@ -35,12 +35,14 @@ import (
 	_ "github.com/grafana/agent/internal/component/faro/receiver"                            // Import faro.receiver
 	_ "github.com/grafana/agent/internal/component/local/file"                               // Import local.file
 	_ "github.com/grafana/agent/internal/component/local/file_match"                         // Import local.file_match
+	_ "github.com/grafana/agent/internal/component/local/secrets"                            // Import local.secrets
 	_ "github.com/grafana/agent/internal/component/loki/echo"                                // Import loki.echo
 	_ "github.com/grafana/agent/internal/component/loki/process"                             // Import loki.process
 	_ "github.com/grafana/agent/internal/component/loki/relabel"                             // Import loki.relabel
 	_ "github.com/grafana/agent/internal/component/loki/rules/kubernetes"                    // Import loki.rules.kubernetes
 	_ "github.com/grafana/agent/internal/component/loki/source/api"                          // Import loki.source.api
 	_ "github.com/grafana/agent/internal/component/loki/source/aws_firehose"                 // Import loki.source.awsfirehose
+	_ "github.com/grafana/agent/internal/component/loki/source/aws_kinesis"                  // Import loki.source.aws_kinesis
 	_ "github.com/grafana/agent/internal/component/loki/source/azure_event_hubs"             // Import loki.source.azure_event_hubs
 	_ "github.com/grafana/agent/internal/component/loki/source/cloudflare"                   // Import loki.source.cloudflare
 	_ "github.com/grafana/agent/internal/component/loki/source/docker"                       // Import loki.source.docker
@@ -48,11 +50,14 @@ import (
 	_ "github.com/grafana/agent/internal/component/loki/source/gcplog"                       // Import loki.source.gcplog
 	_ "github.com/grafana/agent/internal/component/loki/source/gelf"                         // Import loki.source.gelf
 	_ "github.com/grafana/agent/internal/component/loki/source/heroku"                       // Import loki.source.heroku
+	_ "github.com/grafana/agent/internal/component/loki/source/http_pull"                    // Import loki.source.http_pull
 	_ "github.com/grafana/agent/internal/component/loki/source/journal"                      // Import loki.source.journal
+	_ "github.com/grafana/agent/internal/component/loki/source/journal_gateway"              // Import loki.source.journal_gateway
 	_ "github.com/grafana/agent/internal/component/loki/source/kafka"                        // Import loki.source.kafka
 	_ "github.com/grafana/agent/internal/component/loki/source/kubernetes"                   // Import loki.source.kubernetes
 	_ "github.com/grafana/agent/internal/component/loki/source/kubernetes_events"            // Import loki.source.kubernetes_events
 	_ "github.com/grafana/agent/internal/component/loki/source/podlogs"                      // Import loki.source.podlogs
+	_ "github.com/grafana/agent/internal/component/loki/source/stdin"                        // Import loki.source.stdin
 	_ "github.com/grafana/agent/internal/component/loki/source/syslog"                       // Import loki.source.syslog
 	_ "github.com/grafana/agent/internal/component/loki/source/windowsevent"                 // Import loki.source.windowsevent
 	_ "github.com/grafana/agent/internal/component/loki/write"                               // Import loki.write
@@ -134,6 +139,7 @@ import (
 	_ "github.com/grafana/agent/internal/component/pyroscope/java"                           // Import pyroscope.java
 	_ "github.com/grafana/agent/internal/component/pyroscope/scrape"                         // Import pyroscope.scrape
 	_ "github.com/grafana/agent/internal/component/pyroscope/write"                          // Import pyroscope.write
+	_ "github.com/grafana/agent/internal/component/remote/awssecrets"                        // Import remote.awssecrets
 	_ "github.com/grafana/agent/internal/component/remote/http"                              // Import remote.http
 	_ "github.com/grafana/agent/internal/component/remote/kubernetes/configmap"              // Import remote.kubernetes.configmap
 	_ "github.com/grafana/agent/internal/component/remote/kubernetes/secret"                 // Import remote.kubernetes.secret
@@ -5,10 +5,13 @@ package positions
 // same place in case of a restart.
 
 import (
+	"bytes"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -16,6 +19,7 @@ import (
 
 	"github.com/go-kit/log"
 	"github.com/grafana/agent/internal/flow/logging/level"
+	"github.com/prometheus/client_golang/prometheus"
 	yaml "gopkg.in/yaml.v2"
 )
 
@@ -25,12 +29,39 @@ const (
 	journalKeyPrefix = "journal-"
 )
 
+// Format is the on-disk representation of a positions file.
+type Format string
+
+// Supported positions file formats.
+const (
+	FormatYAML Format = "yaml"
+	FormatJSON Format = "json"
+)
+
 // Config describes where to get position information from.
 type Config struct {
 	SyncPeriod        time.Duration `mapstructure:"sync_period" yaml:"sync_period"`
 	PositionsFile     string        `mapstructure:"filename" yaml:"filename"`
 	IgnoreInvalidYaml bool          `mapstructure:"ignore_invalid_yaml" yaml:"ignore_invalid_yaml"`
 	ReadOnly          bool          `mapstructure:"-" yaml:"-"`
+
+	// InMemory keeps positions in memory only. The positions file is neither
+	// read nor written, so every start reads from the beginning.
+	InMemory bool `mapstructure:"in_memory" yaml:"in_memory"`
+
+	// Format is the format used to write the positions file. Defaults to
+	// FormatYAML if empty. Existing files are read in whichever format they
+	// were written in, and rewritten in Format on the next sync.
+	Format Format `mapstructure:"format" yaml:"format"`
+
+	// CleanupGracePeriod is how long the file of an entry must be missing
+	// before the entry is removed. Files which reappear within the grace
+	// period, such as files being rotated, keep their positions. Entries are
+	// removed as soon as their file is found missing if zero.
+	CleanupGracePeriod time.Duration `mapstructure:"cleanup_grace_period" yaml:"cleanup_grace_period"`
+
+	// Registerer to register metrics with. May be nil.
+	Registerer prometheus.Registerer `mapstructure:"-" yaml:"-"`
 }
 
 // RegisterFlagsWithPrefix registers flags where every name is prefixed by
@@ -39,6 +70,15 @@ func (cfg *Config) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
 	f.DurationVar(&cfg.SyncPeriod, prefix+"positions.sync-period", 10*time.Second, "Period with this to sync the position file.")
 	f.StringVar(&cfg.PositionsFile, prefix+"positions.file", "/var/log/positions.yaml", "Location to read/write positions from.")
 	f.BoolVar(&cfg.IgnoreInvalidYaml, prefix+"positions.ignore-invalid-yaml", false, "whether to ignore & later overwrite positions files that are corrupted")
+	f.StringVar((*string)(&cfg.Format), prefix+"positions.format", string(FormatYAML), "Format of the positions file, one of yaml or json.")
+}
+
+// format returns the configured format, falling back to FormatYAML.
+func (cfg *Config) format() Format {
+	if cfg.Format == "" {
+		return FormatYAML
+	}
+	return cfg.Format
 }
 
 // RegisterFlags register flags.
@@ -52,8 +92,15 @@ type positions struct {
 	cfg       Config
 	mtx       sync.Mutex
 	positions map[Entry]string
+	dirty     bool // Whether positions changed since they were last saved.
 	quit      chan struct{}
 	done      chan struct{}
+
+	missingSince   map[Entry]time.Time // When files of entries were first found missing.
+	removedEntries prometheus.Counter
+
+	writeErr    error // Error of the last write, nil if it succeeded.
+	writeErrors prometheus.Counter
 }
 
 // Entry describes a positions file entry consisting of an absolute file path and
@@ -73,6 +120,18 @@ type File struct {
 	Positions map[Entry]string `yaml:"positions"`
 }
 
+// jsonFile is the JSON representation of File. JSON objects can only have
+// string keys, so positions are stored as a list instead of a map.
+type jsonFile struct {
+	Positions []jsonEntry `json:"positions"`
+}
+
+type jsonEntry struct {
+	Path     string `json:"path"`
+	Labels   string `json:"labels"`
+	Position string `json:"position"`
+}
+
 type Positions interface {
 	// GetString returns how far we've through a file as a string.
 	// JournalTarget writes a journal cursor to the positions file, while
@@ -88,10 +147,26 @@ type Positions interface {
 	PutString(path, labels string, pos string)
 	// Put records (asynchronously) how far we've read through a file.
 	Put(path, labels string, pos int64)
+	// PutCursor records (asynchronously) an opaque cursor under key, for
+	// targets which don't read files. Cursors are written along with file
+	// positions and are never removed by the cleanup of missing files.
+	PutCursor(key, value string)
+	// GetCursor returns the cursor recorded under key, and whether there is
+	// one.
+	GetCursor(key string) (string, bool)
 	// Remove removes the position tracking for a filepath
 	Remove(path, labels string)
 	// SyncPeriod returns how often the positions file gets resynced
 	SyncPeriod() time.Duration
+	// SetCleanupGracePeriod changes how long the file of an entry must be
+	// missing before the entry is removed.
+	SetCleanupGracePeriod(d time.Duration)
+	// Sync immediately writes pending changes to the positions file.
+	Sync()
+	// WriteError returns the error of the last attempt to write the
+	// positions file, or nil if it succeeded. Positions aren't persisted
+	// while it returns an error.
+	WriteError() error
 	// Stop the Position tracker.
 	Stop()
 }
@@ -126,7 +201,7 @@ func ConvertLegacyPositionsFile(legacyPath, newPath string, l log.Logger) {
 		}] = v
 	}
 	// After conversion remove the file.
-	err = writePositionFile(newPath, newPositions)
+	err = writePositionFile(newPath, FormatYAML, newPositions)
 	if err != nil {
 		level.Error(l).Log("msg", "error writing new positions file from legacy", "path", newPath, "error", err)
 	}
@@ -155,26 +230,124 @@ func readLegacyFile(legacyPath string, l log.Logger) *LegacyFile {
 	return legacyPositions
 }
 
-// New makes a new Positions.
-func New(logger log.Logger, cfg Config) (Positions, error) {
-	positionData, err := readPositionsFile(cfg, logger)
+// Migration is a change of the path of a positions file entry made by
+// Migrate.
+type Migration struct {
+	Entry   Entry  // Entry before the migration.
+	NewPath string // Path of the entry after the migration.
+}
+
+// Migrate rewrites the path of every entry of the positions file at filename
+// which starts with oldPrefix, replacing oldPrefix with newPrefix. Offsets and
+// labels are preserved, and the file is replaced atomically in the format it
+// was written in. If dryRun is true, the file is left untouched.
+//
+// The returned migrations list the rewritten entries. No entry is rewritten
+// if a new path clashes with the path of another entry with the same labels.
+// The positions file must not be used by a running component during the
+// migration, or the component may overwrite it.
+func Migrate(filename, oldPrefix, newPrefix string, dryRun bool) ([]Migration, error) {
+	if _, err := os.Stat(filename); err != nil {
+		return nil, err
+	}
+
+	cfg := Config{PositionsFile: filename}
+	positions, format, err := readPositionsFileFormat(cfg, log.NewNopLogger())
 	if err != nil {
 		return nil, err
 	}
 
+	var (
+		migrations []Migration
+		migrated   = make(map[Entry]string, len(positions))
+	)
+	for entry, pos := range positions {
+		if !strings.HasPrefix(entry.Path, oldPrefix) {
+			migrated[entry] = pos
+			continue
+		}
+
+		newPath := newPrefix + strings.TrimPrefix(entry.Path, oldPrefix)
+		migrations = append(migrations, Migration{Entry: entry, NewPath: newPath})
+	}
+	for _, m := range migrations {
+		newEntry := Entry{Path: m.NewPath, Labels: m.Entry.Labels}
+		if _, exists := migrated[newEntry]; exists {
+			return nil, fmt.Errorf("can't migrate %s to %s: an entry with labels %s already exists", m.Entry.Path, m.NewPath, m.Entry.Labels)
+		}
+		migrated[newEntry] = positions[m.Entry]
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		if migrations[i].Entry.Path != migrations[j].Entry.Path {
+			return migrations[i].Entry.Path < migrations[j].Entry.Path
+		}
+		return migrations[i].Entry.Labels < migrations[j].Entry.Labels
+	})
+
+	if dryRun || len(migrations) == 0 {
+		return migrations, nil
+	}
+	return migrations, writePositionFile(filename, format, migrated)
+}
+
+// New makes a new Positions.
+func New(logger log.Logger, cfg Config) (Positions, error) {
+	switch cfg.format() {
+	case FormatYAML, FormatJSON:
+	default:
+		return nil, fmt.Errorf("unsupported positions file format %q, expected one of %s,%s", cfg.Format, FormatYAML, FormatJSON)
+	}
+
+	positionData, fileFormat := map[Entry]string{}, cfg.format()
+	if !cfg.InMemory {
+		var err error
+		positionData, fileFormat, err = readPositionsFileFormat(cfg, logger)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	p := &positions{
 		logger:    logger,
 		cfg:       cfg,
 		positions: positionData,
 		quit:      make(chan struct{}),
 		done:      make(chan struct{}),
+
+		// Rewrite a file written in another format on the first sync.
+		dirty: fileFormat != cfg.format(),
+
+		missingSince: make(map[Entry]time.Time),
+		removedEntries: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "loki_positions_removed_entries_total",
+			Help: "Number of positions entries removed because their file no longer exists.",
+		}),
+		writeErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "loki_positions_write_errors_total",
+			Help: "Number of failed attempts to write the positions file.",
+		}),
+	}
+	if cfg.Registerer != nil {
+		for _, c := range []prometheus.Collector{p.removedEntries, p.writeErrors} {
+			if err := cfg.Registerer.Register(c); err != nil {
+				return nil, err
+			}
+		}
 	}
 
-	go p.run()
+	// There's nothing to sync or clean up on disk when positions are only kept
+	// in memory.
+	if !cfg.InMemory {
+		go p.run()
+	}
 	return p, nil
 }
 
 func (p *positions) Stop() {
+	if p.cfg.InMemory {
+		return
+	}
 	close(p.quit)
 	<-p.done
 }
@@ -182,13 +355,28 @@ func (p *positions) Stop() {
 func (p *positions) PutString(path, labels string, pos string) {
 	p.mtx.Lock()
 	defer p.mtx.Unlock()
+	if old, ok := p.positions[Entry{path, labels}]; ok && old == pos {
+		return
+	}
 	p.positions[Entry{path, labels}] = pos
+	p.dirty = true
 }
 
 func (p *positions) Put(path, labels string, pos int64) {
 	p.PutString(path, labels, strconv.FormatInt(pos, 10))
 }
 
+func (p *positions) PutCursor(key, value string) {
+	p.PutString(CursorKey(key), "", value)
+}
+
+func (p *positions) GetCursor(key string) (string, bool) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	value, ok := p.positions[Entry{CursorKey(key), ""}]
+	return value, ok
+}
+
 func (p *positions) GetString(path, labels string) string {
 	p.mtx.Lock()
 	defer p.mtx.Unlock()
@@ -212,13 +400,33 @@ func (p *positions) Remove(path, labels string) {
 }
 
 func (p *positions) remove(path, labels string) {
+	if _, ok := p.positions[Entry{path, labels}]; ok {
+		p.dirty = true
+	}
 	delete(p.positions, Entry{path, labels})
+	delete(p.missingSince, Entry{path, labels})
 }
 
 func (p *positions) SyncPeriod() time.Duration {
 	return p.cfg.SyncPeriod
 }
 
+func (p *positions) SetCleanupGracePeriod(d time.Duration) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.cfg.CleanupGracePeriod = d
+}
+
+func (p *positions) Sync() {
+	p.save()
+}
+
+func (p *positions) WriteError() error {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	return p.writeErr
+}
+
 func (p *positions) run() {
 	defer func() {
 		p.save()
@@ -238,19 +446,36 @@ func (p *positions) run() {
 	}
 }
 
+// save writes the positions file if positions changed since the last time it
+// was written.
 func (p *positions) save() {
-	if p.cfg.ReadOnly {
+	if p.cfg.ReadOnly || p.cfg.InMemory {
 		return
 	}
 	p.mtx.Lock()
+	if !p.dirty {
+		p.mtx.Unlock()
+		return
+	}
 	positions := make(map[Entry]string, len(p.positions))
 	for k, v := range p.positions {
 		positions[k] = v
 	}
+	p.dirty = false
 	p.mtx.Unlock()
 
-	if err := writePositionFile(p.cfg.PositionsFile, positions); err != nil {
+	err := writePositionFile(p.cfg.PositionsFile, p.cfg.format(), positions)
+	if err != nil {
 		level.Error(p.logger).Log("msg", "error writing positions file", "error", err)
+		p.writeErrors.Inc()
+	}
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.writeErr = err
+	if err != nil {
+		// Try again on the next save.
+		p.dirty = true
 	}
 }
 
@@ -262,7 +487,10 @@ func CursorKey(key string) string {
 func (p *positions) cleanup() {
 	p.mtx.Lock()
 	defer p.mtx.Unlock()
-	toRemove := []Entry{}
+	var (
+		now      = time.Now()
+		toRemove = []Entry{}
+	)
 	for k := range p.positions {
 		// If the position file is prefixed with cursor, it's a
 		// cursor and not a file on disk.
@@ -273,46 +501,126 @@ func (p *positions) cleanup() {
 
 		if _, err := os.Stat(k.Path); err != nil {
 			if os.IsNotExist(err) {
-				// File no longer exists.
-				toRemove = append(toRemove, k)
+				// File no longer exists. Only remove it once it's been missing
+				// for the grace period, in case it's recreated.
+				since, ok := p.missingSince[k]
+				if !ok {
+					since = now
+					p.missingSince[k] = since
+				}
+				if now.Sub(since) >= p.cfg.CleanupGracePeriod {
+					toRemove = append(toRemove, k)
+				}
 			} else {
 				// Can't determine if file exists or not, some other error.
 				level.Warn(p.logger).Log("msg", "could not determine if log file "+
 					"still exists while cleaning positions file", "error", err)
 			}
+			continue
 		}
+
+		// The file exists (again), so it's no longer missing.
+		delete(p.missingSince, k)
 	}
 	for _, tr := range toRemove {
 		p.remove(tr.Path, tr.Labels)
 	}
+	p.removedEntries.Add(float64(len(toRemove)))
 }
 
 func readPositionsFile(cfg Config, logger log.Logger) (map[Entry]string, error) {
+	positions, _, err := readPositionsFileFormat(cfg, logger)
+	return positions, err
+}
+
+// readPositionsFileFormat reads the positions file, detecting its format
+// from its content. The returned format is the configured one if the file
+// doesn't exist or is ignored.
+func readPositionsFileFormat(cfg Config, logger log.Logger) (map[Entry]string, Format, error) {
 	cleanfn := filepath.Clean(cfg.PositionsFile)
 	buf, err := os.ReadFile(cleanfn)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return map[Entry]string{}, nil
+			return map[Entry]string{}, cfg.format(), nil
 		}
-		return nil, err
+		return nil, "", err
 	}
 
-	var p File
-	err = yaml.UnmarshalStrict(buf, &p)
+	format := detectFormat(buf)
+	positions, err := decodePositions(format, buf)
 	if err != nil {
 		// return empty if cfg option enabled
 		if cfg.IgnoreInvalidYaml {
 			level.Debug(logger).Log("msg", "ignoring invalid positions file", "file", cleanfn, "error", err)
-			return map[Entry]string{}, nil
+			return map[Entry]string{}, cfg.format(), nil
 		}
 
-		return nil, fmt.Errorf("invalid yaml positions file [%s]: %v", cleanfn, err)
+		return nil, "", fmt.Errorf("invalid %s positions file [%s]: %v", format, cleanfn, err)
 	}
 
-	// p.Positions will be nil if the file exists but is empty
-	if p.Positions == nil {
-		p.Positions = map[Entry]string{}
+	return positions, format, nil
+}
+
+func writePositionFile(filename string, format Format, positions map[Entry]string) error {
+	buf, err := encodePositions(format, positions)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filename, buf)
+}
+
+// detectFormat returns the format of the positions file content in buf. YAML
+// files written by this package never start with a brace.
+func detectFormat(buf []byte) Format {
+	if bytes.HasPrefix(bytes.TrimSpace(buf), []byte("{")) {
+		return FormatJSON
+	}
+	return FormatYAML
+}
+
+func encodePositions(format Format, positions map[Entry]string) ([]byte, error) {
+	if format != FormatJSON {
+		return yaml.Marshal(File{
+			Positions: positions,
+		})
 	}
 
-	return p.Positions, nil
+	f := jsonFile{Positions: make([]jsonEntry, 0, len(positions))}
+	for k, v := range positions {
+		f.Positions = append(f.Positions, jsonEntry{Path: k.Path, Labels: k.Labels, Position: v})
+	}
+	sort.Slice(f.Positions, func(i, j int) bool {
+		if f.Positions[i].Path != f.Positions[j].Path {
+			return f.Positions[i].Path < f.Positions[j].Path
+		}
+		return f.Positions[i].Labels < f.Positions[j].Labels
+	})
+	return json.MarshalIndent(f, "", "  ")
+}
+
+func decodePositions(format Format, buf []byte) (map[Entry]string, error) {
+	positions := map[Entry]string{}
+
+	if format != FormatJSON {
+		var p File
+		if err := yaml.UnmarshalStrict(buf, &p); err != nil {
+			return nil, err
+		}
+		// p.Positions will be nil if the file exists but is empty
+		if p.Positions != nil {
+			positions = p.Positions
+		}
+		return positions, nil
+	}
+
+	var p jsonFile
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&p); err != nil {
+		return nil, err
+	}
+	for _, e := range p.Positions {
+		positions[Entry{Path: e.Path, Labels: e.Labels}] = e.Position
+	}
+	return positions, nil
 }
@@ -12,6 +12,8 @@ import (
 	"time"
 
 	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
 	"gopkg.in/yaml.v2"
 
@@ -74,7 +76,7 @@ func TestLegacyConversionWithNewFile(t *testing.T) {
 	legacy := writeLegacy(t, tmpDir)
 	// Write a new file.
 	positionsPath := filepath.Join(tmpDir, "positions")
-	err := writePositionFile(positionsPath, map[Entry]string{
+	err := writePositionFile(positionsPath, FormatYAML, map[Entry]string{
 		{Path: "/tmp/newrandom.log", Labels: ""}: "100",
 	})
 	require.NoError(t, err)
@@ -97,7 +99,7 @@ func TestLegacyConversionWithNoLegacyFile(t *testing.T) {
 	legacy := filepath.Join(tmpDir, "legacy")
 	positionsPath := filepath.Join(tmpDir, "positions")
 	// Write a new file.
-	err := writePositionFile(positionsPath, map[Entry]string{
+	err := writePositionFile(positionsPath, FormatYAML, map[Entry]string{
 		{Path: "/tmp/newrandom.log", Labels: ""}: "100",
 	})
 	require.NoError(t, err)
@@ -365,3 +367,335 @@ positions:
 		Labels: ``,
 	}])
 }
+
+func TestCleanupGracePeriod(t *testing.T) {
+	var (
+		dir     = t.TempDir()
+		deleted = filepath.Join(dir, "deleted.log")
+		rotated = filepath.Join(dir, "rotated.log")
+	)
+	require.NoError(t, os.WriteFile(deleted, []byte("line\n"), 0644))
+	require.NoError(t, os.WriteFile(rotated, []byte("line\n"), 0644))
+
+	reg := prometheus.NewRegistry()
+	p, err := New(util_log.Logger, Config{
+		SyncPeriod:         20 * time.Second,
+		PositionsFile:      filepath.Join(dir, "positions.yml"),
+		CleanupGracePeriod: 50 * time.Millisecond,
+		Registerer:         reg,
+	})
+	require.NoError(t, err)
+	defer p.Stop()
+
+	p.Put(deleted, "", 5)
+	p.Put(rotated, "", 5)
+
+	require.NoError(t, os.Remove(deleted))
+	require.NoError(t, os.Remove(rotated))
+
+	// Entries are kept while their files are missing for less than the grace
+	// period.
+	p.(*positions).cleanup()
+	require.Equal(t, "5", p.GetString(deleted, ""))
+	require.Equal(t, "5", p.GetString(rotated, ""))
+
+	// The rotated file reappears, so only the deleted file's entry is removed
+	// once the grace period is over.
+	require.NoError(t, os.WriteFile(rotated, []byte("line\n"), 0644))
+	time.Sleep(100 * time.Millisecond)
+
+	p.(*positions).cleanup()
+	require.Equal(t, "", p.GetString(deleted, ""))
+	require.Equal(t, "5", p.GetString(rotated, ""))
+	require.Equal(t, 1.0, testutil.ToFloat64(p.(*positions).removedEntries))
+}
+
+func TestSyncOnlyWritesChanges(t *testing.T) {
+	var (
+		dir  = t.TempDir()
+		path = filepath.Join(dir, "positions.yml")
+	)
+
+	p, err := New(util_log.Logger, Config{
+		SyncPeriod:    20 * time.Second,
+		PositionsFile: path,
+	})
+	require.NoError(t, err)
+	defer p.Stop()
+
+	p.Put("/tmp/random.log", "", 10)
+	p.Sync()
+	require.FileExists(t, path)
+
+	// Putting the same position again doesn't cause another write.
+	require.NoError(t, os.Remove(path))
+	p.Put("/tmp/random.log", "", 10)
+	p.Sync()
+	require.NoFileExists(t, path)
+
+	// A new position is written on the next sync.
+	p.Put("/tmp/random.log", "", 20)
+	p.Sync()
+
+	out, err := readPositionsFile(Config{PositionsFile: path}, log.NewNopLogger())
+	require.NoError(t, err)
+	require.Equal(t, map[Entry]string{
+		{Path: "/tmp/random.log", Labels: ""}: "20",
+	}, out)
+}
+
+func TestFormatRoundTrip(t *testing.T) {
+	entries := map[Entry]string{
+		{Path: "/tmp/random.log", Labels: `{job="tmp"}`}:   "17623",
+		{Path: "/tmp/random.log", Labels: `{job="other"}`}: "42",
+		{Path: "/var/log/journal", Labels: "{}"}:           "cursor-s=abc;i=1",
+	}
+
+	for _, format := range []Format{FormatYAML, FormatJSON} {
+		t.Run(string(format), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "positions")
+			require.NoError(t, writePositionFile(path, format, entries))
+
+			buf, err := os.ReadFile(path)
+			require.NoError(t, err)
+			require.Equal(t, format, detectFormat(buf))
+
+			out, err := readPositionsFile(Config{PositionsFile: path}, log.NewNopLogger())
+			require.NoError(t, err)
+			require.Equal(t, entries, out)
+		})
+	}
+}
+
+func TestFormatMigration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "positions")
+	require.NoError(t, writePositionFile(path, FormatYAML, map[Entry]string{
+		{Path: "/tmp/random.log", Labels: "{}"}: "100",
+	}))
+
+	p, err := New(log.NewNopLogger(), Config{
+		SyncPeriod:    time.Hour,
+		PositionsFile: path,
+		Format:        FormatJSON,
+	})
+	require.NoError(t, err)
+
+	pos, err := p.Get("/tmp/random.log", "{}")
+	require.NoError(t, err)
+	require.Equal(t, int64(100), pos)
+
+	// The file is rewritten in the new format without any change to the
+	// positions.
+	p.Sync()
+	buf, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, FormatJSON, detectFormat(buf))
+	p.Stop()
+
+	// Switching back to YAML reads the JSON file.
+	p, err = New(log.NewNopLogger(), Config{
+		SyncPeriod:    time.Hour,
+		PositionsFile: path,
+	})
+	require.NoError(t, err)
+	defer p.Stop()
+
+	pos, err = p.Get("/tmp/random.log", "{}")
+	require.NoError(t, err)
+	require.Equal(t, int64(100), pos)
+}
+
+func TestUnsupportedFormat(t *testing.T) {
+	_, err := New(log.NewNopLogger(), Config{
+		SyncPeriod:    time.Hour,
+		PositionsFile: filepath.Join(t.TempDir(), "positions"),
+		Format:        "toml",
+	})
+	require.EqualError(t, err, `unsupported positions file format "toml", expected one of yaml,json`)
+}
+
+func TestInMemory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "positions.yml")
+	cfg := Config{
+		SyncPeriod:    10 * time.Millisecond,
+		PositionsFile: path,
+		InMemory:      true,
+	}
+
+	p, err := New(log.NewNopLogger(), cfg)
+	require.NoError(t, err)
+
+	p.Put("/tmp/random.log", "{}", 100)
+	p.Sync()
+	pos, err := p.Get("/tmp/random.log", "{}")
+	require.NoError(t, err)
+	require.Equal(t, int64(100), pos)
+	p.Stop()
+
+	_, err = os.Stat(path)
+	require.True(t, os.IsNotExist(err), "positions file must not be created")
+
+	// An existing positions file is ignored.
+	require.NoError(t, writePositionFile(path, FormatYAML, map[Entry]string{
+		{Path: "/tmp/random.log", Labels: "{}"}: "100",
+	}))
+	p, err = New(log.NewNopLogger(), cfg)
+	require.NoError(t, err)
+	defer p.Stop()
+	require.Empty(t, p.GetString("/tmp/random.log", "{}"))
+}
+
+func TestWriteError(t *testing.T) {
+	var (
+		dir  = filepath.Join(t.TempDir(), "positions")
+		path = filepath.Join(dir, "positions.yml")
+	)
+	require.NoError(t, os.Mkdir(dir, 0750))
+
+	p, err := New(util_log.Logger, Config{
+		SyncPeriod:    20 * time.Second,
+		PositionsFile: path,
+		Registerer:    prometheus.NewRegistry(),
+	})
+	require.NoError(t, err)
+	defer p.Stop()
+
+	p.Put("/tmp/random.log", "", 10)
+	p.Sync()
+	require.NoError(t, p.WriteError())
+
+	// Removing the directory makes the positions file unwritable.
+	require.NoError(t, os.RemoveAll(dir))
+	p.Put("/tmp/random.log", "", 20)
+	p.Sync()
+	require.Error(t, p.WriteError())
+	require.Equal(t, 1.0, testutil.ToFloat64(p.(*positions).writeErrors))
+
+	// Failed writes are retried, and the error is cleared once a write
+	// succeeds.
+	require.NoError(t, os.Mkdir(dir, 0750))
+	p.Sync()
+	require.NoError(t, p.WriteError())
+
+	out, err := readPositionsFile(Config{PositionsFile: path}, log.NewNopLogger())
+	require.NoError(t, err)
+	require.Equal(t, map[Entry]string{
+		{Path: "/tmp/random.log", Labels: ""}: "20",
+	}, out)
+}
+
+func TestCursors(t *testing.T) {
+	var (
+		dir  = t.TempDir()
+		path = filepath.Join(dir, "positions.yml")
+		file = filepath.Join(dir, "file.log")
+	)
+	require.NoError(t, os.WriteFile(file, []byte("line\n"), 0644))
+
+	p, err := New(util_log.Logger, Config{
+		SyncPeriod:    20 * time.Second,
+		PositionsFile: path,
+	})
+	require.NoError(t, err)
+
+	_, ok := p.GetCursor("events")
+	require.False(t, ok)
+
+	p.PutCursor("events", "cursor-1")
+	p.PutCursor("events", "cursor-2")
+	p.Put(file, "", 10)
+
+	value, ok := p.GetCursor("events")
+	require.True(t, ok)
+	require.Equal(t, "cursor-2", value)
+
+	// Cleaning up missing files doesn't remove cursors.
+	p.(*positions).cleanup()
+	_, ok = p.GetCursor("events")
+	require.True(t, ok)
+	p.Stop()
+
+	// Cursors and file positions are restored after a restart.
+	p, err = New(util_log.Logger, Config{
+		SyncPeriod:    20 * time.Second,
+		PositionsFile: path,
+	})
+	require.NoError(t, err)
+	defer p.Stop()
+
+	value, ok = p.GetCursor("events")
+	require.True(t, ok)
+	require.Equal(t, "cursor-2", value)
+
+	pos, err := p.Get(file, "")
+	require.NoError(t, err)
+	require.Equal(t, int64(10), pos)
+}
+
+func TestMigrate(t *testing.T) {
+	for _, format := range []Format{FormatYAML, FormatJSON} {
+		t.Run(string(format), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "positions")
+			require.NoError(t, writePositionFile(path, format, map[Entry]string{
+				{Path: "/var/log/app/a.log", Labels: `{job="a"}`}: "100",
+				{Path: "/var/log/app/b.log", Labels: `{job="b"}`}: "200",
+				{Path: "/var/log/app/b.log", Labels: `{job="c"}`}: "300",
+				{Path: "/var/log/other.log", Labels: "{}"}:        "400",
+			}))
+
+			expect := []Migration{
+				{Entry: Entry{Path: "/var/log/app/a.log", Labels: `{job="a"}`}, NewPath: "/mnt/logs/app/a.log"},
+				{Entry: Entry{Path: "/var/log/app/b.log", Labels: `{job="b"}`}, NewPath: "/mnt/logs/app/b.log"},
+				{Entry: Entry{Path: "/var/log/app/b.log", Labels: `{job="c"}`}, NewPath: "/mnt/logs/app/b.log"},
+			}
+
+			// A dry run lists the migrations without changing the file.
+			before, err := os.ReadFile(path)
+			require.NoError(t, err)
+			migrations, err := Migrate(path, "/var/log/app/", "/mnt/logs/app/", true)
+			require.NoError(t, err)
+			require.Equal(t, expect, migrations)
+			after, err := os.ReadFile(path)
+			require.NoError(t, err)
+			require.Equal(t, before, after)
+
+			migrations, err = Migrate(path, "/var/log/app/", "/mnt/logs/app/", false)
+			require.NoError(t, err)
+			require.Equal(t, expect, migrations)
+
+			buf, err := os.ReadFile(path)
+			require.NoError(t, err)
+			require.Equal(t, format, detectFormat(buf))
+
+			out, err := readPositionsFile(Config{PositionsFile: path}, log.NewNopLogger())
+			require.NoError(t, err)
+			require.Equal(t, map[Entry]string{
+				{Path: "/mnt/logs/app/a.log", Labels: `{job="a"}`}: "100",
+				{Path: "/mnt/logs/app/b.log", Labels: `{job="b"}`}: "200",
+				{Path: "/mnt/logs/app/b.log", Labels: `{job="c"}`}: "300",
+				{Path: "/var/log/other.log", Labels: "{}"}:         "400",
+			}, out)
+		})
+	}
+}
+
+func TestMigrateConflict(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "positions")
+	entries := map[Entry]string{
+		{Path: "/old/a.log", Labels: "{}"}: "100",
+		{Path: "/new/a.log", Labels: "{}"}: "200",
+	}
+	require.NoError(t, writePositionFile(path, FormatYAML, entries))
+
+	_, err := Migrate(path, "/old/", "/new/", false)
+	require.EqualError(t, err, "can't migrate /old/a.log to /new/a.log: an entry with labels {} already exists")
+
+	out, err := readPositionsFile(Config{PositionsFile: path}, log.NewNopLogger())
+	require.NoError(t, err)
+	require.Equal(t, entries, out)
+}
+
+func TestMigrateMissingFile(t *testing.T) {
+	_, err := Migrate(filepath.Join(t.TempDir(), "positions"), "/old/", "/new/", false)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
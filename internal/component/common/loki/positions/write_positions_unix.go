@@ -11,17 +11,9 @@ import (
 	"path/filepath"
 
 	renameio "github.com/google/renameio/v2"
-	yaml "gopkg.in/yaml.v2"
 )
 
-func writePositionFile(filename string, positions map[Entry]string) error {
-	buf, err := yaml.Marshal(File{
-		Positions: positions,
-	})
-	if err != nil {
-		return err
-	}
-
+func writeFileAtomic(filename string, buf []byte) error {
 	target := filepath.Clean(filename)
 
 	return renameio.WriteFile(target, buf, os.FileMode(positionFileMode))
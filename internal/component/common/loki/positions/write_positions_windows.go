@@ -10,16 +10,8 @@ import (
 	"bytes"
 
 	"github.com/natefinch/atomic"
-	yaml "gopkg.in/yaml.v2"
 )
 
-func writePositionFile(filename string, positions map[Entry]string) error {
-	buf, err := yaml.Marshal(File{
-		Positions: positions,
-	})
-	if err != nil {
-		return err
-	}
+func writeFileAtomic(filename string, buf []byte) error {
 	return atomic.WriteFile(filename, bytes.NewReader(buf))
-
 }
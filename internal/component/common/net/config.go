@@ -44,6 +44,7 @@ type HTTPConfig struct {
 	ServerReadTimeout  time.Duration `river:"server_read_timeout,attr,optional"`
 	ServerWriteTimeout time.Duration `river:"server_write_timeout,attr,optional"`
 	ServerIdleTimeout  time.Duration `river:"server_idle_timeout,attr,optional"`
+	TLSConfig          *TLSConfig    `river:"tls,block,optional"`
 }
 
 // Into applies the configs from HTTPConfig into a dskit.Into.
@@ -54,6 +55,30 @@ func (h *HTTPConfig) Into(c *dskit.Config) {
 	c.HTTPServerReadTimeout = h.ServerReadTimeout
 	c.HTTPServerWriteTimeout = h.ServerWriteTimeout
 	c.HTTPServerIdleTimeout = h.ServerIdleTimeout
+	if h.TLSConfig != nil {
+		h.TLSConfig.Into(&c.HTTPTLSConfig)
+	}
+}
+
+// TLSConfig configures TLS for the HTTP server. The certificate and key files
+// are read again when clients which send a server name connect, so that
+// rotated certificates are used without restarting the server.
+type TLSConfig struct {
+	CertFile       string `river:"cert_file,attr"`
+	KeyFile        string `river:"key_file,attr"`
+	ClientCAFile   string `river:"client_ca_file,attr,optional"`
+	ClientAuthType string `river:"client_auth_type,attr,optional"`
+}
+
+// Into applies the configs from TLSConfig into a dskit.TLSConfig.
+func (t *TLSConfig) Into(c *dskit.TLSConfig) {
+	c.TLSCertPath = t.CertFile
+	c.TLSKeyPath = t.KeyFile
+	c.ClientCAs = t.ClientCAFile
+	c.ClientAuth = t.ClientAuthType
+	if t.ClientCAFile != "" && t.ClientAuthType == "" {
+		c.ClientAuth = "RequireAndVerifyClientCert"
+	}
 }
 
 // GRPCConfig configures the gRPC dskit started by dskit.Server.
@@ -102,6 +102,23 @@ func TestConfig(t *testing.T) {
 				require.Equal(t, time.Minute, config.ServerGracefulShutdownTimeout)
 			},
 		},
+		"tls": {
+			raw: `
+			http {
+				tls {
+					cert_file      = "/etc/tls/cert.pem"
+					key_file       = "/etc/tls/key.pem"
+					client_ca_file = "/etc/tls/ca.pem"
+				}
+			}`,
+			assert: func(t *testing.T, config dskit.Config) {
+				require.Equal(t, "/etc/tls/cert.pem", config.HTTPTLSConfig.TLSCertPath)
+				require.Equal(t, "/etc/tls/key.pem", config.HTTPTLSConfig.TLSKeyPath)
+				require.Equal(t, "/etc/tls/ca.pem", config.HTTPTLSConfig.ClientCAs)
+				// client certificates are verified by default when a CA is set
+				require.Equal(t, "RequireAndVerifyClientCert", config.HTTPTLSConfig.ClientAuth)
+			},
+		},
 		"all params": {
 			raw: `
 			graceful_shutdown_timeout = "1m"
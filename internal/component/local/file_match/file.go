@@ -2,6 +2,8 @@ package file_match
 
 import (
 	"context"
+	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
@@ -9,6 +11,7 @@ import (
 	"github.com/grafana/agent/internal/component/discovery"
 	"github.com/grafana/agent/internal/featuregate"
 	"github.com/grafana/agent/internal/flow/logging/level"
+	"github.com/prometheus/common/model"
 )
 
 func init() {
@@ -113,14 +116,50 @@ func (c *Component) Run(ctx context.Context) error {
 }
 
 func (c *Component) getWatchedFiles() []discovery.Target {
-	paths := make([]discovery.Target, 0)
+	var (
+		paths = make([]discovery.Target, 0)
+		seen  = make(map[watchedFile]struct{})
+	)
 	// See if there is anything new we need to check.
 	for _, w := range c.watches {
 		newPaths, err := w.getPaths()
 		if err != nil {
 			level.Error(c.opts.Logger).Log("msg", "error getting paths", "path", w.getPath(), "excluded", w.getExcludePath(), "err", err)
 		}
-		paths = append(paths, newPaths...)
+		for _, p := range newPaths {
+			// Skip files which were already matched with the same labels, either
+			// by overlapping globs or through a symlink.
+			key := newWatchedFile(p)
+			if _, ok := seen[key]; ok {
+				level.Debug(c.opts.Logger).Log("msg", "skipping duplicate path", "path", p["__path__"], "resolved_path", key.path)
+				continue
+			}
+			seen[key] = struct{}{}
+			paths = append(paths, p)
+		}
 	}
 	return paths
 }
+
+// watchedFile uniquely identifies a file matched by a target.
+type watchedFile struct {
+	path   string // Path with symlinks resolved.
+	labels string // Labels of the target, excluding reserved labels.
+}
+
+func newWatchedFile(target discovery.Target) watchedFile {
+	path := target["__path__"]
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+
+	labels := make(model.LabelSet, len(target))
+	for k, v := range target {
+		if strings.HasPrefix(k, model.ReservedLabelPrefix) {
+			continue
+		}
+		labels[model.LabelName(k)] = model.LabelValue(v)
+	}
+
+	return watchedFile{path: path, labels: labels.String()}
+}
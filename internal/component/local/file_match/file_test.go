@@ -238,6 +238,24 @@ func TestMultiLabels(t *testing.T) {
 	require.True(t, contains([]discovery.Target{foundFiles[1]}, "t1.txt"))
 }
 
+func TestDuplicatePaths(t *testing.T) {
+	dir := path.Join(os.TempDir(), "agent_testing", "t5")
+	os.MkdirAll(dir, 0755)
+	writeFile(t, dir, "t1.txt")
+	writeFile(t, dir, "t2.log")
+	require.NoError(t, os.Symlink(path.Join(dir, "t2.log"), path.Join(dir, "t2-link.log")))
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+	})
+
+	// Both globs match t1.txt, and t2-link.log resolves to t2.log.
+	c := createComponent(t, dir, []string{path.Join(dir, "*.txt"), path.Join(dir, "t*")}, nil)
+	foundFiles := c.getWatchedFiles()
+	require.Len(t, foundFiles, 2)
+	require.True(t, contains(foundFiles, "t1.txt"))
+	require.True(t, contains(foundFiles, "t2.log") || contains(foundFiles, "t2-link.log"))
+}
+
 // createComponent creates a component with the given paths and labels. The paths and excluded slices are zipped together
 // to create the set of targets to pass to the component.
 func createComponent(t *testing.T, dir string, paths []string, excluded []string) *Component {
@@ -0,0 +1,395 @@
+// Package secrets implements the local.secrets component.
+package secrets
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/agent/internal/component"
+	"github.com/grafana/agent/internal/featuregate"
+	filedetector "github.com/grafana/agent/internal/filedetector"
+	"github.com/grafana/agent/internal/flow/logging/level"
+	"github.com/grafana/river/rivertypes"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:      "local.secrets",
+		Stability: featuregate.StabilityBeta,
+		Args:      Arguments{},
+		Exports:   Exports{},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return New(opts, args.(Arguments))
+		},
+	})
+}
+
+const (
+	// formatKeyValue parses a file as KEY=VALUE lines.
+	formatKeyValue = "key_value"
+
+	// formatJSON parses a file as a JSON object.
+	formatJSON = "json"
+)
+
+// Arguments holds values which are used to configure the local.secrets
+// component.
+type Arguments struct {
+	// Path is the file or directory to read secrets from.
+	Path string `river:"path,attr"`
+	// Format indicates how to parse the file at Path. It's ignored when Path is
+	// a directory.
+	Format string `river:"format,attr,optional"`
+	// Type indicates how to detect changes to Path.
+	Type filedetector.Detector `river:"detector,attr,optional"`
+	// PollFrequency determines the frequency to check for changes when Type is
+	// Poll, and to re-establish the watch when Type is FSNotify.
+	PollFrequency time.Duration `river:"poll_frequency,attr,optional"`
+	// Debounce is the time to wait for changes to settle before rereading
+	// Path.
+	Debounce time.Duration `river:"debounce,attr,optional"`
+}
+
+// DefaultArguments provides the default arguments for the local.secrets
+// component.
+var DefaultArguments = Arguments{
+	Format:        formatKeyValue,
+	Type:          filedetector.DetectorFSNotify,
+	PollFrequency: time.Minute,
+	Debounce:      100 * time.Millisecond,
+}
+
+// SetToDefault implements river.Defaulter.
+func (a *Arguments) SetToDefault() {
+	*a = DefaultArguments
+}
+
+// Validate implements river.Validator.
+func (a *Arguments) Validate() error {
+	if a.Format != formatKeyValue && a.Format != formatJSON {
+		return fmt.Errorf("unrecognized format %q, expected one of %s,%s", a.Format, formatKeyValue, formatJSON)
+	}
+	if a.PollFrequency <= 0 {
+		return fmt.Errorf("poll_frequency must be greater than 0")
+	}
+	if a.Debounce < 0 {
+		return fmt.Errorf("debounce must not be negative")
+	}
+	return nil
+}
+
+// Exports holds values which are exported by the local.secrets component.
+type Exports struct {
+	// Data holds the secrets read from Path.
+	Data map[string]rivertypes.Secret `river:"data,attr"`
+}
+
+// Component implements the local.secrets component.
+type Component struct {
+	opts component.Options
+
+	mut         sync.Mutex
+	args        Arguments
+	detector    io.Closer
+	lastExports Exports // Used for determining whether exports should be updated
+
+	healthMut sync.RWMutex
+	health    component.Health
+
+	// reloadCh is a buffered channel which is written to when the watched path
+	// should be reloaded by the component.
+	reloadCh chan struct{}
+}
+
+var (
+	_ component.Component       = (*Component)(nil)
+	_ component.HealthComponent = (*Component)(nil)
+)
+
+// New creates a new local.secrets component. It will try to immediately read
+// the secrets and return an error if they can't be read.
+func New(o component.Options, args Arguments) (*Component, error) {
+	c := &Component{
+		opts:     o,
+		reloadCh: make(chan struct{}, 1),
+	}
+
+	if err := c.Update(args); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Run implements component.Component.
+func (c *Component) Run(ctx context.Context) error {
+	defer func() {
+		c.mut.Lock()
+		defer c.mut.Unlock()
+
+		if err := c.detector.Close(); err != nil {
+			level.Error(c.opts.Logger).Log("msg", "failed to shut down detector", "err", err)
+		}
+		c.detector = nil
+	}()
+
+	// Run may be called again after it exited, in which case the detector must
+	// be recreated.
+	c.mut.Lock()
+	c.configureDetector()
+	c.mut.Unlock()
+
+	var (
+		debounce   *time.Timer
+		debounceCh <-chan time.Time
+	)
+	for {
+		select {
+		case <-ctx.Done():
+			if debounce != nil {
+				debounce.Stop()
+			}
+			return nil
+		case <-c.reloadCh:
+			// Wait for changes to settle, so that writes in progress aren't
+			// exported and a burst of events causes a single read.
+			if debounce != nil {
+				debounce.Stop()
+			}
+			c.mut.Lock()
+			debounce = time.NewTimer(c.args.Debounce)
+			c.mut.Unlock()
+			debounceCh = debounce.C
+		case <-debounceCh:
+			debounce, debounceCh = nil, nil
+
+			// We ignore the error here from readSecrets since readSecrets will log
+			// errors and also report the error as the health of the component.
+			c.mut.Lock()
+			_ = c.readSecrets()
+			c.mut.Unlock()
+		}
+	}
+}
+
+// readSecrets reads the secrets from the path and exports them if they
+// changed. mut must be held when called.
+func (c *Component) readSecrets() error {
+	data, err := readPath(c.args.Path, c.args.Format)
+	if err != nil {
+		c.setHealth(component.Health{
+			Health:     component.HealthTypeUnhealthy,
+			Message:    fmt.Sprintf("failed to read secrets: %s", err),
+			UpdateTime: time.Now(),
+		})
+		level.Error(c.opts.Logger).Log("msg", "failed to read secrets", "path", c.args.Path, "err", err)
+		return err
+	}
+
+	newExports := Exports{Data: data}
+	if !reflect.DeepEqual(c.lastExports, newExports) {
+		c.opts.OnStateChange(newExports)
+	}
+	c.lastExports = newExports
+
+	c.setHealth(component.Health{
+		Health:     component.HealthTypeHealthy,
+		Message:    "read secrets",
+		UpdateTime: time.Now(),
+	})
+	return nil
+}
+
+// Update implements component.Component.
+func (c *Component) Update(args component.Arguments) error {
+	newArgs := args.(Arguments)
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.args = newArgs
+
+	// Force an immediate read of the secrets to report any potential errors
+	// early.
+	if err := c.readSecrets(); err != nil {
+		return fmt.Errorf("failed to read secrets: %w", err)
+	}
+
+	// Each detector is dedicated to a single path, so the existing detector is
+	// replaced in case the path changed.
+	if c.detector != nil {
+		if err := c.detector.Close(); err != nil {
+			level.Error(c.opts.Logger).Log("msg", "failed to shut down old detector", "err", err)
+		}
+		c.detector = nil
+	}
+
+	c.configureDetector()
+	return nil
+}
+
+// configureDetector configures the detector if one isn't set. If filesystem
+// notifications aren't available, it falls back to polling. mut must be held
+// when called.
+func (c *Component) configureDetector() {
+	if c.detector != nil {
+		// Already have a detector; don't do anything.
+		return
+	}
+
+	reload := func() {
+		select {
+		case c.reloadCh <- struct{}{}:
+		default:
+			// no-op: a reload is already queued so we don't need to queue a second
+			// one.
+		}
+	}
+
+	if c.args.Type == filedetector.DetectorFSNotify {
+		detector, err := filedetector.NewFSNotify(filedetector.FSNotifyOptions{
+			Logger:        c.opts.Logger,
+			Filename:      c.args.Path,
+			ReloadFile:    reload,
+			PollFrequency: c.args.PollFrequency,
+		})
+		if err == nil {
+			c.detector = detector
+			return
+		}
+		level.Warn(c.opts.Logger).Log("msg", "filesystem notifications are unavailable, falling back to polling", "err", err)
+	}
+
+	c.detector = filedetector.NewPoller(filedetector.PollerOptions{
+		Filename:      c.args.Path,
+		ReloadFile:    reload,
+		PollFrequency: c.args.PollFrequency,
+	})
+}
+
+// CurrentHealth implements component.HealthComponent.
+func (c *Component) CurrentHealth() component.Health {
+	c.healthMut.RLock()
+	defer c.healthMut.RUnlock()
+	return c.health
+}
+
+func (c *Component) setHealth(h component.Health) {
+	c.healthMut.Lock()
+	defer c.healthMut.Unlock()
+	c.health = h
+}
+
+// readPath reads the secrets at path. If path is a directory, every file in it
+// is a secret named after the file. Otherwise, the file is parsed according to
+// format.
+func readPath(path, format string) (map[string]rivertypes.Secret, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if fi.IsDir() {
+		return readDir(path)
+	}
+
+	bb, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if format == formatJSON {
+		return parseJSON(bb)
+	}
+	return parseKeyValue(bb)
+}
+
+// readDir reads every file in dir as a secret. Hidden files are skipped, which
+// also skips the bookkeeping entries of Kubernetes secret volumes.
+func readDir(dir string) (map[string]rivertypes.Secret, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make(map[string]rivertypes.Secret, len(entries))
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		// Follow symbolic links, which Kubernetes uses for the files of secret
+		// volumes.
+		path := filepath.Join(dir, entry.Name())
+		fi, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !fi.Mode().IsRegular() {
+			continue
+		}
+
+		bb, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		data[entry.Name()] = rivertypes.Secret(bb)
+	}
+	return data, nil
+}
+
+// parseKeyValue parses KEY=VALUE lines. Empty lines and lines starting with #
+// are ignored, and values may be surrounded by quotes.
+func parseKeyValue(bb []byte) (map[string]rivertypes.Secret, error) {
+	data := make(map[string]rivertypes.Secret)
+
+	scanner := bufio.NewScanner(bytes.NewReader(bb))
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", lineNum)
+		}
+
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		data[key] = rivertypes.Secret(value)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// parseJSON parses a JSON object. Values which aren't strings are exported as
+// their JSON encoding.
+func parseJSON(bb []byte) (map[string]rivertypes.Secret, error) {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(bb, &object); err != nil {
+		return nil, fmt.Errorf("expected a JSON object: %w", err)
+	}
+
+	data := make(map[string]rivertypes.Secret, len(object))
+	for key, raw := range object {
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			value = string(raw)
+		}
+		data[key] = rivertypes.Secret(value)
+	}
+	return data, nil
+}
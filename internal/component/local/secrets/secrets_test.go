@@ -0,0 +1,170 @@
+package secrets_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/internal/component/local/secrets"
+	filedetector "github.com/grafana/agent/internal/filedetector"
+	"github.com/grafana/agent/internal/flow/componenttest"
+	"github.com/grafana/river"
+	"github.com/grafana/river/rivertypes"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecrets(t *testing.T) {
+	t.Run("Polling change detector", func(t *testing.T) {
+		runSecretsTests(t, filedetector.DetectorPoll)
+	})
+
+	t.Run("Event change detector", func(t *testing.T) {
+		runSecretsTests(t, filedetector.DetectorFSNotify)
+	})
+}
+
+// runSecretsTests will run a suite of tests with the configured update type.
+func runSecretsTests(t *testing.T, ut filedetector.Detector) {
+	newSuiteController := func(t *testing.T, path, format string) *componenttest.Controller {
+		tc, err := componenttest.NewControllerFromID(nil, "local.secrets")
+		require.NoError(t, err)
+		go func() {
+			err := tc.Run(componenttest.TestContext(t), secrets.Arguments{
+				Path:   path,
+				Format: format,
+				Type:   ut,
+
+				// Pick a polling frequency which is fast enough so that tests finish
+				// quickly but not so frequent such that Go struggles to schedule the
+				// goroutines of the tests on slower machines.
+				PollFrequency: 50 * time.Millisecond,
+				Debounce:      10 * time.Millisecond,
+			})
+			require.NoError(t, err)
+		}()
+
+		// Swallow the initial exports notification.
+		require.NoError(t, tc.WaitExports(time.Second))
+		return tc
+	}
+
+	t.Run("Key/value files are parsed and updates detected", func(t *testing.T) {
+		testFile := filepath.Join(t.TempDir(), "secrets.env")
+		writeFile(t, testFile, "# credentials\nusername=agent\npassword = \"hunter2\"\n")
+
+		sc := newSuiteController(t, testFile, "key_value")
+		require.Equal(t, secrets.Exports{
+			Data: map[string]rivertypes.Secret{
+				"username": "agent",
+				"password": "hunter2",
+			},
+		}, sc.Exports())
+
+		writeFile(t, testFile, "username=agent\npassword=hunter3\n")
+
+		require.NoError(t, sc.WaitExports(time.Second))
+		require.Equal(t, secrets.Exports{
+			Data: map[string]rivertypes.Secret{
+				"username": "agent",
+				"password": "hunter3",
+			},
+		}, sc.Exports())
+	})
+
+	t.Run("JSON files are parsed and updates detected", func(t *testing.T) {
+		testFile := filepath.Join(t.TempDir(), "secrets.json")
+		writeFile(t, testFile, `{"username": "agent", "port": 5432}`)
+
+		sc := newSuiteController(t, testFile, "json")
+		require.Equal(t, secrets.Exports{
+			Data: map[string]rivertypes.Secret{
+				"username": "agent",
+				"port":     "5432",
+			},
+		}, sc.Exports())
+
+		writeFile(t, testFile, `{"username": "agent", "port": 5433}`)
+
+		require.NoError(t, sc.WaitExports(time.Second))
+		require.Equal(t, secrets.Exports{
+			Data: map[string]rivertypes.Secret{
+				"username": "agent",
+				"port":     "5433",
+			},
+		}, sc.Exports())
+	})
+
+	t.Run("Directories are read and updates detected", func(t *testing.T) {
+		testDir := t.TempDir()
+		writeFile(t, filepath.Join(testDir, "username"), "agent")
+		writeFile(t, filepath.Join(testDir, ".hidden"), "ignored")
+
+		sc := newSuiteController(t, testDir, "key_value")
+		require.Equal(t, secrets.Exports{
+			Data: map[string]rivertypes.Secret{
+				"username": "agent",
+			},
+		}, sc.Exports())
+
+		writeFile(t, filepath.Join(testDir, "password"), "hunter2")
+
+		require.NoError(t, sc.WaitExports(time.Second))
+		require.Equal(t, secrets.Exports{
+			Data: map[string]rivertypes.Secret{
+				"username": "agent",
+				"password": "hunter2",
+			},
+		}, sc.Exports())
+	})
+}
+
+func TestArguments(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         string
+		expectedErr string
+	}{
+		{
+			name: "valid",
+			cfg: `
+				path     = "/etc/agent/secrets.json"
+				format   = "json"
+				detector = "poll"
+			`,
+		},
+		{
+			name: "invalid format",
+			cfg: `
+				path   = "/etc/agent/secrets.yaml"
+				format = "yaml"
+			`,
+			expectedErr: `unrecognized format "yaml", expected one of key_value,json`,
+		},
+		{
+			name: "negative debounce",
+			cfg: `
+				path     = "/etc/agent/secrets"
+				debounce = "-1s"
+			`,
+			expectedErr: "debounce must not be negative",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var args secrets.Arguments
+			err := river.Unmarshal([]byte(tc.cfg), &args)
+			if tc.expectedErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+}
@@ -198,3 +198,21 @@ func registerCounterVec(registerer prometheus.Registerer, namespace, name, help
 	}
 	return vec
 }
+
+func registerGaugeVec(registerer prometheus.Registerer, namespace, name, help string, labels []string) *prometheus.GaugeVec {
+	vec := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      name,
+		Help:      help,
+	}, labels)
+	err := registerer.Register(vec)
+	if err != nil {
+		if existing, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			vec = existing.ExistingCollector.(*prometheus.GaugeVec)
+		} else {
+			// Same behavior as MustRegister if the error is not for AlreadyRegistered
+			panic(err)
+		}
+	}
+	return vec
+}
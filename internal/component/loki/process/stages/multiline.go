@@ -8,10 +8,12 @@ import (
 	"sync"
 	"time"
 
+	"github.com/alecthomas/units"
 	"github.com/go-kit/log"
 	"github.com/grafana/agent/internal/component/common/loki"
 	"github.com/grafana/agent/internal/flow/logging/level"
 	"github.com/grafana/loki/pkg/logproto"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/model"
 )
 
@@ -24,10 +26,11 @@ var (
 
 // MultilineConfig contains the configuration for a Multiline stage.
 type MultilineConfig struct {
-	Expression  string        `river:"firstline,attr"`
-	MaxLines    uint64        `river:"max_lines,attr,optional"`
-	MaxWaitTime time.Duration `river:"max_wait_time,attr,optional"`
-	regex       *regexp.Regexp
+	Expression       string           `river:"firstline,attr"`
+	MaxLines         uint64           `river:"max_lines,attr,optional"`
+	MaxWaitTime      time.Duration    `river:"max_wait_time,attr,optional"`
+	MaxBufferedBytes units.Base2Bytes `river:"max_buffered_bytes,attr,optional"`
+	regex            *regexp.Regexp
 }
 
 // DefaultMultilineConfig applies the default values on
@@ -46,6 +49,9 @@ func (args *MultilineConfig) Validate() error {
 	if args.MaxWaitTime <= 0 {
 		return fmt.Errorf("max_wait_time must be greater than 0")
 	}
+	if args.MaxBufferedBytes < 0 {
+		return fmt.Errorf("max_buffered_bytes must not be negative")
+	}
 
 	return nil
 }
@@ -64,10 +70,66 @@ func validateMultilineConfig(cfg *MultilineConfig) error {
 	return nil
 }
 
+// Reasons for flushing a multiline block.
+const (
+	multilineFlushFirstLine        = "firstline"
+	multilineFlushMaxLines         = "max_lines"
+	multilineFlushMaxWaitTime      = "max_wait_time"
+	multilineFlushMaxBufferedBytes = "max_buffered_bytes"
+)
+
 // multilineStage matches lines to determine whether the following lines belong to a block and should be collapsed
 type multilineStage struct {
-	logger log.Logger
-	cfg    MultilineConfig
+	logger  log.Logger
+	cfg     MultilineConfig
+	metrics *multilineMetrics // nil if metrics aren't collected.
+
+	bufferedMut sync.Mutex
+	buffered    int  // Number of bytes buffered by all the streams of the stage.
+	cleanedUp   bool // Whether buffered bytes are no longer accounted.
+}
+
+// multilineMetrics account for the memory buffered by a multiline stage.
+type multilineMetrics struct {
+	bufferedBytes prometheus.Gauge
+	flushes       *prometheus.CounterVec
+}
+
+func newMultilineMetrics(registerer prometheus.Registerer) *multilineMetrics {
+	return &multilineMetrics{
+		bufferedBytes: registerGaugeVec(registerer, "loki_process", "multiline_buffered_bytes",
+			"Number of bytes buffered for the pending multiline blocks of all streams",
+			nil).WithLabelValues(),
+		flushes: registerCounterVec(registerer, "loki_process", "multiline_flushes_total",
+			"A count of all multiline blocks flushed, partitioned by the reason for flushing them",
+			[]string{"reason"}),
+	}
+}
+
+func (m *multilineMetrics) countFlush(reason string) {
+	if m == nil {
+		return
+	}
+	m.flushes.WithLabelValues(reason).Inc()
+}
+
+// setBuffered records that bytes are buffered for the stream of s, adding the
+// difference with the bytes previously recorded for it to the total of the
+// stage.
+func (m *multilineStage) setBuffered(s *multilineState, bytes int) {
+	delta := bytes - s.bufferedBytes
+	s.bufferedBytes = bytes
+	if m.metrics == nil || delta == 0 {
+		return
+	}
+
+	m.bufferedMut.Lock()
+	defer m.bufferedMut.Unlock()
+	if m.cleanedUp {
+		return
+	}
+	m.buffered += delta
+	m.metrics.bufferedBytes.Add(float64(delta))
 }
 
 // multilineState captures the internal state of a running multiline stage.
@@ -75,18 +137,20 @@ type multilineState struct {
 	buffer         *bytes.Buffer // The lines of the current multiline block.
 	startLineEntry Entry         // The entry of the start line of a multiline block.
 	currentLines   uint64        // The number of lines of the current multiline block.
+	bufferedBytes  int           // The number of bytes recorded in the buffered bytes metric.
 }
 
 // newMultilineStage creates a MulitlineStage from config
-func newMultilineStage(logger log.Logger, config MultilineConfig) (Stage, error) {
+func newMultilineStage(logger log.Logger, config MultilineConfig, registerer prometheus.Registerer) (Stage, error) {
 	err := validateMultilineConfig(&config)
 	if err != nil {
 		return nil, err
 	}
 
 	return &multilineStage{
-		logger: log.With(logger, "component", "stage", "type", "multiline"),
-		cfg:    config,
+		logger:  log.With(logger, "component", "stage", "type", "multiline"),
+		cfg:     config,
+		metrics: newMultilineMetrics(registerer),
 	}, nil
 }
 
@@ -141,20 +205,20 @@ func (m *multilineStage) runMultiline(in chan Entry, out chan Entry, wg *sync.Wa
 		select {
 		case <-time.After(m.cfg.MaxWaitTime):
 			level.Debug(m.logger).Log("msg", fmt.Sprintf("flush multiline block due to %v timeout", m.cfg.MaxWaitTime), "block", state.buffer.String())
-			m.flush(out, state)
+			m.flush(out, state, multilineFlushMaxWaitTime)
 		case e, ok := <-in:
 			level.Debug(m.logger).Log("msg", "processing line", "line", e.Line, "stream", e.Labels.FastFingerprint())
 
 			if !ok {
 				level.Debug(m.logger).Log("msg", "flush multiline block because inbound closed", "block", state.buffer.String(), "stream", e.Labels.FastFingerprint())
-				m.flush(out, state)
+				m.flush(out, state, "")
 				return
 			}
 
 			isFirstLine := m.cfg.regex.MatchString(e.Line)
 			if isFirstLine {
 				level.Debug(m.logger).Log("msg", "flush multiline block because new start line", "block", state.buffer.String(), "stream", e.Labels.FastFingerprint())
-				m.flush(out, state)
+				m.flush(out, state, multilineFlushFirstLine)
 
 				// The start line entry is used to set timestamp and labels in the flush method.
 				// The timestamps for following lines are ignored for now.
@@ -168,14 +232,22 @@ func (m *multilineStage) runMultiline(in chan Entry, out chan Entry, wg *sync.Wa
 			state.buffer.WriteString(e.Line)
 			state.currentLines++
 
-			if state.currentLines == m.cfg.MaxLines {
-				m.flush(out, state)
+			switch {
+			case state.currentLines == m.cfg.MaxLines:
+				m.flush(out, state, multilineFlushMaxLines)
+			case m.cfg.MaxBufferedBytes > 0 && state.buffer.Len() >= int(m.cfg.MaxBufferedBytes):
+				level.Debug(m.logger).Log("msg", "flush multiline block because max_buffered_bytes was reached", "buffered_bytes", state.buffer.Len(), "stream", e.Labels.FastFingerprint())
+				m.flush(out, state, multilineFlushMaxBufferedBytes)
+			default:
+				m.setBuffered(state, state.buffer.Len())
 			}
 		}
 	}
 }
 
-func (m *multilineStage) flush(out chan Entry, s *multilineState) {
+// flush sends the pending block, if any. reason is counted in the flushes
+// metric unless it's empty.
+func (m *multilineStage) flush(out chan Entry, s *multilineState, reason string) {
 	if s.buffer.Len() == 0 {
 		level.Debug(m.logger).Log("msg", "nothing to flush", "buffer_len", s.buffer.Len())
 		return
@@ -198,6 +270,11 @@ func (m *multilineStage) flush(out chan Entry, s *multilineState) {
 	s.buffer.Reset()
 	s.currentLines = 0
 
+	m.setBuffered(s, 0)
+	if reason != "" {
+		m.metrics.countFlush(reason)
+	}
+
 	out <- collapsed
 }
 
@@ -206,7 +283,16 @@ func (m *multilineStage) Name() string {
 	return StageTypeMultiline
 }
 
-// Cleanup implements Stage.
-func (*multilineStage) Cleanup() {
-	// no-op
+// Cleanup implements Stage. The bytes still buffered by the stage are removed
+// from the buffered bytes metric.
+func (m *multilineStage) Cleanup() {
+	if m.metrics == nil {
+		return
+	}
+
+	m.bufferedMut.Lock()
+	defer m.bufferedMut.Unlock()
+	m.metrics.bufferedBytes.Sub(float64(m.buffered))
+	m.buffered = 0
+	m.cleanedUp = true
 }
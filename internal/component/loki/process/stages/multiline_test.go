@@ -2,6 +2,7 @@ package stages
 
 import (
 	"sort"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -9,6 +10,8 @@ import (
 	"github.com/grafana/agent/internal/component/common/loki"
 	"github.com/grafana/agent/internal/util"
 	"github.com/grafana/loki/pkg/logproto"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/prometheus/common/model"
 	"github.com/stretchr/testify/require"
 )
@@ -124,6 +127,116 @@ func TestMultilineStageMaxWaitTime(t *testing.T) {
 	require.Equal(t, "not a start line hitting timeout", res[1].Line)
 }
 
+func TestMultilineStageStackTraceMaxLines(t *testing.T) {
+	logger := util.TestFlowLogger(t)
+	mcfg := MultilineConfig{Expression: `^\d{4}-\d{2}-\d{2}`, MaxWaitTime: 3 * time.Second, MaxLines: 3}
+	err := validateMultilineConfig(&mcfg)
+	require.NoError(t, err)
+
+	stage := &multilineStage{
+		cfg:    mcfg,
+		logger: logger,
+	}
+
+	out := processEntries(stage,
+		simpleEntry("2024-01-02 10:00:00 ERROR request failed", "label"),
+		simpleEntry("java.lang.IllegalStateException: boom", "label"),
+		simpleEntry("\tat com.example.Handler.handle(Handler.java:42)", "label"),
+		simpleEntry("\tat com.example.Server.run(Server.java:7)", "label"),
+		simpleEntry("2024-01-02 10:00:01 INFO recovered", "label"))
+
+	// The stack trace is cut after max_lines lines, and the remaining lines
+	// start a new block.
+	require.Len(t, out, 3)
+	require.Equal(t, "2024-01-02 10:00:00 ERROR request failed\njava.lang.IllegalStateException: boom\n\tat com.example.Handler.handle(Handler.java:42)", out[0].Line)
+	require.Equal(t, "\tat com.example.Server.run(Server.java:7)", out[1].Line)
+	require.Equal(t, "2024-01-02 10:00:01 INFO recovered", out[2].Line)
+}
+
+func TestMultilineStageMaxBufferedBytes(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	stage, err := newMultilineStage(util.TestFlowLogger(t), MultilineConfig{
+		Expression:       "^START",
+		MaxLines:         128,
+		MaxWaitTime:      time.Minute,
+		MaxBufferedBytes: 64,
+	}, reg)
+	require.NoError(t, err)
+
+	var (
+		in  = make(chan Entry)
+		out = stage.Run(in)
+
+		line = strings.Repeat("x", 30)
+	)
+
+	// Lines are buffered and accounted until the block reaches
+	// max_buffered_bytes.
+	in <- simpleEntry("START", "label")
+	in <- simpleEntry(line, "label")
+	buffered := stage.(*multilineStage).metrics.bufferedBytes
+	require.Eventually(t, func() bool {
+		return testutil.ToFloat64(buffered) == 36
+	}, 5*time.Second, 10*time.Millisecond)
+
+	in <- simpleEntry(line, "label")
+	flushed := <-out
+	require.Equal(t, "START\n"+line+"\n"+line, flushed.Line)
+
+	// The remaining lines start a new block, which is flushed when the input
+	// is closed.
+	in <- simpleEntry(line, "label")
+	close(in)
+	flushed = <-out
+	require.Equal(t, line, flushed.Line)
+	_, ok := <-out
+	require.False(t, ok)
+
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP loki_process_multiline_buffered_bytes Number of bytes buffered for the pending multiline blocks of all streams
+# TYPE loki_process_multiline_buffered_bytes gauge
+loki_process_multiline_buffered_bytes 0
+# HELP loki_process_multiline_flushes_total A count of all multiline blocks flushed, partitioned by the reason for flushing them
+# TYPE loki_process_multiline_flushes_total counter
+loki_process_multiline_flushes_total{reason="max_buffered_bytes"} 1
+`)))
+}
+
+func TestMultilineStageCleanup(t *testing.T) {
+	stage, err := newMultilineStage(util.TestFlowLogger(t), MultilineConfig{
+		Expression:  "^START",
+		MaxLines:    128,
+		MaxWaitTime: time.Minute,
+	}, prometheus.NewRegistry())
+	require.NoError(t, err)
+
+	var (
+		in  = make(chan Entry)
+		out = stage.Run(in)
+	)
+
+	// The bytes buffered by every stream are added up.
+	in <- simpleEntry("START a", "a")
+	in <- simpleEntry("START b", "b")
+	buffered := stage.(*multilineStage).metrics.bufferedBytes
+	require.Eventually(t, func() bool {
+		return testutil.ToFloat64(buffered) == 14
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Cleaning up the stage removes its buffered bytes, even if its blocks are
+	// flushed later.
+	stage.Cleanup()
+	require.Equal(t, 0.0, testutil.ToFloat64(buffered))
+
+	close(in)
+	// Both blocks are flushed when the input is closed.
+	<-out
+	<-out
+	_, ok := <-out
+	require.False(t, ok)
+	require.Equal(t, 0.0, testutil.ToFloat64(buffered))
+}
+
 func simpleEntry(line, label string) Entry {
 	// We're adding a small wait time here, because on Windows, timers have a
 	// smaller resolution than on Linux. This can mess with the ordering of log
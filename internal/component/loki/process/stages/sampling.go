@@ -4,15 +4,19 @@ import (
 	"fmt"
 	"math"
 	"math/rand"
+	"reflect"
 	"time"
 
+	"github.com/cespare/xxhash/v2"
 	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/uber/jaeger-client-go/utils"
 )
 
 const (
 	ErrSamplingStageInvalidRate = "sampling stage failed to parse rate,Sampling Rate must be between 0.0 and 1.0, received %f"
+	ErrSamplingStageSource      = "sampling stage source can only be set when deterministic is true"
 )
 const maxRandomNumber = ^(uint64(1) << 63) // i.e. 0x7fffffffffffffff
 
@@ -24,6 +28,12 @@ var (
 type SamplingConfig struct {
 	DropReason   *string `river:"drop_counter_reason,attr,optional"`
 	SamplingRate float64 `river:"rate,attr"`
+
+	// Deterministic makes the sampling decision depend on a hash of the log
+	// line (or of the extracted value named by Source) instead of a random
+	// number, so identical values are always either kept or dropped.
+	Deterministic bool   `river:"deterministic,attr,optional"`
+	Source        string `river:"source,attr,optional"`
 }
 
 func (s *SamplingConfig) SetToDefault() {
@@ -36,6 +46,9 @@ func (s *SamplingConfig) Validate() error {
 	if s.SamplingRate < 0.0 || s.SamplingRate > 1.0 {
 		return fmt.Errorf(ErrSamplingStageInvalidRate, s.SamplingRate)
 	}
+	if s.Source != "" && !s.Deterministic {
+		return fmt.Errorf(ErrSamplingStageSource)
+	}
 	return nil
 }
 
@@ -51,6 +64,7 @@ func newSamplingStage(logger log.Logger, cfg SamplingConfig, registerer promethe
 		logger:           log.With(logger, "component", "stage", "type", "sampling"),
 		cfg:              cfg,
 		dropCount:        getDropCountMetric(registerer),
+		sampledCount:     getSampledCountMetric(registerer),
 		samplingBoundary: samplingBoundary,
 		source:           source,
 	}
@@ -60,6 +74,7 @@ type samplingStage struct {
 	logger           log.Logger
 	cfg              SamplingConfig
 	dropCount        *prometheus.CounterVec
+	sampledCount     *prometheus.CounterVec
 	samplingBoundary uint64
 	source           rand.Source
 }
@@ -69,16 +84,45 @@ func (m *samplingStage) Run(in chan Entry) chan Entry {
 	go func() {
 		defer close(out)
 		for e := range in {
-			if m.isSampled() {
+			if m.keep(e) {
+				m.sampledCount.WithLabelValues("kept").Inc()
 				out <- e
 				continue
 			}
+			m.sampledCount.WithLabelValues("dropped").Inc()
 			m.dropCount.WithLabelValues(*m.cfg.DropReason).Inc()
 		}
 	}()
 	return out
 }
 
+// keep reports whether the entry should be forwarded.
+func (m *samplingStage) keep(e Entry) bool {
+	if !m.cfg.Deterministic {
+		return m.isSampled()
+	}
+
+	value := e.Line
+	if m.cfg.Source != "" {
+		v, ok := e.Extracted[m.cfg.Source]
+		if !ok {
+			if Debug {
+				level.Debug(m.logger).Log("msg", "source does not exist in the set of extracted values", "source", m.cfg.Source)
+			}
+			return m.isSampled()
+		}
+		s, err := getString(v)
+		if err != nil {
+			if Debug {
+				level.Debug(m.logger).Log("msg", "failed to convert source value to string", "source", m.cfg.Source, "err", err, "type", reflect.TypeOf(v))
+			}
+			return m.isSampled()
+		}
+		value = s
+	}
+	return m.samplingBoundary >= xxhash.Sum64String(value)&maxRandomNumber
+}
+
 // code from jaeger project.
 // github.com/uber/jaeger-client-go@v2.30.0+incompatible/sampler.go:144
 // func (s *ProbabilisticSampler) IsSampled(id TraceID, operation string) (bool, []Tag)
@@ -105,3 +149,20 @@ func (m *samplingStage) Name() string {
 func (*samplingStage) Cleanup() {
 	// no-op
 }
+
+func getSampledCountMetric(registerer prometheus.Registerer) *prometheus.CounterVec {
+	sampledCount := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "loki_process_sampled_lines_total",
+		Help: "A count of all log lines processed by a sampling stage, partitioned by whether they were kept or dropped",
+	}, []string{"decision"})
+	err := registerer.Register(sampledCount)
+	if err != nil {
+		if existing, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			sampledCount = existing.ExistingCollector.(*prometheus.CounterVec)
+		} else {
+			// Same behavior as MustRegister if the error is not for AlreadyRegistered
+			panic(err)
+		}
+	}
+	return sampledCount
+}
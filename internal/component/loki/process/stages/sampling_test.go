@@ -2,10 +2,12 @@ package stages
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -37,6 +39,72 @@ func TestSamplingPipeline(t *testing.T) {
 	assert.LessOrEqual(t, len(out), 70)
 }
 
+var testDeterministicSamplingRiver = `
+stage.sampling {
+  rate          = 0.25
+  deterministic = true
+}
+`
+
+func TestDeterministicSamplingPipeline(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	pl, err := NewPipeline(util_log.Logger, loadConfig(testDeterministicSamplingRiver), &plName, registry)
+	require.NoError(t, err)
+
+	entries := make([]Entry, 0, 10000)
+	for i := 0; i < 10000; i++ {
+		entries = append(entries, newEntry(nil, nil, fmt.Sprintf("debug line %d", i), time.Now()))
+	}
+
+	// The same lines must always lead to the same decision.
+	first := processEntries(pl, entries...)
+	second := processEntries(pl, entries...)
+	require.Equal(t, len(first), len(second))
+	for i := range first {
+		require.Equal(t, first[i].Line, second[i].Line)
+	}
+
+	// sampling rate = 0.25, entries len = 10000,
+	// The theoretical sample size is 2500.
+	assert.GreaterOrEqual(t, len(first), 2300)
+	assert.LessOrEqual(t, len(first), 2700)
+
+	expected := fmt.Sprintf(`
+# HELP loki_process_sampled_lines_total A count of all log lines processed by a sampling stage, partitioned by whether they were kept or dropped
+# TYPE loki_process_sampled_lines_total counter
+loki_process_sampled_lines_total{decision="dropped"} %d
+loki_process_sampled_lines_total{decision="kept"} %d
+`, 2*(len(entries)-len(first)), 2*len(first))
+	require.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected), "loki_process_sampled_lines_total"))
+}
+
+var testDeterministicSamplingSourceRiver = `
+stage.json {
+  expressions = { trace_id = "" }
+}
+stage.sampling {
+  rate          = 0.5
+  deterministic = true
+  source        = "trace_id"
+}
+`
+
+func TestDeterministicSamplingSource(t *testing.T) {
+	pl, err := NewPipeline(util_log.Logger, loadConfig(testDeterministicSamplingSourceRiver), &plName, prometheus.NewRegistry())
+	require.NoError(t, err)
+
+	// Every line of a trace gets the same decision, regardless of its content.
+	for i := 0; i < 20; i++ {
+		entries := make([]Entry, 0, 10)
+		for j := 0; j < 10; j++ {
+			line := fmt.Sprintf(`{"trace_id":"%d","msg":"step %d"}`, i, j)
+			entries = append(entries, newEntry(nil, nil, line, time.Now()))
+		}
+		out := processEntries(pl, entries...)
+		require.Contains(t, []int{0, len(entries)}, len(out))
+	}
+}
+
 func Test_validateSamplingConfig(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -50,6 +118,14 @@ func Test_validateSamplingConfig(t *testing.T) {
 			},
 			wantErr: fmt.Errorf(ErrSamplingStageInvalidRate, 12.0),
 		},
+		{
+			name: "Source without deterministic",
+			config: &SamplingConfig{
+				SamplingRate: 0.5,
+				Source:       "trace_id",
+			},
+			wantErr: fmt.Errorf(ErrSamplingStageSource),
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
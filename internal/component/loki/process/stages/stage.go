@@ -204,7 +204,7 @@ func New(logger log.Logger, jobName *string, cfg StageConfig, registerer prometh
 			return nil, err
 		}
 	case cfg.MultilineConfig != nil:
-		s, err = newMultilineStage(logger, *cfg.MultilineConfig)
+		s, err = newMultilineStage(logger, *cfg.MultilineConfig, registerer)
 		if err != nil {
 			return nil, err
 		}
@@ -11,6 +11,7 @@ type metrics struct {
 	cacheHits        prometheus_client.Counter
 	cacheMisses      prometheus_client.Counter
 	cacheSize        prometheus_client.Gauge
+	labelsLimited    *prometheus_client.CounterVec
 }
 
 // newMetrics creates a new set of metrics. If reg is non-nil, the metrics
@@ -38,6 +39,10 @@ func newMetrics(reg prometheus.Registerer) *metrics {
 		Name: "loki_relabel_cache_size",
 		Help: "Total size of relabel cache",
 	})
+	m.labelsLimited = prometheus_client.NewCounterVec(prometheus_client.CounterOpts{
+		Name: "loki_relabel_labels_limited_total",
+		Help: "Total number of labels dropped or truncated by the label limits",
+	}, []string{"limit"})
 
 	if reg != nil {
 		reg.MustRegister(
@@ -46,6 +51,7 @@ func newMetrics(reg prometheus.Registerer) *metrics {
 			m.cacheMisses,
 			m.cacheHits,
 			m.cacheSize,
+			m.labelsLimited,
 		)
 	}
 
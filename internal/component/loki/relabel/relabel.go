@@ -3,7 +3,9 @@ package relabel
 import (
 	"context"
 	"reflect"
+	"sort"
 	"sync"
+	"unicode/utf8"
 
 	"github.com/grafana/agent/internal/component"
 	"github.com/grafana/agent/internal/component/common/loki"
@@ -39,6 +41,12 @@ type Arguments struct {
 
 	// The maximum number of items to hold in the component's LRU cache.
 	MaxCacheSize int `river:"max_cache_size,attr,optional"`
+
+	// Limits applied to the labels of each log entry after relabeling. A
+	// value of zero means no limit.
+	MaxLabelNames       uint `river:"max_label_names,attr,optional"`
+	MaxLabelNameLength  uint `river:"max_label_name_length,attr,optional"`
+	MaxLabelValueLength uint `river:"max_label_value_length,attr,optional"`
 }
 
 // DefaultArguments provides the default arguments for the loki.relabel
@@ -70,6 +78,15 @@ type Component struct {
 
 	cache        *lru.Cache
 	maxCacheSize int
+
+	limits labelLimits
+}
+
+// labelLimits holds the label limits of the component.
+type labelLimits struct {
+	maxNames       int
+	maxNameLength  int
+	maxValueLength int
 }
 
 var (
@@ -111,7 +128,7 @@ func (c *Component) Run(ctx context.Context) error {
 			return nil
 		case entry := <-c.receiver.Chan():
 			c.metrics.entriesProcessed.Inc()
-			lbls := c.relabel(entry)
+			lbls := c.limitLabels(c.relabel(entry))
 			if len(lbls) == 0 {
 				level.Debug(c.opts.Logger).Log("msg", "dropping entry after relabeling", "labels", entry.Labels.String())
 				continue
@@ -150,6 +167,11 @@ func (c *Component) Update(args component.Arguments) error {
 	}
 	c.rcs = newRCS
 	c.fanout = newArgs.ForwardTo
+	c.limits = labelLimits{
+		maxNames:       int(newArgs.MaxLabelNames),
+		maxNameLength:  int(newArgs.MaxLabelNameLength),
+		maxValueLength: int(newArgs.MaxLabelValueLength),
+	}
 
 	c.opts.OnStateChange(Exports{Receiver: c.receiver, Rules: newArgs.RelabelConfigs})
 
@@ -228,3 +250,73 @@ func (c *Component) process(e loki.Entry) model.LabelSet {
 	}
 	return relabeled
 }
+
+// limitLabels applies the label limits to the relabeled labels of an entry.
+// Labels with a name longer than max_label_name_length are dropped, values
+// longer than max_label_value_length are truncated, and labels beyond
+// max_label_names are dropped, keeping the first labels in lexicographical
+// order. lbls isn't modified, as it may be shared with the cache.
+func (c *Component) limitLabels(lbls model.LabelSet) model.LabelSet {
+	c.mut.RLock()
+	limits := c.limits
+	c.mut.RUnlock()
+
+	if !limits.exceeded(lbls) {
+		return lbls
+	}
+
+	names := make([]string, 0, len(lbls))
+	for name := range lbls {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+
+	limited := make(model.LabelSet, len(lbls))
+	for _, name := range names {
+		value := string(lbls[model.LabelName(name)])
+		if limits.maxNameLength > 0 && len(name) > limits.maxNameLength {
+			c.metrics.labelsLimited.WithLabelValues("max_label_name_length").Inc()
+			continue
+		}
+		if limits.maxNames > 0 && len(limited) >= limits.maxNames {
+			c.metrics.labelsLimited.WithLabelValues("max_label_names").Inc()
+			continue
+		}
+		if limits.maxValueLength > 0 && len(value) > limits.maxValueLength {
+			c.metrics.labelsLimited.WithLabelValues("max_label_value_length").Inc()
+			value = truncate(value, limits.maxValueLength)
+		}
+		limited[model.LabelName(name)] = model.LabelValue(value)
+	}
+
+	level.Debug(c.opts.Logger).Log("msg", "limited labels of entry", "labels", lbls.String(), "limited", limited.String())
+	return limited
+}
+
+// exceeded reports whether lbls exceeds any of the limits.
+func (l labelLimits) exceeded(lbls model.LabelSet) bool {
+	if l.maxNames > 0 && len(lbls) > l.maxNames {
+		return true
+	}
+	if l.maxNameLength == 0 && l.maxValueLength == 0 {
+		return false
+	}
+	for name, value := range lbls {
+		if l.maxNameLength > 0 && len(name) > l.maxNameLength {
+			return true
+		}
+		if l.maxValueLength > 0 && len(value) > l.maxValueLength {
+			return true
+		}
+	}
+	return false
+}
+
+// truncate truncates s to at most n bytes without splitting a UTF-8 encoded
+// rune.
+func truncate(s string, n int) string {
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -17,6 +18,7 @@ import (
 	"github.com/grafana/loki/pkg/logproto"
 	"github.com/grafana/river"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/relabel"
 
@@ -425,6 +427,105 @@ func TestRuleGetter(t *testing.T) {
 	require.Equal(t, gotUpdated[0].Regex, gotOriginal[0].Regex)
 }
 
+func TestLabelLimits(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        Arguments
+		labels      model.LabelSet
+		want        model.LabelSet
+		wantMetrics string
+	}{
+		{
+			name: "max_label_names",
+			args: Arguments{MaxLabelNames: 2},
+			labels: model.LabelSet{
+				"app": "agent", "env": "dev", "pod": "agent-0",
+			},
+			want: model.LabelSet{
+				"app": "agent", "env": "dev",
+			},
+			wantMetrics: `
+				# HELP loki_relabel_labels_limited_total Total number of labels dropped or truncated by the label limits
+				# TYPE loki_relabel_labels_limited_total counter
+				loki_relabel_labels_limited_total{limit="max_label_names"} 1
+			`,
+		},
+		{
+			name: "max_label_name_length",
+			args: Arguments{MaxLabelNameLength: 5},
+			labels: model.LabelSet{
+				"app": "agent", "namespace": "dev",
+			},
+			want: model.LabelSet{
+				"app": "agent",
+			},
+			wantMetrics: `
+				# HELP loki_relabel_labels_limited_total Total number of labels dropped or truncated by the label limits
+				# TYPE loki_relabel_labels_limited_total counter
+				loki_relabel_labels_limited_total{limit="max_label_name_length"} 1
+			`,
+		},
+		{
+			name: "max_label_value_length",
+			args: Arguments{MaxLabelValueLength: 4},
+			labels: model.LabelSet{
+				"app": "agent", "env": "dev", "city": "zürich",
+			},
+			want: model.LabelSet{
+				"app": "agen", "env": "dev", "city": "zür",
+			},
+			wantMetrics: `
+				# HELP loki_relabel_labels_limited_total Total number of labels dropped or truncated by the label limits
+				# TYPE loki_relabel_labels_limited_total counter
+				loki_relabel_labels_limited_total{limit="max_label_value_length"} 2
+			`,
+		},
+		{
+			name: "within limits",
+			args: Arguments{MaxLabelNames: 2, MaxLabelNameLength: 3, MaxLabelValueLength: 5},
+			labels: model.LabelSet{
+				"app": "agent", "env": "dev",
+			},
+			want: model.LabelSet{
+				"app": "agent", "env": "dev",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ch := loki.NewLogsReceiver()
+			reg := prometheus.NewRegistry()
+			opts := component.Options{
+				Logger:        util.TestFlowLogger(t),
+				Registerer:    reg,
+				OnStateChange: func(e component.Exports) {},
+			}
+			args := tc.args
+			args.ForwardTo = []loki.LogsReceiver{ch}
+			args.MaxCacheSize = DefaultArguments.MaxCacheSize
+
+			c, err := New(opts, args)
+			require.NoError(t, err)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go c.Run(ctx)
+
+			entry := getEntry()
+			entry.Labels = tc.labels
+			c.receiver.Chan() <- entry
+
+			select {
+			case got := <-ch.Chan():
+				require.Equal(t, tc.want, got.Labels)
+			case <-time.After(5 * time.Second):
+				require.FailNow(t, "failed waiting for log line")
+			}
+			require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(tc.wantMetrics), "loki_relabel_labels_limited_total"))
+		})
+	}
+}
+
 func getEntry() loki.Entry {
 	return loki.Entry{
 		Labels: model.LabelSet{},
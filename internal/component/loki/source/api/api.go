@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"reflect"
 	"sync"
+	"time"
 
 	"github.com/grafana/agent/internal/component"
 	"github.com/grafana/agent/internal/component/common/loki"
@@ -13,6 +14,7 @@ import (
 	"github.com/grafana/agent/internal/component/loki/source/api/internal/lokipush"
 	"github.com/grafana/agent/internal/featuregate"
 	"github.com/grafana/agent/internal/util"
+	"github.com/grafana/river/rivertypes"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/model"
 )
@@ -34,12 +36,68 @@ type Arguments struct {
 	Labels               map[string]string   `river:"labels,attr,optional"`
 	RelabelRules         relabel.Rules       `river:"relabel_rules,attr,optional"`
 	UseIncomingTimestamp bool                `river:"use_incoming_timestamp,attr,optional"`
+	UseIncomingTenant    bool                `river:"use_incoming_tenant,attr,optional"`
+	RequireTenant        bool                `river:"require_tenant,attr,optional"`
+	DefaultTenant        string              `river:"default_tenant,attr,optional"`
+	BearerToken          rivertypes.Secret   `river:"bearer_token,attr,optional"`
+	ForwardTimeout       time.Duration       `river:"forward_timeout,attr,optional"`
+	ForwardMaxRetries    int                 `river:"forward_max_retries,attr,optional"`
+	DedupWindow          time.Duration       `river:"dedup_window,attr,optional"`
+	BasicAuth            *BasicAuth          `river:"basic_auth,block,optional"`
+}
+
+// BasicAuth configures the basic auth credentials push requests must send.
+type BasicAuth struct {
+	Username string            `river:"username,attr"`
+	Password rivertypes.Secret `river:"password,attr"`
 }
 
 // SetToDefault implements river.Defaulter.
 func (a *Arguments) SetToDefault() {
 	*a = Arguments{
-		Server: fnet.DefaultServerConfig(),
+		Server:            fnet.DefaultServerConfig(),
+		ForwardMaxRetries: 3,
+	}
+}
+
+// Validate implements river.Validator.
+func (a *Arguments) Validate() error {
+	if !a.UseIncomingTenant && (a.RequireTenant || a.DefaultTenant != "") {
+		return fmt.Errorf("require_tenant and default_tenant can only be used with use_incoming_tenant")
+	} else if a.RequireTenant && a.DefaultTenant != "" {
+		return fmt.Errorf("require_tenant and default_tenant can't be used together")
+	}
+	if a.BearerToken != "" && a.BasicAuth != nil {
+		return fmt.Errorf("bearer_token and basic_auth can't be used together")
+	} else if a.BasicAuth != nil && a.BasicAuth.Username == "" {
+		return fmt.Errorf("basic_auth username must not be empty")
+	}
+	if a.ForwardTimeout < 0 {
+		return fmt.Errorf("forward_timeout must not be negative")
+	}
+	if a.ForwardMaxRetries < 0 {
+		return fmt.Errorf("forward_max_retries must not be negative")
+	}
+	if a.DedupWindow < 0 {
+		return fmt.Errorf("dedup_window must not be negative")
+	}
+	return nil
+}
+
+func (a *Arguments) authConfig() lokipush.AuthConfig {
+	cfg := lokipush.AuthConfig{BearerToken: string(a.BearerToken)}
+	if a.BasicAuth != nil {
+		cfg.Username = a.BasicAuth.Username
+		cfg.Password = string(a.BasicAuth.Password)
+	}
+	return cfg
+}
+
+func (a *Arguments) tenantConfig() lokipush.TenantConfig {
+	return lokipush.TenantConfig{
+		UseIncoming: a.UseIncomingTenant,
+		Required:    a.RequireTenant,
+		Default:     a.DefaultTenant,
 	}
 }
 
@@ -146,6 +204,8 @@ func (c *Component) Update(args component.Arguments) error {
 		if err != nil {
 			return fmt.Errorf("failed to create embedded server: %v", err)
 		}
+		// Authentication must be configured before the server accepts requests.
+		c.server.SetAuthConfig(newArgs.authConfig())
 		err = c.server.Run()
 		if err != nil {
 			return fmt.Errorf("failed to run embedded server: %v", err)
@@ -155,6 +215,11 @@ func (c *Component) Update(args component.Arguments) error {
 	c.server.SetLabels(newArgs.labelSet())
 	c.server.SetRelabelRules(newArgs.RelabelRules)
 	c.server.SetKeepTimestamp(newArgs.UseIncomingTimestamp)
+	c.server.SetTenantConfig(newArgs.tenantConfig())
+	c.server.SetAuthConfig(newArgs.authConfig())
+	c.server.SetForwardTimeout(newArgs.ForwardTimeout)
+	c.server.SetForwardMaxRetries(newArgs.ForwardMaxRetries)
+	c.server.SetDedupWindow(newArgs.DedupWindow)
 
 	return nil
 }
@@ -2,8 +2,20 @@ package api
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"fmt"
+	"math/big"
+	gonet "net"
 	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -19,7 +31,9 @@ import (
 	"github.com/grafana/dskit/flagext"
 	"github.com/grafana/loki/pkg/logproto"
 	"github.com/grafana/regexp"
+	"github.com/grafana/river/rivertypes"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/prometheus/common/model"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -322,6 +336,133 @@ func TestDefaultServerConfig(t *testing.T) {
 	comp.stop()
 }
 
+func TestLokiSourceAPI_TLSAndAuth(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	certFile, keyFile, certPool := writeTestCertificate(t)
+
+	receiver := fake.NewClient(func() {})
+	defer receiver.Stop()
+
+	args := testArgsWith(t, func(a *Arguments) {
+		a.Server.HTTP.TLSConfig = &net.TLSConfig{CertFile: certFile, KeyFile: keyFile}
+		a.ForwardTo = []loki.LogsReceiver{receiver.LogsReceiver()}
+		a.RelabelRules = nil
+		a.BearerToken = rivertypes.Secret("token")
+	})
+	opts := defaultOptions(t)
+	comp, err := New(opts, args)
+	require.NoError(t, err)
+	go func() {
+		require.NoError(t, comp.Run(ctx))
+	}()
+	defer comp.stop()
+
+	httpClient := &http.Client{Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{RootCAs: certPool, ServerName: "localhost"},
+	}}
+	push := func(token string) int {
+		req, err := http.NewRequest(http.MethodPost, fmt.Sprintf(
+			"https://%s:%d/loki/api/v1/raw",
+			args.Server.HTTP.ListenAddress,
+			args.Server.HTTP.ListenPort,
+		), strings.NewReader("hello world!\n"))
+		require.NoError(t, err)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return 0
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// Requests without the right bearer token are rejected.
+	require.Eventually(t, func() bool {
+		return push("") == http.StatusUnauthorized
+	}, 5*time.Second, 20*time.Millisecond, "server failed to start before timeout")
+	require.Equal(t, http.StatusUnauthorized, push("wrong"))
+	require.Empty(t, receiver.Received())
+
+	require.NoError(t, testutil.GatherAndCompare(opts.Registerer.(prometheus.Gatherer), strings.NewReader(`
+		# HELP loki_source_api_unauthorized_requests_total Number of push requests rejected because they weren't authenticated.
+		# TYPE loki_source_api_unauthorized_requests_total counter
+		loki_source_api_unauthorized_requests_total 2
+	`), "loki_source_api_unauthorized_requests_total"))
+
+	// Requests with the bearer token are accepted.
+	require.Equal(t, http.StatusNoContent, push("token"))
+	require.Eventually(t, func() bool {
+		return len(receiver.Received()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, "hello world!", receiver.Received()[0].Line)
+
+	// Plain HTTP requests aren't served.
+	resp, err := http.Post(fmt.Sprintf(
+		"http://%s:%d/loki/api/v1/raw",
+		args.Server.HTTP.ListenAddress,
+		args.Server.HTTP.ListenPort,
+	), "text/plain", strings.NewReader("hello world!\n"))
+	if err == nil {
+		resp.Body.Close()
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	}
+}
+
+func TestInvalidAuth(t *testing.T) {
+	args := testArgsWith(t, func(a *Arguments) {
+		a.BearerToken = rivertypes.Secret("token")
+		a.BasicAuth = &BasicAuth{Username: "user", Password: rivertypes.Secret("password")}
+	})
+	require.EqualError(t, args.Validate(), "bearer_token and basic_auth can't be used together")
+
+	args = testArgsWith(t, func(a *Arguments) {
+		a.BasicAuth = &BasicAuth{Password: rivertypes.Secret("password")}
+	})
+	require.EqualError(t, args.Validate(), "basic_auth username must not be empty")
+}
+
+// writeTestCertificate writes a self-signed certificate for localhost and its
+// key to a temporary directory. It returns the paths of the files and a pool
+// trusting the certificate.
+func writeTestCertificate(t *testing.T) (certFile, keyFile string, pool *x509.CertPool) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []gonet.IP{gonet.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+
+	pool = x509.NewCertPool()
+	pool.AddCert(cert)
+	return certFile, keyFile, pool
+}
+
 func startTestComponent(
 	t *testing.T,
 	opts component.Options,
@@ -0,0 +1,139 @@
+package lokipush
+
+import (
+	"encoding/binary"
+	"sync"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/prometheus/common/model"
+)
+
+const (
+	// forwardWindowSeconds is how far back the outcome of push requests is
+	// taken into account to report readiness.
+	forwardWindowSeconds = 60
+
+	// maxForwardFailureRatio is the ratio of push requests in the window
+	// whose entries couldn't be forwarded above which the server is reported
+	// as not ready.
+	maxForwardFailureRatio = 0.5
+
+	// forwardMinBackoff and forwardMaxBackoff bound the delay between retries
+	// of an entry which couldn't be forwarded in time.
+	forwardMinBackoff = 100 * time.Millisecond
+	forwardMaxBackoff = time.Second
+)
+
+// forwardWindow counts the push requests whose entries were or weren't
+// forwarded over a sliding window of forwardWindowSeconds, in buckets of one
+// second.
+type forwardWindow struct {
+	mut     sync.Mutex
+	now     func() time.Time
+	buckets [forwardWindowSeconds]forwardBucket
+}
+
+type forwardBucket struct {
+	second            int64 // Unix time of the second counted in the bucket.
+	forwarded, failed int
+}
+
+func newForwardWindow() *forwardWindow {
+	return &forwardWindow{now: time.Now}
+}
+
+// Record counts the outcome of forwarding the entries of a push request.
+func (w *forwardWindow) Record(forwarded bool) {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+
+	second := w.now().Unix()
+	b := &w.buckets[second%forwardWindowSeconds]
+	if b.second != second {
+		*b = forwardBucket{second: second}
+	}
+
+	if forwarded {
+		b.forwarded++
+	} else {
+		b.failed++
+	}
+}
+
+// Healthy returns false if more than maxForwardFailureRatio of the push
+// requests in the window failed to be forwarded.
+func (w *forwardWindow) Healthy() bool {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+
+	var (
+		now               = w.now().Unix()
+		forwarded, failed int
+	)
+	for _, b := range w.buckets {
+		if now-b.second < forwardWindowSeconds {
+			forwarded += b.forwarded
+			failed += b.failed
+		}
+	}
+
+	total := forwarded + failed
+	return total == 0 || float64(failed)/float64(total) <= maxForwardFailureRatio
+}
+
+// forwardedEntries remembers the entries forwarded over a dedup window, so
+// that the entries of a push request which is retried by its client after
+// being partially forwarded aren't forwarded twice.
+type forwardedEntries struct {
+	mut       sync.Mutex
+	now       func() time.Time
+	entries   map[uint64]time.Time // Key of each entry to when it was forwarded.
+	lastPrune time.Time
+}
+
+func newForwardedEntries() *forwardedEntries {
+	return &forwardedEntries{
+		now:     time.Now,
+		entries: make(map[uint64]time.Time),
+	}
+}
+
+// Seen returns true if the entry with the given key was forwarded within
+// window.
+func (f *forwardedEntries) Seen(key uint64, window time.Duration) bool {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	forwarded, ok := f.entries[key]
+	return ok && f.now().Sub(forwarded) < window
+}
+
+// Add remembers that the entry with the given key was forwarded. Entries
+// forwarded longer than window ago are forgotten.
+func (f *forwardedEntries) Add(key uint64, window time.Duration) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	now := f.now()
+	f.entries[key] = now
+
+	if now.Sub(f.lastPrune) < window {
+		return
+	}
+	for k, forwarded := range f.entries {
+		if now.Sub(forwarded) >= window {
+			delete(f.entries, k)
+		}
+	}
+	f.lastPrune = now
+}
+
+// entryKey identifies an entry by its labels, incoming timestamp, and line.
+func entryKey(labels model.LabelSet, timestamp time.Time, line string) uint64 {
+	h := xxhash.New()
+	_, _ = h.WriteString(labels.String())
+	_ = binary.Write(h, binary.LittleEndian, timestamp.UnixNano())
+	_, _ = h.WriteString(line)
+	return h.Sum64()
+}
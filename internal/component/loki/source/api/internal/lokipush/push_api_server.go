@@ -2,6 +2,9 @@ package lokipush
 
 import (
 	"bufio"
+	"context"
+	"crypto/subtle"
+	"errors"
 	"io"
 	"net/http"
 	"sort"
@@ -12,10 +15,13 @@ import (
 	"github.com/go-kit/log"
 	"github.com/gorilla/mux"
 	"github.com/grafana/agent/internal/component/common/loki"
+	"github.com/grafana/agent/internal/component/common/loki/client"
 	fnet "github.com/grafana/agent/internal/component/common/net"
 	frelabel "github.com/grafana/agent/internal/component/common/relabel"
 	"github.com/grafana/agent/internal/flow/logging/level"
+	"github.com/grafana/dskit/backoff"
 	"github.com/grafana/dskit/tenant"
+	"github.com/grafana/dskit/user"
 	"github.com/grafana/loki/pkg/loghttp/push"
 	"github.com/grafana/loki/pkg/logproto"
 	util_log "github.com/grafana/loki/pkg/util/log"
@@ -32,10 +38,86 @@ type PushAPIServer struct {
 	server       *fnet.TargetServer
 	handler      loki.EntryHandler
 
-	rwMutex       sync.RWMutex
-	labels        model.LabelSet
-	relabelRules  []*relabel.Config
-	keepTimestamp bool
+	rwMutex           sync.RWMutex
+	labels            model.LabelSet
+	relabelRules      []*relabel.Config
+	keepTimestamp     bool
+	tenantConfig      TenantConfig
+	authConfig        AuthConfig
+	forwardTimeout    time.Duration
+	forwardMaxRetries int
+	dedupWindow       time.Duration
+
+	forwards  *forwardWindow
+	forwarded *forwardedEntries
+
+	unauthorizedRequests prometheus.Counter
+	forwardTimeouts      prometheus.Counter
+	forwardRetries       prometheus.Counter
+	dedupedEntries       prometheus.Counter
+}
+
+// AuthConfig configures how push requests are authenticated. Requests aren't
+// authenticated if neither a bearer token nor a username is set.
+type AuthConfig struct {
+	// BearerToken is the token push requests must send in their
+	// Authorization header.
+	BearerToken string
+	// Username and Password are the basic auth credentials push requests must
+	// send.
+	Username string
+	Password string
+}
+
+// authorized returns whether the push request r is authenticated.
+func (cfg AuthConfig) authorized(r *http.Request) bool {
+	switch {
+	case cfg.BearerToken != "":
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		return ok && subtle.ConstantTimeCompare([]byte(token), []byte(cfg.BearerToken)) == 1
+	case cfg.Username != "":
+		username, password, ok := r.BasicAuth()
+		return ok &&
+			subtle.ConstantTimeCompare([]byte(username), []byte(cfg.Username)) == 1 &&
+			subtle.ConstantTimeCompare([]byte(password), []byte(cfg.Password)) == 1
+	default:
+		return true
+	}
+}
+
+// TenantConfig configures how the tenant of pushed entries is set.
+type TenantConfig struct {
+	// UseIncoming sets the tenant of entries to the X-Scope-OrgID header of
+	// the push request.
+	UseIncoming bool
+	// Required rejects push requests without an X-Scope-OrgID header when
+	// UseIncoming is set.
+	Required bool
+	// Default is the tenant of entries from push requests without an
+	// X-Scope-OrgID header when UseIncoming is set.
+	Default string
+}
+
+var (
+	errMissingTenant  = errors.New("no tenant ID in the " + user.OrgIDHeaderName + " header")
+	errForwardTimeout = errors.New("timed out forwarding entries")
+)
+
+// tenantID returns the tenant to set on entries from the push request r. An
+// empty tenant ID leaves the tenant of entries unset. An error is returned if
+// the push request must be rejected.
+func (cfg TenantConfig) tenantID(r *http.Request) (string, error) {
+	if !cfg.UseIncoming {
+		return "", nil
+	}
+
+	tenantID := r.Header.Get(user.OrgIDHeaderName)
+	if tenantID != "" {
+		return tenantID, nil
+	} else if cfg.Required {
+		return "", errMissingTenant
+	}
+	return cfg.Default, nil
 }
 
 func NewPushAPIServer(logger log.Logger,
@@ -48,6 +130,30 @@ func NewPushAPIServer(logger log.Logger,
 		logger:       logger,
 		serverConfig: serverConfig,
 		handler:      handler,
+		forwards:     newForwardWindow(),
+		forwarded:    newForwardedEntries(),
+
+		unauthorizedRequests: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "loki_source_api_unauthorized_requests_total",
+			Help: "Number of push requests rejected because they weren't authenticated.",
+		}),
+		forwardTimeouts: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "loki_source_api_forward_timeouts_total",
+			Help: "Number of push requests rejected because their entries couldn't be forwarded in time.",
+		}),
+		forwardRetries: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "loki_source_api_forward_retries_total",
+			Help: "Number of times forwarding an entry was retried after timing out.",
+		}),
+		dedupedEntries: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "loki_source_api_deduplicated_entries_total",
+			Help: "Number of entries dropped because they were already forwarded within the dedup window.",
+		}),
+	}
+	for _, c := range []prometheus.Collector{s.unauthorizedRequests, s.forwardTimeouts, s.forwardRetries, s.dedupedEntries} {
+		if err := registerer.Register(c); err != nil {
+			return nil, err
+		}
 	}
 
 	srv, err := fnet.NewTargetServer(logger, "loki_source_api", registerer, serverConfig)
@@ -65,19 +171,19 @@ func (s *PushAPIServer) Run() error {
 	err := s.server.MountAndRun(func(router *mux.Router) {
 		// This redirecting is so we can avoid breaking changes where we originally implemented it with
 		// the loki prefix.
-		router.Path("/api/v1/push").Methods("POST").Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		router.Path("/api/v1/push").Methods("POST").Handler(s.authenticate(func(w http.ResponseWriter, r *http.Request) {
 			r.URL.Path = "/loki/api/v1/push"
 			r.RequestURI = "/loki/api/v1/push"
 			s.handleLoki(w, r)
 		}))
-		router.Path("/api/v1/raw").Methods("POST").Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		router.Path("/api/v1/raw").Methods("POST").Handler(s.authenticate(func(w http.ResponseWriter, r *http.Request) {
 			r.URL.Path = "/loki/api/v1/raw"
 			r.RequestURI = "/loki/api/v1/raw"
 			s.handlePlaintext(w, r)
 		}))
 		router.Path("/ready").Methods("GET").Handler(http.HandlerFunc(s.ready))
-		router.Path("/loki/api/v1/push").Methods("POST").Handler(http.HandlerFunc(s.handleLoki))
-		router.Path("/loki/api/v1/raw").Methods("POST").Handler(http.HandlerFunc(s.handlePlaintext))
+		router.Path("/loki/api/v1/push").Methods("POST").Handler(s.authenticate(s.handleLoki))
+		router.Path("/loki/api/v1/raw").Methods("POST").Handler(s.authenticate(s.handlePlaintext))
 	})
 	return err
 }
@@ -115,6 +221,136 @@ func (s *PushAPIServer) getKeepTimestamp() bool {
 	return s.keepTimestamp
 }
 
+func (s *PushAPIServer) SetTenantConfig(cfg TenantConfig) {
+	s.rwMutex.Lock()
+	defer s.rwMutex.Unlock()
+	s.tenantConfig = cfg
+}
+
+func (s *PushAPIServer) getTenantConfig() TenantConfig {
+	s.rwMutex.RLock()
+	defer s.rwMutex.RUnlock()
+	return s.tenantConfig
+}
+
+func (s *PushAPIServer) SetAuthConfig(cfg AuthConfig) {
+	s.rwMutex.Lock()
+	defer s.rwMutex.Unlock()
+	s.authConfig = cfg
+}
+
+func (s *PushAPIServer) getAuthConfig() AuthConfig {
+	s.rwMutex.RLock()
+	defer s.rwMutex.RUnlock()
+	return s.authConfig
+}
+
+func (s *PushAPIServer) SetForwardTimeout(timeout time.Duration) {
+	s.rwMutex.Lock()
+	defer s.rwMutex.Unlock()
+	s.forwardTimeout = timeout
+}
+
+func (s *PushAPIServer) getForwardTimeout() time.Duration {
+	s.rwMutex.RLock()
+	defer s.rwMutex.RUnlock()
+	return s.forwardTimeout
+}
+
+func (s *PushAPIServer) SetForwardMaxRetries(maxRetries int) {
+	s.rwMutex.Lock()
+	defer s.rwMutex.Unlock()
+	s.forwardMaxRetries = maxRetries
+}
+
+func (s *PushAPIServer) getForwardMaxRetries() int {
+	s.rwMutex.RLock()
+	defer s.rwMutex.RUnlock()
+	return s.forwardMaxRetries
+}
+
+func (s *PushAPIServer) SetDedupWindow(window time.Duration) {
+	s.rwMutex.Lock()
+	defer s.rwMutex.Unlock()
+	s.dedupWindow = window
+}
+
+func (s *PushAPIServer) getDedupWindow() time.Duration {
+	s.rwMutex.RLock()
+	defer s.rwMutex.RUnlock()
+	return s.dedupWindow
+}
+
+// Ready returns false if the entries of most push requests received in the
+// last minute couldn't be forwarded.
+func (s *PushAPIServer) Ready() bool {
+	return s.forwards.Healthy()
+}
+
+// forward sends e to the handler, waiting at most timeout for it to be
+// accepted and retrying up to maxRetries times with backoff if it isn't. A
+// zero timeout waits until e is accepted. Retries stop early once ctx is
+// canceled.
+func (s *PushAPIServer) forward(ctx context.Context, e loki.Entry, timeout time.Duration, maxRetries int) error {
+	if timeout == 0 {
+		s.handler.Chan() <- e
+		return nil
+	}
+
+	retries := backoff.New(ctx, backoff.Config{
+		MinBackoff: forwardMinBackoff,
+		MaxBackoff: forwardMaxBackoff,
+	})
+	for {
+		err := s.forwardOnce(e, timeout)
+		if err == nil || retries.NumRetries() >= maxRetries {
+			return err
+		}
+
+		s.forwardRetries.Inc()
+		retries.Wait()
+		if ctx.Err() != nil {
+			return err
+		}
+	}
+}
+
+// forwardOnce sends e to the handler, waiting at most timeout for it to be
+// accepted.
+func (s *PushAPIServer) forwardOnce(e loki.Entry, timeout time.Duration) error {
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	select {
+	case s.handler.Chan() <- e:
+		return nil
+	case <-t.C:
+		return errForwardTimeout
+	}
+}
+
+// forwardFailed records a push request whose entries couldn't be forwarded
+// and rejects it so that the client retries it later.
+func (s *PushAPIServer) forwardFailed(w http.ResponseWriter, err error) {
+	level.Warn(s.logger).Log("msg", "failed to forward entries of incoming push request", "err", err.Error())
+	s.forwardTimeouts.Inc()
+	s.forwards.Record(false)
+	http.Error(w, err.Error(), http.StatusServiceUnavailable)
+}
+
+// authenticate wraps next so that push requests which aren't authenticated
+// are rejected.
+func (s *PushAPIServer) authenticate(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.getAuthConfig().authorized(r) {
+			level.Warn(s.logger).Log("msg", "rejected unauthenticated push request", "remote_addr", r.RemoteAddr)
+			s.unauthorizedRequests.Inc()
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	})
+}
+
 func (s *PushAPIServer) SetRelabelRules(rules frelabel.Rules) {
 	s.rwMutex.Lock()
 	defer s.rwMutex.Unlock()
@@ -136,6 +372,12 @@ func (s *PushAPIServer) getRelabelRules() []*relabel.Config {
 // Only the HTTP handler functions are copied to allow for flow-specific server configuration and lifecycle management.
 func (s *PushAPIServer) handleLoki(w http.ResponseWriter, r *http.Request) {
 	logger := util_log.WithContext(r.Context(), util_log.Logger)
+	tenantID, err := s.getTenantConfig().tenantID(r)
+	if err != nil {
+		level.Warn(s.logger).Log("msg", "rejected incoming push request", "err", err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	userID, _ := tenant.TenantID(r.Context())
 	req, err := push.ParseRequest(logger, userID, r, nil, nil, push.ParseLokiRequest)
 	if err != nil {
@@ -148,6 +390,9 @@ func (s *PushAPIServer) handleLoki(w http.ResponseWriter, r *http.Request) {
 	addLabels := s.getLabels()
 	relabelRules := s.getRelabelRules()
 	keepTimestamp := s.getKeepTimestamp()
+	forwardTimeout := s.getForwardTimeout()
+	forwardMaxRetries := s.getForwardMaxRetries()
+	dedupWindow := s.getDedupWindow()
 
 	var lastErr error
 	for _, stream := range req.Streams {
@@ -180,6 +425,9 @@ func (s *PushAPIServer) handleLoki(w http.ResponseWriter, r *http.Request) {
 			}
 			filtered[model.LabelName(processed[i].Name)] = model.LabelValue(processed[i].Value)
 		}
+		if tenantID != "" {
+			filtered[client.ReservedLabelTenantID] = model.LabelValue(tenantID)
+		}
 
 		for _, entry := range stream.Entries {
 			e := loki.Entry{
@@ -195,9 +443,28 @@ func (s *PushAPIServer) handleLoki(w http.ResponseWriter, r *http.Request) {
 			} else {
 				e.Timestamp = time.Now()
 			}
-			s.handler.Chan() <- e
+
+			// Entries are identified by their incoming timestamp, so that
+			// entries of a retried push request are recognized even when
+			// their timestamp is replaced.
+			var key uint64
+			if dedupWindow > 0 {
+				key = entryKey(filtered, entry.Timestamp, entry.Line)
+				if s.forwarded.Seen(key, dedupWindow) {
+					s.dedupedEntries.Inc()
+					continue
+				}
+			}
+			if err := s.forward(r.Context(), e, forwardTimeout, forwardMaxRetries); err != nil {
+				s.forwardFailed(w, err)
+				return
+			}
+			if dedupWindow > 0 {
+				s.forwarded.Add(key, dedupWindow)
+			}
 		}
 	}
+	s.forwards.Record(true)
 
 	if lastErr != nil {
 		level.Warn(s.logger).Log("msg", "at least one entry in the push request failed to process", "err", lastErr.Error())
@@ -211,10 +478,20 @@ func (s *PushAPIServer) handleLoki(w http.ResponseWriter, r *http.Request) {
 // NOTE: This code is copied from Promtail (https://github.com/grafana/loki/commit/47e2c5884f443667e64764f3fc3948f8f11abbb8) with changes kept to the minimum.
 // Only the HTTP handler functions are copied to allow for flow-specific server configuration and lifecycle management.
 func (s *PushAPIServer) handlePlaintext(w http.ResponseWriter, r *http.Request) {
-	entries := s.handler.Chan()
 	defer r.Body.Close()
+	tenantID, err := s.getTenantConfig().tenantID(r)
+	if err != nil {
+		level.Warn(s.logger).Log("msg", "rejected incoming push request", "err", err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	body := bufio.NewReader(r.Body)
 	addLabels := s.getLabels()
+	forwardTimeout := s.getForwardTimeout()
+	forwardMaxRetries := s.getForwardMaxRetries()
+	if tenantID != "" {
+		addLabels[client.ReservedLabelTenantID] = model.LabelValue(tenantID)
+	}
 	for {
 		line, err := body.ReadString('\n')
 		if err != nil && err != io.EOF {
@@ -229,17 +506,22 @@ func (s *PushAPIServer) handlePlaintext(w http.ResponseWriter, r *http.Request)
 			}
 			continue
 		}
-		entries <- loki.Entry{
+		e := loki.Entry{
 			Labels: addLabels,
 			Entry: logproto.Entry{
 				Timestamp: time.Now(),
 				Line:      line,
 			},
 		}
+		if err := s.forward(r.Context(), e, forwardTimeout, forwardMaxRetries); err != nil {
+			s.forwardFailed(w, err)
+			return
+		}
 		if err == io.EOF {
 			break
 		}
 	}
+	s.forwards.Record(true)
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -247,6 +529,10 @@ func (s *PushAPIServer) handlePlaintext(w http.ResponseWriter, r *http.Request)
 // NOTE: This code is copied from Promtail (https://github.com/grafana/loki/commit/47e2c5884f443667e64764f3fc3948f8f11abbb8) with changes kept to the minimum.
 // Only the HTTP handler functions are copied to allow for flow-specific server configuration and lifecycle management.
 func (s *PushAPIServer) ready(w http.ResponseWriter, r *http.Request) {
+	if !s.Ready() {
+		http.Error(w, "not ready", http.StatusServiceUnavailable)
+		return
+	}
 	resp := "ready"
 	if _, err := w.Write([]byte(resp)); err != nil {
 		level.Error(s.logger).Log("msg", "failed to respond to ready endoint", "err", err)
@@ -27,8 +27,10 @@ import (
 	"github.com/grafana/river"
 	"github.com/phayes/freeport"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/prometheus/common/model"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
 )
 
 const localhost = "127.0.0.1"
@@ -328,6 +330,224 @@ func TestReady(t *testing.T) {
 	t.Cleanup(pt.Shutdown)
 }
 
+func TestReadyForwardFailures(t *testing.T) {
+	w := log.NewSyncWriter(os.Stderr)
+	logger := log.NewLogfmtLogger(w)
+
+	// Entries are only accepted by the handler while it's being read from.
+	var (
+		entries  = make(chan loki.Entry)
+		received atomic.Int64
+	)
+	startReading := func() (stop func()) {
+		var (
+			stopCh = make(chan struct{})
+			done   = make(chan struct{})
+		)
+		go func() {
+			defer close(done)
+			for {
+				select {
+				case <-entries:
+					received.Inc()
+				case <-stopCh:
+					return
+				}
+			}
+		}()
+		return func() {
+			close(stopCh)
+			<-done
+		}
+	}
+	eh := loki.NewEntryHandler(entries, func() {})
+
+	serverConfig := &fnet.ServerConfig{
+		HTTP: &fnet.HTTPConfig{
+			ListenAddress: localhost,
+			ListenPort:    getFreePort(t),
+		},
+		GRPC: &fnet.GRPCConfig{ListenPort: getFreePort(t)},
+	}
+	pt, err := NewPushAPIServer(logger, serverConfig, eh, prometheus.NewRegistry())
+	require.NoError(t, err)
+	require.NoError(t, pt.Run())
+	t.Cleanup(pt.Shutdown)
+	pt.SetForwardTimeout(50 * time.Millisecond)
+
+	push := func() int {
+		resp, err := http.Post(fmt.Sprintf("http://%s:%d/api/v1/raw", localhost, serverConfig.HTTP.ListenPort), "text/plain", bytes.NewBufferString("line"))
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	ready := func() int {
+		resp, err := http.Get(fmt.Sprintf("http://%s:%d/ready", localhost, serverConfig.HTTP.ListenPort))
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// The server is ready before receiving any push request.
+	require.Equal(t, http.StatusOK, ready())
+
+	stopReading := startReading()
+	require.Equal(t, http.StatusNoContent, push())
+	require.Equal(t, int64(1), received.Load())
+	require.Equal(t, http.StatusOK, ready())
+
+	// The server stays ready while at most half of the push requests fail.
+	stopReading()
+	require.Equal(t, http.StatusServiceUnavailable, push())
+	require.Equal(t, http.StatusOK, ready())
+	require.Equal(t, http.StatusServiceUnavailable, push())
+	require.Equal(t, http.StatusServiceUnavailable, ready())
+	require.Equal(t, 2.0, testutil.ToFloat64(pt.forwardTimeouts))
+
+	// The server is ready again once enough push requests succeed.
+	stopReading = startReading()
+	defer stopReading()
+	require.Equal(t, http.StatusNoContent, push())
+	require.Equal(t, http.StatusOK, ready())
+}
+
+func TestForwardWindow(t *testing.T) {
+	now := time.Unix(1000, 0)
+	w := newForwardWindow()
+	w.now = func() time.Time { return now }
+
+	require.True(t, w.Healthy())
+
+	w.Record(true)
+	w.Record(false)
+	require.True(t, w.Healthy())
+	w.Record(false)
+	require.False(t, w.Healthy())
+
+	// Failures are forgotten once they're out of the window.
+	now = now.Add(30 * time.Second)
+	w.Record(true)
+	w.Record(true)
+	require.True(t, w.Healthy())
+	w.Record(false)
+	w.Record(false)
+	require.False(t, w.Healthy())
+
+	now = now.Add(forwardWindowSeconds * time.Second)
+	require.True(t, w.Healthy())
+}
+
+func TestForwardRetries(t *testing.T) {
+	w := log.NewSyncWriter(os.Stderr)
+	logger := log.NewLogfmtLogger(w)
+
+	entries := make(chan loki.Entry)
+	eh := loki.NewEntryHandler(entries, func() {})
+
+	serverConfig := &fnet.ServerConfig{
+		HTTP: &fnet.HTTPConfig{
+			ListenAddress: localhost,
+			ListenPort:    getFreePort(t),
+		},
+		GRPC: &fnet.GRPCConfig{ListenPort: getFreePort(t)},
+	}
+	pt, err := NewPushAPIServer(logger, serverConfig, eh, prometheus.NewRegistry())
+	require.NoError(t, err)
+	require.NoError(t, pt.Run())
+	t.Cleanup(pt.Shutdown)
+	pt.SetForwardTimeout(20 * time.Millisecond)
+	pt.SetForwardMaxRetries(3)
+
+	push := func() int {
+		resp, err := http.Post(fmt.Sprintf("http://%s:%d/api/v1/raw", localhost, serverConfig.HTTP.ListenPort), "text/plain", bytes.NewBufferString("line"))
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// The handler only accepts the entry after it failed to be forwarded
+	// twice, so the push request succeeds on the second retry.
+	received := make(chan loki.Entry, 1)
+	go func() {
+		for testutil.ToFloat64(pt.forwardRetries) < 2 {
+			time.Sleep(time.Millisecond)
+		}
+		received <- <-entries
+	}()
+	require.Equal(t, http.StatusNoContent, push())
+	require.Equal(t, "line", (<-received).Line)
+	require.Equal(t, 2.0, testutil.ToFloat64(pt.forwardRetries))
+	require.Equal(t, 0.0, testutil.ToFloat64(pt.forwardTimeouts))
+
+	// The push request is rejected once all retries failed.
+	require.Equal(t, http.StatusServiceUnavailable, push())
+	require.Equal(t, 5.0, testutil.ToFloat64(pt.forwardRetries))
+	require.Equal(t, 1.0, testutil.ToFloat64(pt.forwardTimeouts))
+}
+
+func TestDedupWindow(t *testing.T) {
+	w := log.NewSyncWriter(os.Stderr)
+	logger := log.NewLogfmtLogger(w)
+
+	entries := make(chan loki.Entry)
+	eh := loki.NewEntryHandler(entries, func() {})
+
+	serverConfig := &fnet.ServerConfig{
+		HTTP: &fnet.HTTPConfig{
+			ListenAddress: localhost,
+			ListenPort:    getFreePort(t),
+		},
+		GRPC: &fnet.GRPCConfig{ListenPort: getFreePort(t)},
+	}
+	pt, err := NewPushAPIServer(logger, serverConfig, eh, prometheus.NewRegistry())
+	require.NoError(t, err)
+	require.NoError(t, pt.Run())
+	t.Cleanup(pt.Shutdown)
+	pt.SetForwardTimeout(50 * time.Millisecond)
+	pt.SetDedupWindow(time.Minute)
+
+	body := fmt.Sprintf(`{"streams": [{"stream": {"job": "test"}, "values": [["%[1]d", "first"], ["%[1]d", "second"]]}]}`, time.Now().UnixNano())
+	push := func() int {
+		resp, err := http.Post(fmt.Sprintf("http://%s:%d/loki/api/v1/push", localhost, serverConfig.HTTP.ListenPort), "application/json", bytes.NewBufferString(body))
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	// Only the first entry is accepted, so the push request fails.
+	first := make(chan string, 1)
+	go func() { first <- (<-entries).Line }()
+	require.Equal(t, http.StatusServiceUnavailable, push())
+	require.Equal(t, "first", <-first)
+
+	// The retried push request only forwards the entry which wasn't forwarded
+	// yet.
+	second := make(chan string, 1)
+	go func() { second <- (<-entries).Line }()
+	require.Equal(t, http.StatusNoContent, push())
+	require.Equal(t, "second", <-second)
+	require.Equal(t, 1.0, testutil.ToFloat64(pt.dedupedEntries))
+}
+
+func TestForwardedEntries(t *testing.T) {
+	now := time.Unix(1000, 0)
+	f := newForwardedEntries()
+	f.now = func() time.Time { return now }
+
+	key := entryKey(model.LabelSet{"job": "test"}, now, "line")
+	require.NotEqual(t, key, entryKey(model.LabelSet{"job": "other"}, now, "line"))
+	require.NotEqual(t, key, entryKey(model.LabelSet{"job": "test"}, now.Add(time.Nanosecond), "line"))
+
+	require.False(t, f.Seen(key, time.Minute))
+	f.Add(key, time.Minute)
+	require.True(t, f.Seen(key, time.Minute))
+
+	// Entries are forgotten once they're out of the window.
+	now = now.Add(time.Minute)
+	require.False(t, f.Seen(key, time.Minute))
+	f.Add(entryKey(model.LabelSet{"job": "test"}, now, "other line"), time.Minute)
+	require.Len(t, f.entries, 1)
+}
+
 func getFreePort(t *testing.T) int {
 	port, err := freeport.GetFreePort()
 	require.NoError(t, err)
@@ -359,3 +579,122 @@ func createPushServer(t *testing.T, logger log.Logger) (*PushAPIServer, int, *fa
 	require.NoError(t, err)
 	return pt, port, eh
 }
+
+func TestLokiPushTargetTenant(t *testing.T) {
+	w := log.NewSyncWriter(os.Stderr)
+	logger := log.NewLogfmtLogger(w)
+	pt, port, eh := createPushServer(t, logger)
+	t.Cleanup(pt.Shutdown)
+
+	pt.SetTenantConfig(TenantConfig{UseIncoming: true})
+
+	// Build a client to send logs. The client sends the tenant of entries in
+	// the X-Scope-OrgID header.
+	serverURL := flagext.URLValue{}
+	err := serverURL.Set("http://" + localhost + ":" + strconv.Itoa(port) + "/api/v1/push")
+	require.NoError(t, err)
+
+	ccfg := client.Config{
+		URL:       serverURL,
+		Timeout:   1 * time.Second,
+		BatchWait: 1 * time.Second,
+		BatchSize: 100 * 1024,
+	}
+	m := client.NewMetrics(prometheus.NewRegistry())
+	pc, err := client.New(m, ccfg, 0, 0, false, logger)
+	require.NoError(t, err)
+	defer pc.Stop()
+
+	for i := 0; i < 10; i++ {
+		tenantID := "tenant-" + strconv.Itoa(i%2)
+		pc.Chan() <- loki.Entry{
+			Labels: model.LabelSet{
+				"stream":                     "stream1",
+				client.ReservedLabelTenantID: model.LabelValue(tenantID),
+			},
+			Entry: logproto.Entry{
+				Timestamp: time.Unix(int64(i), 0),
+				Line:      "line" + strconv.Itoa(i),
+			},
+		}
+	}
+
+	require.Eventually(t, func() bool {
+		return len(eh.Received()) == 10
+	}, 10*time.Second, 10*time.Millisecond)
+
+	// Each entry keeps the tenant it was pushed with.
+	tenants := map[model.LabelValue]int{}
+	for _, entry := range eh.Received() {
+		tenants[entry.Labels[client.ReservedLabelTenantID]]++
+	}
+	require.Equal(t, map[model.LabelValue]int{"tenant-0": 5, "tenant-1": 5}, tenants)
+}
+
+func TestPlaintextPushTargetTenant(t *testing.T) {
+	tt := []struct {
+		name         string
+		config       TenantConfig
+		header       string
+		expectCode   int
+		expectTenant model.LabelValue
+	}{
+		{
+			name:         "incoming tenant",
+			config:       TenantConfig{UseIncoming: true},
+			header:       "tenant-1",
+			expectCode:   http.StatusNoContent,
+			expectTenant: "tenant-1",
+		},
+		{
+			name:       "incoming tenant ignored",
+			config:     TenantConfig{},
+			header:     "tenant-1",
+			expectCode: http.StatusNoContent,
+		},
+		{
+			name:         "default tenant",
+			config:       TenantConfig{UseIncoming: true, Default: "fallback"},
+			expectCode:   http.StatusNoContent,
+			expectTenant: "fallback",
+		},
+		{
+			name:       "required tenant",
+			config:     TenantConfig{UseIncoming: true, Required: true},
+			expectCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			w := log.NewSyncWriter(os.Stderr)
+			logger := log.NewLogfmtLogger(w)
+			pt, port, eh := createPushServer(t, logger)
+			t.Cleanup(pt.Shutdown)
+
+			pt.SetTenantConfig(tc.config)
+
+			req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("http://%s:%d/api/v1/raw", localhost, port), bytes.NewBufferString("line"))
+			require.NoError(t, err)
+			if tc.header != "" {
+				req.Header.Set("X-Scope-OrgID", tc.header)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tc.expectCode, resp.StatusCode)
+			// Missing tenants aren't authentication failures.
+			require.Equal(t, 0.0, testutil.ToFloat64(pt.unauthorizedRequests))
+
+			if tc.expectCode != http.StatusNoContent {
+				require.Empty(t, eh.Received())
+				return
+			}
+
+			require.Eventually(t, func() bool {
+				return len(eh.Received()) == 1
+			}, 5*time.Second, 10*time.Millisecond)
+			require.Equal(t, tc.expectTenant, eh.Received()[0].Labels[client.ReservedLabelTenantID])
+		})
+	}
+}
@@ -0,0 +1,263 @@
+// Package aws_kinesis implements the loki.source.aws_kinesis component.
+package aws_kinesis //nolint:golint
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	aws_config "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/grafana/agent/internal/component"
+	"github.com/grafana/agent/internal/component/common/loki"
+	"github.com/grafana/agent/internal/component/common/loki/positions"
+	flow_relabel "github.com/grafana/agent/internal/component/common/relabel"
+	"github.com/grafana/agent/internal/featuregate"
+	"github.com/grafana/river/rivertypes"
+	"github.com/prometheus/common/model"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:      "loki.source.aws_kinesis",
+		Stability: featuregate.StabilityBeta,
+		Args:      Arguments{},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return New(opts, args.(Arguments))
+		},
+	})
+}
+
+const (
+	// startingPositionLatest starts reading shards without a saved position
+	// from their newest record.
+	startingPositionLatest = "latest"
+
+	// startingPositionTrimHorizon starts reading shards without a saved
+	// position from their oldest record.
+	startingPositionTrimHorizon = "trim_horizon"
+)
+
+// Arguments holds values which are used to configure the
+// loki.source.aws_kinesis component.
+type Arguments struct {
+	StreamName           string              `river:"stream_name,attr"`
+	StartingPosition     string              `river:"starting_position,attr,optional"`
+	PollInterval         time.Duration       `river:"poll_interval,attr,optional"`
+	ShardRefreshInterval time.Duration       `river:"shard_refresh_interval,attr,optional"`
+	Labels               map[string]string   `river:"labels,attr,optional"`
+	RelabelRules         flow_relabel.Rules  `river:"relabel_rules,attr,optional"`
+	Receivers            []loki.LogsReceiver `river:"forward_to,attr"`
+
+	Client Client `river:"client,block,optional"`
+}
+
+// Client configures how to connect and authenticate to Amazon Kinesis Data
+// Streams.
+type Client struct {
+	AccessKey string            `river:"key,attr,optional"`
+	Secret    rivertypes.Secret `river:"secret,attr,optional"`
+	Region    string            `river:"region,attr,optional"`
+	RoleARN   string            `river:"role_arn,attr,optional"`
+	Endpoint  string            `river:"endpoint,attr,optional"`
+}
+
+// DefaultArguments holds default settings for Arguments.
+var DefaultArguments = Arguments{
+	StartingPosition:     startingPositionLatest,
+	PollInterval:         time.Second,
+	ShardRefreshInterval: time.Minute,
+}
+
+// SetToDefault implements river.Defaulter.
+func (a *Arguments) SetToDefault() {
+	*a = DefaultArguments
+}
+
+// Validate implements river.Validator.
+func (a *Arguments) Validate() error {
+	if a.StreamName == "" {
+		return fmt.Errorf("stream_name must not be empty")
+	}
+	switch a.StartingPosition {
+	case startingPositionLatest, startingPositionTrimHorizon:
+		// no-op
+	default:
+		return fmt.Errorf("unrecognized starting_position %q, expected one of %s,%s", a.StartingPosition, startingPositionLatest, startingPositionTrimHorizon)
+	}
+	if a.PollInterval <= 0 {
+		return fmt.Errorf("poll_interval must be greater than 0")
+	}
+	if a.ShardRefreshInterval <= 0 {
+		return fmt.Errorf("shard_refresh_interval must be greater than 0")
+	}
+	if (a.Client.AccessKey == "") != (a.Client.Secret == "") {
+		return fmt.Errorf("if key or secret are specified then the other must also be specified")
+	}
+	return nil
+}
+
+// client creates a Kinesis client from the arguments. Credentials which
+// aren't set in the client block are taken from the default AWS credential
+// chain.
+func (a *Arguments) client(ctx context.Context) (kinesisAPI, error) {
+	var configOptions []func(*aws_config.LoadOptions) error
+	if a.Client.AccessKey != "" {
+		credFunc := aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+			return aws.Credentials{
+				AccessKeyID:     a.Client.AccessKey,
+				SecretAccessKey: string(a.Client.Secret),
+			}, nil
+		})
+		configOptions = append(configOptions, aws_config.WithCredentialsProvider(credFunc))
+	}
+	if a.Client.Region != "" {
+		configOptions = append(configOptions, aws_config.WithRegion(a.Client.Region))
+	}
+
+	cfg, err := aws_config.LoadDefaultConfig(ctx, configOptions...)
+	if err != nil {
+		return nil, err
+	}
+
+	if a.Client.RoleARN != "" {
+		provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cfg), a.Client.RoleARN)
+		cfg.Credentials = aws.NewCredentialsCache(provider)
+	}
+
+	return kinesis.NewFromConfig(cfg, func(o *kinesis.Options) {
+		if a.Client.Endpoint != "" {
+			o.BaseEndpoint = aws.String(a.Client.Endpoint)
+		}
+	}), nil
+}
+
+var (
+	_ component.Component       = (*Component)(nil)
+	_ component.HealthComponent = (*Component)(nil)
+)
+
+// Component implements the loki.source.aws_kinesis component.
+type Component struct {
+	opts      component.Options
+	metrics   *metrics
+	handler   chan loki.Entry
+	positions positions.Positions
+
+	mut       sync.RWMutex
+	r         *streamReader
+	receivers []loki.LogsReceiver
+}
+
+// New creates a new loki.source.aws_kinesis component.
+func New(o component.Options, args Arguments) (*Component, error) {
+	err := os.MkdirAll(o.DataPath, 0750)
+	if err != nil {
+		return nil, err
+	}
+
+	positionsFile, err := positions.New(o.Logger, positions.Config{
+		SyncPeriod:        10 * time.Second,
+		PositionsFile:     filepath.Join(o.DataPath, "positions.yml"),
+		IgnoreInvalidYaml: false,
+		ReadOnly:          false,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Component{
+		opts:      o,
+		metrics:   newMetrics(o.Registerer),
+		handler:   make(chan loki.Entry),
+		positions: positionsFile,
+	}
+	if err := c.Update(args); err != nil {
+		positionsFile.Stop()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Run implements component.Component.
+func (c *Component) Run(ctx context.Context) error {
+	defer func() {
+		c.mut.Lock()
+		if c.r != nil {
+			c.r.Stop()
+		}
+		c.mut.Unlock()
+		c.positions.Stop()
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case entry := <-c.handler:
+			c.mut.RLock()
+			for _, receiver := range c.receivers {
+				receiver.Chan() <- entry
+			}
+			c.mut.RUnlock()
+		}
+	}
+}
+
+// Update implements component.Component.
+func (c *Component) Update(args component.Arguments) error {
+	newArgs := args.(Arguments)
+
+	client, err := newArgs.client(context.Background())
+	if err != nil {
+		return err
+	}
+
+	labels := model.LabelSet{
+		model.LabelName("job"): model.LabelValue(c.opts.ID),
+	}
+	for k, v := range newArgs.Labels {
+		labels[model.LabelName(k)] = model.LabelValue(v)
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	// Stop the previous reader before starting a new one so that both don't
+	// read the same shards at the same time.
+	if c.r != nil {
+		c.r.Stop()
+	}
+
+	c.receivers = newArgs.Receivers
+	c.r = newStreamReader(streamReaderConfig{
+		Logger:               c.opts.Logger,
+		Client:               client,
+		StreamName:           newArgs.StreamName,
+		TrimHorizon:          newArgs.StartingPosition == startingPositionTrimHorizon,
+		PollInterval:         newArgs.PollInterval,
+		ShardRefreshInterval: newArgs.ShardRefreshInterval,
+		Labels:               labels,
+		RelabelConfig:        flow_relabel.ComponentToPromRelabelConfigs(newArgs.RelabelRules),
+	}, c.positions, c.metrics, c.handler)
+	return nil
+}
+
+// CurrentHealth implements component.HealthComponent. The component is
+// reported as unhealthy while the shards of the stream can't be listed or
+// read.
+func (c *Component) CurrentHealth() component.Health {
+	c.mut.RLock()
+	defer c.mut.RUnlock()
+	if c.r == nil {
+		return component.Health{}
+	}
+	return c.r.CurrentHealth()
+}
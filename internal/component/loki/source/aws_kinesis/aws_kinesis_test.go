@@ -0,0 +1,250 @@
+package aws_kinesis //nolint:golint
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis/types"
+	"github.com/grafana/agent/internal/component/common/loki"
+	"github.com/grafana/agent/internal/component/common/loki/positions"
+	"github.com/grafana/agent/internal/util"
+	"github.com/grafana/river"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeKinesis is an in-memory fake of the Kinesis API. The sequence number
+// of a record is its index in its shard.
+type fakeKinesis struct {
+	mut    sync.Mutex
+	shards []*fakeShard
+}
+
+type fakeShard struct {
+	id, parentID string
+	records      []string
+	closed       bool
+}
+
+var _ kinesisAPI = (*fakeKinesis)(nil)
+
+func (f *fakeKinesis) AddShard(id, parentID string, closed bool, records ...string) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	f.shards = append(f.shards, &fakeShard{id: id, parentID: parentID, records: records, closed: closed})
+}
+
+func (f *fakeKinesis) AddRecords(id string, records ...string) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	shard := f.shard(id)
+	shard.records = append(shard.records, records...)
+}
+
+func (f *fakeKinesis) shard(id string) *fakeShard {
+	for _, shard := range f.shards {
+		if shard.id == id {
+			return shard
+		}
+	}
+	return nil
+}
+
+func (f *fakeKinesis) ListShards(_ context.Context, params *kinesis.ListShardsInput, _ ...func(*kinesis.Options)) (*kinesis.ListShardsOutput, error) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	// Return one shard per page to exercise pagination.
+	start := 0
+	if params.NextToken != nil {
+		start, _ = strconv.Atoi(*params.NextToken)
+	}
+
+	out := &kinesis.ListShardsOutput{}
+	if start < len(f.shards) {
+		shard := f.shards[start]
+		s := types.Shard{ShardId: aws.String(shard.id)}
+		if shard.parentID != "" {
+			s.ParentShardId = aws.String(shard.parentID)
+		}
+		out.Shards = []types.Shard{s}
+	}
+	if start+1 < len(f.shards) {
+		out.NextToken = aws.String(strconv.Itoa(start + 1))
+	}
+	return out, nil
+}
+
+func (f *fakeKinesis) GetShardIterator(_ context.Context, params *kinesis.GetShardIteratorInput, _ ...func(*kinesis.Options)) (*kinesis.GetShardIteratorOutput, error) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	shard := f.shard(aws.ToString(params.ShardId))
+	if shard == nil {
+		return nil, fmt.Errorf("shard %s not found", aws.ToString(params.ShardId))
+	}
+
+	var index int
+	switch params.ShardIteratorType {
+	case types.ShardIteratorTypeTrimHorizon:
+		index = 0
+	case types.ShardIteratorTypeLatest:
+		index = len(shard.records)
+	case types.ShardIteratorTypeAfterSequenceNumber:
+		seq, err := strconv.Atoi(aws.ToString(params.StartingSequenceNumber))
+		if err != nil {
+			return nil, err
+		}
+		index = seq + 1
+	default:
+		return nil, fmt.Errorf("unsupported iterator type %s", params.ShardIteratorType)
+	}
+	return &kinesis.GetShardIteratorOutput{ShardIterator: aws.String(fmt.Sprintf("%s:%d", shard.id, index))}, nil
+}
+
+func (f *fakeKinesis) GetRecords(_ context.Context, params *kinesis.GetRecordsInput, _ ...func(*kinesis.Options)) (*kinesis.GetRecordsOutput, error) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	id, indexStr, _ := strings.Cut(aws.ToString(params.ShardIterator), ":")
+	index, _ := strconv.Atoi(indexStr)
+	shard := f.shard(id)
+
+	out := &kinesis.GetRecordsOutput{MillisBehindLatest: aws.Int64(0)}
+	for i := index; i < len(shard.records); i++ {
+		out.Records = append(out.Records, types.Record{
+			Data:           []byte(shard.records[i]),
+			PartitionKey:   aws.String("key"),
+			SequenceNumber: aws.String(strconv.Itoa(i)),
+		})
+	}
+	if !shard.closed {
+		out.NextShardIterator = aws.String(fmt.Sprintf("%s:%d", id, len(shard.records)))
+	}
+	return out, nil
+}
+
+func newTestStreamReader(t *testing.T, client kinesisAPI, pos positions.Positions, m *metrics, handler chan loki.Entry) *streamReader {
+	r := newStreamReader(streamReaderConfig{
+		Logger:               util.TestFlowLogger(t),
+		Client:               client,
+		StreamName:           "logs",
+		TrimHorizon:          true,
+		PollInterval:         10 * time.Millisecond,
+		ShardRefreshInterval: 50 * time.Millisecond,
+		Labels:               model.LabelSet{"job": "test"},
+	}, pos, m, handler)
+	t.Cleanup(r.Stop)
+	return r
+}
+
+func receiveLines(t *testing.T, handler chan loki.Entry, n int) []string {
+	t.Helper()
+
+	var lines []string
+	for len(lines) < n {
+		select {
+		case e := <-handler:
+			require.Equal(t, model.LabelSet{"job": "test"}, e.Labels)
+			lines = append(lines, e.Line)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "timed out waiting for records", "received %v", lines)
+		}
+	}
+	return lines
+}
+
+func TestStreamReader(t *testing.T) {
+	pos, err := positions.New(util.TestLogger(t), positions.Config{
+		SyncPeriod:    time.Minute,
+		PositionsFile: filepath.Join(t.TempDir(), "positions.yml"),
+	})
+	require.NoError(t, err)
+	defer pos.Stop()
+
+	// shard-0 was split into shard-1 and shard-2.
+	client := &fakeKinesis{}
+	client.AddShard("shard-0", "", true, "parent-1", "parent-2")
+	client.AddShard("shard-1", "shard-0", false, "child-1")
+	client.AddShard("shard-2", "shard-0", false, "child-2")
+
+	var (
+		m       = newMetrics(prometheus.NewRegistry())
+		handler = make(chan loki.Entry)
+		r       = newTestStreamReader(t, client, pos, m, handler)
+	)
+
+	// The records of the parent shard are read before the ones of its
+	// children.
+	lines := receiveLines(t, handler, 4)
+	require.Equal(t, []string{"parent-1", "parent-2"}, lines[:2])
+	require.ElementsMatch(t, []string{"child-1", "child-2"}, lines[2:])
+
+	require.Equal(t, 2.0, testutil.ToFloat64(m.records.WithLabelValues("shard-0")))
+	require.Eventually(t, func() bool {
+		return r.shardFinished("shard-0")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Shards are read again from their saved position.
+	r.Stop()
+	client.AddRecords("shard-1", "child-3")
+	newTestStreamReader(t, client, pos, m, handler)
+	require.Equal(t, []string{"child-3"}, receiveLines(t, handler, 1))
+
+	// New shards are discovered while the stream is read.
+	client.AddShard("shard-3", "", false, "new-1")
+	require.Equal(t, []string{"new-1"}, receiveLines(t, handler, 1))
+	require.Equal(t, 3.0, testutil.ToFloat64(m.shards))
+}
+
+func TestArguments_Validate(t *testing.T) {
+	tt := []struct {
+		name, cfg, expectErr string
+	}{
+		{
+			name:      "missing stream name",
+			cfg:       `stream_name = ""`,
+			expectErr: "stream_name must not be empty",
+		},
+		{
+			name: "unknown starting position",
+			cfg: `
+				stream_name       = "logs"
+				starting_position = "oldest"
+			`,
+			expectErr: `unrecognized starting_position "oldest", expected one of latest,trim_horizon`,
+		},
+		{
+			name: "key without secret",
+			cfg: `
+				stream_name = "logs"
+				client {
+					key = "access-key"
+				}
+			`,
+			expectErr: "if key or secret are specified then the other must also be specified",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := fmt.Sprintf(`
+				forward_to = []
+				%s
+			`, tc.cfg)
+
+			var args Arguments
+			require.EqualError(t, river.Unmarshal([]byte(cfg), &args), tc.expectErr)
+		})
+	}
+}
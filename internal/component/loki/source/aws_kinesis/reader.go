@@ -0,0 +1,407 @@
+package aws_kinesis //nolint:golint
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis/types"
+	"github.com/go-kit/log"
+	"github.com/grafana/agent/internal/component"
+	"github.com/grafana/agent/internal/component/common/loki"
+	"github.com/grafana/agent/internal/component/common/loki/positions"
+	"github.com/grafana/agent/internal/flow/logging/level"
+	"github.com/grafana/dskit/backoff"
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/relabel"
+)
+
+// kinesisAPI is the subset of the Kinesis client used by the component.
+type kinesisAPI interface {
+	ListShards(ctx context.Context, params *kinesis.ListShardsInput, optFns ...func(*kinesis.Options)) (*kinesis.ListShardsOutput, error)
+	GetShardIterator(ctx context.Context, params *kinesis.GetShardIteratorInput, optFns ...func(*kinesis.Options)) (*kinesis.GetShardIteratorOutput, error)
+	GetRecords(ctx context.Context, params *kinesis.GetRecordsInput, optFns ...func(*kinesis.Options)) (*kinesis.GetRecordsOutput, error)
+}
+
+// retryBackoff is the backoff used when reading a shard fails.
+var retryBackoff = backoff.Config{
+	MinBackoff: time.Second,
+	MaxBackoff: time.Minute,
+}
+
+const (
+	// minReadInterval is the minimum delay between two reads of a shard.
+	// Kinesis allows up to five reads per second and shard.
+	minReadInterval = 200 * time.Millisecond
+
+	// shardEnd is saved as the position of shards which were closed by
+	// resharding and read to their end.
+	shardEnd = "SHARD_END"
+)
+
+type metrics struct {
+	records     *prometheus.CounterVec
+	iteratorAge *prometheus.GaugeVec
+	errors      *prometheus.CounterVec
+	shards      prometheus.Gauge
+}
+
+func newMetrics(reg prometheus.Registerer) *metrics {
+	var m metrics
+
+	m.records = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "loki_source_aws_kinesis_records_total",
+		Help: "Total number of records read from Kinesis shards",
+	}, []string{"shard_id"})
+	m.iteratorAge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "loki_source_aws_kinesis_iterator_age_milliseconds",
+		Help: "Time between the last record read from a shard and the newest record of the shard",
+	}, []string{"shard_id"})
+	m.errors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "loki_source_aws_kinesis_read_errors_total",
+		Help: "Total number of failed reads of Kinesis shards",
+	}, []string{"shard_id"})
+	m.shards = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "loki_source_aws_kinesis_shards_active",
+		Help: "Number of Kinesis shards being read",
+	})
+
+	if reg != nil {
+		reg.MustRegister(m.records, m.iteratorAge, m.errors, m.shards)
+	}
+	return &m
+}
+
+// streamReaderConfig configures a streamReader.
+type streamReaderConfig struct {
+	Logger     log.Logger
+	Client     kinesisAPI
+	StreamName string
+	// TrimHorizon starts reading shards without a saved position from their
+	// oldest record instead of their newest one.
+	TrimHorizon          bool
+	PollInterval         time.Duration
+	ShardRefreshInterval time.Duration
+	Labels               model.LabelSet
+	RelabelConfig        []*relabel.Config
+}
+
+// streamReader reads the records of every shard of a Kinesis stream and
+// sends them to a handler. Shards are discovered again periodically, so that
+// shards created by resharding are read once their parents were read to
+// their end.
+type streamReader struct {
+	cfg       streamReaderConfig
+	positions positions.Positions
+	metrics   *metrics
+	handler   chan<- loki.Entry
+
+	cancel   context.CancelFunc
+	done     chan struct{}
+	wg       sync.WaitGroup // Running shard readers.
+	finished chan string    // Shards read to their end.
+
+	healthMut sync.RWMutex
+	health    component.Health
+}
+
+// newStreamReader creates a streamReader and starts reading the stream in the
+// background.
+func newStreamReader(cfg streamReaderConfig, positions positions.Positions, metrics *metrics, handler chan<- loki.Entry) *streamReader {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &streamReader{
+		cfg:       cfg,
+		positions: positions,
+		metrics:   metrics,
+		handler:   handler,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+		finished:  make(chan string),
+	}
+	go r.run(ctx)
+	return r
+}
+
+func (r *streamReader) run(ctx context.Context) {
+	defer close(r.done)
+	defer r.wg.Wait()
+
+	ticker := time.NewTicker(r.cfg.ShardRefreshInterval)
+	defer ticker.Stop()
+
+	reading := make(map[string]struct{})
+	r.refreshShards(ctx, reading)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.refreshShards(ctx, reading)
+		case shardID := <-r.finished:
+			// The children of the shard can be read now.
+			delete(reading, shardID)
+			r.refreshShards(ctx, reading)
+		}
+	}
+}
+
+// refreshShards lists the shards of the stream and starts reading the shards
+// which aren't in reading yet.
+func (r *streamReader) refreshShards(ctx context.Context, reading map[string]struct{}) {
+	shards, err := r.listShards(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			level.Error(r.cfg.Logger).Log("msg", "failed to list shards", "stream", r.cfg.StreamName, "err", err)
+			r.setHealth(component.HealthTypeUnhealthy, fmt.Sprintf("failed to list shards: %s", err))
+		}
+		return
+	}
+
+	listed := make(map[string]struct{}, len(shards))
+	for _, shard := range shards {
+		listed[aws.ToString(shard.ShardId)] = struct{}{}
+	}
+
+	for _, shard := range shards {
+		shardID := aws.ToString(shard.ShardId)
+		if _, ok := reading[shardID]; ok || r.shardFinished(shardID) {
+			continue
+		}
+
+		// Children are only read once their parents were read to their end,
+		// so that the records of each partition key are read in order. Their
+		// records follow the ones of their parents, so they're read from their
+		// oldest record.
+		iteratorType := types.ShardIteratorTypeLatest
+		if r.cfg.TrimHorizon {
+			iteratorType = types.ShardIteratorTypeTrimHorizon
+		}
+		pending := false
+		for _, parentID := range []*string{shard.ParentShardId, shard.AdjacentParentShardId} {
+			if _, ok := listed[aws.ToString(parentID)]; !ok {
+				continue
+			}
+			iteratorType = types.ShardIteratorTypeTrimHorizon
+			if !r.shardFinished(aws.ToString(parentID)) {
+				pending = true
+			}
+		}
+		if pending {
+			continue
+		}
+
+		level.Info(r.cfg.Logger).Log("msg", "reading shard", "stream", r.cfg.StreamName, "shard_id", shardID)
+		reading[shardID] = struct{}{}
+		r.wg.Add(1)
+		go r.readShard(ctx, shardID, iteratorType)
+	}
+
+	r.metrics.shards.Set(float64(len(reading)))
+	r.setHealth(component.HealthTypeHealthy, fmt.Sprintf("reading %d shards", len(reading)))
+}
+
+// listShards returns every shard of the stream.
+func (r *streamReader) listShards(ctx context.Context) ([]types.Shard, error) {
+	var (
+		shards []types.Shard
+		input  = &kinesis.ListShardsInput{StreamName: aws.String(r.cfg.StreamName)}
+	)
+	for {
+		out, err := r.cfg.Client.ListShards(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		shards = append(shards, out.Shards...)
+		if out.NextToken == nil {
+			return shards, nil
+		}
+		// The stream name must not be set along with a token.
+		input = &kinesis.ListShardsInput{NextToken: out.NextToken}
+	}
+}
+
+// shardFinished returns true if shardID was read to its end.
+func (r *streamReader) shardFinished(shardID string) bool {
+	pos, _ := r.positions.GetCursor(r.positionKey(shardID))
+	return pos == shardEnd
+}
+
+func (r *streamReader) positionKey(shardID string) string {
+	return r.cfg.StreamName + "/" + shardID
+}
+
+// readShard reads the records of a shard until ctx is canceled or the shard
+// is read to its end. Shards without a saved position start being read at
+// iteratorType.
+func (r *streamReader) readShard(ctx context.Context, shardID string, iteratorType types.ShardIteratorType) {
+	defer r.wg.Done()
+
+	var (
+		bo       = backoff.New(ctx, retryBackoff)
+		iterator *string
+	)
+	for bo.Ongoing() {
+		if iterator == nil {
+			var err error
+			iterator, err = r.shardIterator(ctx, shardID, iteratorType)
+			if err != nil {
+				r.readFailed(ctx, shardID, err)
+				bo.Wait()
+				continue
+			}
+		}
+
+		out, err := r.cfg.Client.GetRecords(ctx, &kinesis.GetRecordsInput{ShardIterator: iterator})
+		if err != nil {
+			// Iterators expire after five minutes, so a new one is requested
+			// from the saved position.
+			iterator = nil
+			r.readFailed(ctx, shardID, err)
+			bo.Wait()
+			continue
+		}
+		if bo.NumRetries() > 0 {
+			r.setHealth(component.HealthTypeHealthy, fmt.Sprintf("reading shard %s again", shardID))
+		}
+		bo.Reset()
+
+		if out.MillisBehindLatest != nil {
+			r.metrics.iteratorAge.WithLabelValues(shardID).Set(float64(*out.MillisBehindLatest))
+		}
+		for _, record := range out.Records {
+			if !r.handleRecord(ctx, shardID, record) {
+				return
+			}
+		}
+
+		if out.NextShardIterator == nil {
+			level.Info(r.cfg.Logger).Log("msg", "shard was read to its end", "stream", r.cfg.StreamName, "shard_id", shardID)
+			r.positions.PutCursor(r.positionKey(shardID), shardEnd)
+			r.metrics.iteratorAge.DeleteLabelValues(shardID)
+			select {
+			case <-ctx.Done():
+			case r.finished <- shardID:
+			}
+			return
+		}
+		iterator = out.NextShardIterator
+
+		wait := minReadInterval
+		if len(out.Records) == 0 {
+			wait = max(wait, r.cfg.PollInterval)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// shardIterator returns an iterator which starts after the saved position of
+// the shard, or at iteratorType if the shard has no saved position.
+func (r *streamReader) shardIterator(ctx context.Context, shardID string, iteratorType types.ShardIteratorType) (*string, error) {
+	input := &kinesis.GetShardIteratorInput{
+		StreamName:        aws.String(r.cfg.StreamName),
+		ShardId:           aws.String(shardID),
+		ShardIteratorType: iteratorType,
+	}
+	if seq, ok := r.positions.GetCursor(r.positionKey(shardID)); ok {
+		input.ShardIteratorType = types.ShardIteratorTypeAfterSequenceNumber
+		input.StartingSequenceNumber = aws.String(seq)
+	}
+
+	out, err := r.cfg.Client.GetShardIterator(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get shard iterator: %w", err)
+	}
+	return out.ShardIterator, nil
+}
+
+func (r *streamReader) readFailed(ctx context.Context, shardID string, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+	r.metrics.errors.WithLabelValues(shardID).Inc()
+	level.Error(r.cfg.Logger).Log("msg", "failed to read shard", "stream", r.cfg.StreamName, "shard_id", shardID, "err", err)
+	r.setHealth(component.HealthTypeUnhealthy, fmt.Sprintf("failed to read shard %s: %s", shardID, err))
+}
+
+// handleRecord sends record to the handler and saves its sequence number. It
+// returns false if ctx was canceled before the record could be sent.
+func (r *streamReader) handleRecord(ctx context.Context, shardID string, record types.Record) bool {
+	recordLabels := map[string]string{
+		"__aws_kinesis_stream":        r.cfg.StreamName,
+		"__aws_kinesis_shard_id":      shardID,
+		"__aws_kinesis_partition_key": aws.ToString(record.PartitionKey),
+	}
+	for k, v := range r.cfg.Labels {
+		recordLabels[string(k)] = string(v)
+	}
+
+	processedLabels, _ := relabel.Process(labels.FromMap(recordLabels), r.cfg.RelabelConfig...)
+
+	lbls := make(model.LabelSet, processedLabels.Len())
+	processedLabels.Range(func(l labels.Label) {
+		if strings.HasPrefix(l.Name, "__") {
+			return
+		}
+		lbls[model.LabelName(l.Name)] = model.LabelValue(l.Value)
+	})
+
+	ts := time.Now()
+	if record.ApproximateArrivalTimestamp != nil {
+		ts = *record.ApproximateArrivalTimestamp
+	}
+
+	r.metrics.records.WithLabelValues(shardID).Inc()
+
+	// Records left without labels are dropped.
+	if len(lbls) > 0 {
+		select {
+		case <-ctx.Done():
+			return false
+		case r.handler <- loki.Entry{
+			Labels: lbls,
+			Entry: logproto.Entry{
+				Line:      string(record.Data),
+				Timestamp: ts,
+			},
+		}:
+		}
+	}
+
+	r.positions.PutCursor(r.positionKey(shardID), aws.ToString(record.SequenceNumber))
+	return true
+}
+
+func (r *streamReader) setHealth(ty component.HealthType, msg string) {
+	r.healthMut.Lock()
+	defer r.healthMut.Unlock()
+	r.health = component.Health{
+		Health:     ty,
+		Message:    msg,
+		UpdateTime: time.Now(),
+	}
+}
+
+// CurrentHealth returns the health of the streamReader.
+func (r *streamReader) CurrentHealth() component.Health {
+	r.healthMut.RLock()
+	defer r.healthMut.RUnlock()
+	return r.health
+}
+
+// Stop stops the streamReader and waits for it and its shard readers to
+// exit.
+func (r *streamReader) Stop() {
+	r.cancel()
+	<-r.done
+}
@@ -0,0 +1,65 @@
+package file
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/grafana/agent/internal/component/common/loki/positions"
+	"github.com/spf13/cobra"
+)
+
+// InstallTools installs command line utilities as subcommands of the provided
+// cmd.
+func InstallTools(cmd *cobra.Command) {
+	cmd.AddCommand(
+		migratePositionsCmd(),
+	)
+}
+
+func migratePositionsCmd() *cobra.Command {
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "migrate-positions [positions file] [old prefix] [new prefix]",
+		Short: "Rewrite the paths of the files in a positions file",
+		Long: `migrate-positions rewrites the paths of the entries of a positions file which
+start with the old prefix, replacing it with the new prefix. Read offsets and
+labels are preserved, so files which moved to a new directory are resumed from
+where they were instead of being read again from the beginning.
+
+The positions file is replaced atomically. Grafana Agent must not be running
+while the positions file is migrated.
+
+Examples:
+
+Show the entries which would be rewritten:
+
+migrate-positions --dry-run data/loki.source.file.logs/positions.yml /var/log/app/ /mnt/logs/app/
+
+
+Rewrite the entries:
+
+migrate-positions data/loki.source.file.logs/positions.yml /var/log/app/ /mnt/logs/app/
+`,
+		Args: cobra.ExactArgs(3),
+		Run: func(_ *cobra.Command, args []string) {
+			migrations, err := positions.Migrate(args[0], args[1], args[2], dryRun)
+			if err != nil {
+				fmt.Printf("failed to migrate positions file: %v\n", err)
+				os.Exit(1)
+			}
+
+			for _, m := range migrations {
+				fmt.Printf("%s %s -> %s\n", m.Entry.Path, m.Entry.Labels, m.NewPath)
+			}
+			if dryRun {
+				fmt.Printf("%d entries would be migrated\n", len(migrations))
+			} else {
+				fmt.Printf("%d entries migrated\n", len(migrations))
+			}
+		},
+	}
+
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "list the entries which would be migrated without changing the positions file")
+	return cmd
+}
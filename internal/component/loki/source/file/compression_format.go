@@ -3,6 +3,7 @@ package file
 import (
 	"encoding"
 	"fmt"
+	"path/filepath"
 	"strings"
 
 	"golang.org/x/exp/maps"
@@ -10,6 +11,18 @@ import (
 
 type CompressionFormat string
 
+// autoCompressionFormat detects the compression format of each file from its
+// extension. Files without a known extension are tailed as plain text.
+const autoCompressionFormat CompressionFormat = "auto"
+
+// compressedExtensions maps file extensions to the compression format used to
+// read them when the format is auto.
+var compressedExtensions = map[string]CompressionFormat{
+	".gz":  "gz",
+	".z":   "z",
+	".bz2": "bz2",
+}
+
 var (
 	_ encoding.TextMarshaler   = CompressionFormat("")
 	_ encoding.TextUnmarshaler = (*CompressionFormat)(nil)
@@ -28,13 +41,25 @@ func (ut CompressionFormat) MarshalText() (text []byte, err error) {
 func (ut *CompressionFormat) UnmarshalText(text []byte) error {
 	s := string(text)
 	_, ok := supportedCompressedFormats()[s]
-	if !ok {
+	if !ok && CompressionFormat(s) != autoCompressionFormat {
 		return fmt.Errorf(
-			"unsupported compression format: %q - please use one of %q",
+			"unsupported compression format: %q - please use one of %q or %q",
 			s,
 			strings.Join(maps.Keys(supportedCompressedFormats()), ", "),
+			autoCompressionFormat,
 		)
 	}
 	*ut = CompressionFormat(s)
 	return nil
 }
+
+// formatForPath returns the compression format to read path with. If ut is
+// auto, the format is detected from the extension of path, and ok is false
+// when path doesn't look like a compressed file.
+func (ut CompressionFormat) formatForPath(path string) (format CompressionFormat, ok bool) {
+	if ut != autoCompressionFormat {
+		return ut, true
+	}
+	format, ok = compressedExtensions[strings.ToLower(filepath.Ext(path))]
+	return format, ok
+}
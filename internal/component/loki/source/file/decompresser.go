@@ -16,6 +16,7 @@ import (
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 	"unsafe"
 
 	"github.com/go-kit/log"
@@ -58,6 +59,9 @@ type decompressor struct {
 
 	decoder *encoding.Decoder
 
+	// lastEncodingErr holds the last error encountered while decoding a line.
+	lastEncodingErr atomic.Error
+
 	position int64
 	size     int64
 	cfg      DecompressionConfig
@@ -239,10 +243,16 @@ func (d *decompressor) readLines() {
 			if err != nil {
 				level.Debug(d.logger).Log("msg", "failed to convert encoding", "error", err)
 				d.metrics.encodingFailures.WithLabelValues(d.path).Inc()
+				d.lastEncodingErr.Store(err)
 				finalText = fmt.Sprintf("the requested encoding conversion for this line failed in Grafana Agent: %s", err.Error())
 			}
 		} else {
 			finalText = text
+			if !utf8.ValidString(finalText) {
+				level.Debug(d.logger).Log("msg", "line contains invalid UTF-8", "path", d.path)
+				d.metrics.encodingFailures.WithLabelValues(d.path).Inc()
+				d.lastEncodingErr.Store(errInvalidUTF8)
+			}
 		}
 
 		d.metrics.readLines.WithLabelValues(d.path).Inc()
@@ -296,6 +306,12 @@ func (d *decompressor) IsRunning() bool {
 	return d.running.Load()
 }
 
+// LastEncodingError returns the last error encountered while decoding a line,
+// if any.
+func (d *decompressor) LastEncodingError() error {
+	return d.lastEncodingErr.Load()
+}
+
 func (d *decompressor) convertToUTF8(text string) (string, error) {
 	res, _, err := transform.String(d.decoder, text)
 	if err != nil {
@@ -4,7 +4,9 @@ package file
 // of the reader interface.
 
 import (
+	"compress/gzip"
 	"os"
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
@@ -13,6 +15,7 @@ import (
 
 	"github.com/go-kit/log"
 	"github.com/grafana/agent/internal/component/common/loki"
+	"github.com/grafana/agent/internal/component/common/loki/positions"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/atomic"
@@ -188,3 +191,61 @@ func TestOnelineFiles(t *testing.T) {
 		require.Contains(t, firstEntry.Line, `5.202.214.160 - - [26/Jan/2019:19:45:25 +0330] "GET / HTTP/1.1" 200 30975 "https://www.zanbil.ir/" "Mozilla/5.0 (Windows NT 6.2; WOW64; rv:21.0) Gecko/20100101 Firefox/21.0" "-"`)
 	})
 }
+
+// TestDecompressorResume checks that a partially read compressed file is
+// resumed from the line stored in the positions file, and that a fully read
+// file isn't read again.
+func TestDecompressorResume(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log.1.gz")
+	writeGzipFile(t, path, "line1", "line2", "line3", "line4", "line5")
+
+	ps, err := positions.New(log.NewNopLogger(), positions.Config{
+		SyncPeriod:    10 * time.Second,
+		PositionsFile: filepath.Join(dir, "positions.yml"),
+	})
+	require.NoError(t, err)
+	defer ps.Stop()
+
+	// Pretend the first two lines were read before a restart.
+	ps.Put(path, "{}", 2)
+
+	readAll := func() []string {
+		handler := fake.NewClient(func() {})
+
+		d, err := newDecompressor(newMetrics(prometheus.NewRegistry()), log.NewNopLogger(), handler, ps, path, "{}", "", DecompressionConfig{Enabled: true, Format: "gz"})
+		require.NoError(t, err)
+		<-d.done
+		d.Stop()
+		handler.Stop()
+
+		var lines []string
+		for _, e := range handler.Received() {
+			lines = append(lines, e.Line)
+		}
+		return lines
+	}
+
+	require.Equal(t, []string{"line3", "line4", "line5"}, readAll())
+
+	pos, err := ps.Get(path, "{}")
+	require.NoError(t, err)
+	require.Equal(t, int64(5), pos)
+
+	require.Empty(t, readAll())
+}
+
+func writeGzipFile(t *testing.T, path string, lines ...string) {
+	t.Helper()
+
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	w := gzip.NewWriter(f)
+	for _, line := range lines {
+		_, err = w.Write([]byte(line + "\n"))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+}
@@ -0,0 +1,117 @@
+package file
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/grafana/agent/internal/component/common/loki"
+)
+
+// dedupFinalEntryTimeout is how long a dedup handler waits for its pending
+// entry to be forwarded when it's stopped.
+const dedupFinalEntryTimeout = 5 * time.Second
+
+// newDedupHandler returns an entry handler which collapses consecutive
+// entries with identical lines received within window into a single entry.
+// The first entry of a run is held back until a different line is received
+// or window has elapsed since it was received. If the run contains more than
+// one entry, the number of collapsed entries is appended to its line.
+//
+// onDrop is called for every entry which is collapsed into a previous one.
+// Stopping the returned handler forwards the pending entry and stops next.
+func newDedupHandler(next loki.EntryHandler, window time.Duration, onDrop func()) loki.EntryHandler {
+	var (
+		ctx, cancel = context.WithCancel(context.Background())
+
+		in       = make(chan loki.Entry)
+		nextChan = next.Chan()
+	)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+		defer cancel()
+
+		var (
+			pending *loki.Entry
+			count   int
+
+			timer  = time.NewTimer(window)
+			expire <-chan time.Time
+		)
+		timer.Stop()
+		defer timer.Stop()
+
+		// flush forwards the pending entry, if any. It returns false if the
+		// handler was stopped while forwarding it.
+		flush := func() bool {
+			if pending == nil {
+				return true
+			}
+			e := *pending
+			if count > 1 {
+				e.Line = fmt.Sprintf("%s (repeated %d times)", e.Line, count)
+			}
+			pending, count, expire = nil, 0, nil
+
+			select {
+			case <-ctx.Done():
+				return false
+			case nextChan <- e:
+				return true
+			}
+		}
+
+		for {
+			select {
+			case e, ok := <-in:
+				if !ok {
+					flush()
+					return
+				}
+				if pending != nil && pending.Line == e.Line {
+					count++
+					onDrop()
+					continue
+				}
+				if !flush() {
+					return
+				}
+				pending, count = &e, 1
+				if !timer.Stop() {
+					// Drain an expiry of the previous entry which wasn't received.
+					select {
+					case <-timer.C:
+					default:
+					}
+				}
+				timer.Reset(window)
+				expire = timer.C
+
+			case <-expire:
+				if !flush() {
+					return
+				}
+			}
+		}
+	}()
+
+	var closeOnce sync.Once
+	return loki.NewEntryHandler(in, func() {
+		closeOnce.Do(func() {
+			close(in)
+
+			select {
+			case <-ctx.Done():
+			case <-time.After(dedupFinalEntryTimeout):
+				cancel()
+			}
+			wg.Wait()
+			next.Stop()
+		})
+	})
+}
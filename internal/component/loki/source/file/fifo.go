@@ -0,0 +1,231 @@
+package file
+
+// fifoReader implements the reader interface and is used to read named pipes.
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/agent/internal/component/common/loki"
+	"github.com/grafana/agent/internal/flow/logging/level"
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/prometheus/common/model"
+	"go.uber.org/atomic"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/ianaindex"
+	"golang.org/x/text/transform"
+)
+
+// fifoReader reads lines from a named pipe. Named pipes can't be seeked, so
+// no position is stored for them. The pipe is reopened every time all of its
+// writers close it.
+type fifoReader struct {
+	metrics *metrics
+	logger  log.Logger
+	handler loki.EntryHandler
+	path    string
+	decoder *encoding.Decoder
+
+	// reopenWait is how long to wait before reopening the pipe after reaching
+	// the end of its data.
+	reopenWait time.Duration
+
+	running         *atomic.Bool
+	lastEncodingErr atomic.Error
+
+	mut  sync.Mutex
+	file *os.File // The currently opened pipe, closed to interrupt reads.
+
+	stopOnce sync.Once
+	quit     chan struct{}
+	done     chan struct{}
+}
+
+func newFifoReader(
+	metrics *metrics,
+	logger log.Logger,
+	handler loki.EntryHandler,
+	path string,
+	encodingFormat string,
+	reopenWait time.Duration,
+) (*fifoReader, error) {
+
+	logger = log.With(logger, "component", "fifo_reader")
+
+	var decoder *encoding.Decoder
+	if encodingFormat != "" {
+		level.Info(logger).Log("msg", "fifo reader will decode messages", "from", encodingFormat, "to", "UTF8")
+		encoder, err := ianaindex.IANA.Encoding(encodingFormat)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get IANA encoding %s: %w", encodingFormat, err)
+		}
+		decoder = encoder.NewDecoder()
+	}
+
+	r := &fifoReader{
+		metrics:    metrics,
+		logger:     logger,
+		handler:    loki.AddLabelsMiddleware(model.LabelSet{filenameLabel: model.LabelValue(path)}).Wrap(handler),
+		path:       path,
+		decoder:    decoder,
+		reopenWait: reopenWait,
+		running:    atomic.NewBool(false),
+		quit:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+
+	go r.readLines()
+	metrics.filesActive.Add(1.)
+	return r, nil
+}
+
+func (r *fifoReader) readLines() {
+	level.Info(r.logger).Log("msg", "read lines routine: started", "path", r.path)
+	r.running.Store(true)
+
+	defer func() {
+		r.running.Store(false)
+		r.cleanupMetrics()
+		level.Info(r.logger).Log("msg", "read lines routine finished", "path", r.path)
+		close(r.done)
+	}()
+
+	for {
+		if err := r.readPipe(); err != nil {
+			level.Error(r.logger).Log("msg", "error reading named pipe", "path", r.path, "error", err)
+		}
+
+		select {
+		case <-r.quit:
+			return
+		case <-time.After(r.reopenWait):
+		}
+	}
+}
+
+// readPipe opens the pipe and reads lines from it until all of its writers
+// close it or the reader is stopped.
+func (r *fifoReader) readPipe() error {
+	// Opening a named pipe for reading blocks until there is a writer, unless
+	// it's opened in non-blocking mode.
+	f, err := os.OpenFile(r.path, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return err
+	}
+
+	r.mut.Lock()
+	select {
+	case <-r.quit:
+		r.mut.Unlock()
+		return f.Close()
+	default:
+		r.file = f
+	}
+	r.mut.Unlock()
+
+	defer func() {
+		r.mut.Lock()
+		r.file = nil
+		r.mut.Unlock()
+		f.Close()
+	}()
+
+	entries := r.handler.Chan()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 4096), 2000000) // 2 MB
+	for scanner.Scan() {
+		text := scanner.Text()
+		var finalText string
+		if r.decoder != nil {
+			var err error
+			finalText, err = r.convertToUTF8(text)
+			if err != nil {
+				level.Debug(r.logger).Log("msg", "failed to convert encoding", "error", err)
+				r.metrics.encodingFailures.WithLabelValues(r.path).Inc()
+				r.lastEncodingErr.Store(err)
+				finalText = fmt.Sprintf("the requested encoding conversion for this line failed in Grafana Agent: %s", err.Error())
+			}
+		} else {
+			finalText = text
+			if !utf8.ValidString(finalText) {
+				level.Debug(r.logger).Log("msg", "line contains invalid UTF-8", "path", r.path)
+				r.metrics.encodingFailures.WithLabelValues(r.path).Inc()
+				r.lastEncodingErr.Store(errInvalidUTF8)
+			}
+		}
+
+		r.metrics.readLines.WithLabelValues(r.path).Inc()
+
+		entries <- loki.Entry{
+			Labels: model.LabelSet{},
+			Entry: logproto.Entry{
+				Timestamp: time.Now(),
+				Line:      finalText,
+			},
+		}
+	}
+
+	if err := scanner.Err(); err != nil && !errors.Is(err, os.ErrClosed) {
+		return err
+	}
+	return nil
+}
+
+// MarkPositionAndSize is a no-op, since named pipes don't have positions.
+func (r *fifoReader) MarkPositionAndSize() error {
+	return nil
+}
+
+func (r *fifoReader) Stop() {
+	r.stopOnce.Do(func() {
+		close(r.quit)
+
+		// Interrupt the current read, if any.
+		r.mut.Lock()
+		if r.file != nil {
+			r.file.Close()
+		}
+		r.mut.Unlock()
+
+		<-r.done
+		level.Info(r.logger).Log("msg", "stopped fifo reader", "path", r.path)
+		r.handler.Stop()
+	})
+}
+
+func (r *fifoReader) IsRunning() bool {
+	return r.running.Load()
+}
+
+// LastEncodingError returns the last error encountered while decoding a line,
+// if any.
+func (r *fifoReader) LastEncodingError() error {
+	return r.lastEncodingErr.Load()
+}
+
+func (r *fifoReader) convertToUTF8(text string) (string, error) {
+	res, _, err := transform.String(r.decoder, text)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode text to UTF8: %w", err)
+	}
+
+	return res, nil
+}
+
+// cleanupMetrics removes all metrics exported by this reader
+func (r *fifoReader) cleanupMetrics() {
+	r.metrics.filesActive.Add(-1.)
+	r.metrics.readLines.DeleteLabelValues(r.path)
+	r.metrics.encodingFailures.DeleteLabelValues(r.path)
+}
+
+func (r *fifoReader) Path() string {
+	return r.path
+}
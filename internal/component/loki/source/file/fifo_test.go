@@ -0,0 +1,63 @@
+//go:build !windows
+
+package file
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/internal/component"
+	"github.com/grafana/agent/internal/component/common/loki"
+	"github.com/grafana/agent/internal/component/discovery"
+	"github.com/grafana/agent/internal/util"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNamedPipe(t *testing.T) {
+	opts := component.Options{
+		Logger:        util.TestFlowLogger(t),
+		Registerer:    prometheus.NewRegistry(),
+		OnStateChange: func(e component.Exports) {},
+		DataPath:      t.TempDir(),
+	}
+
+	path := filepath.Join(t.TempDir(), "app.pipe")
+	require.NoError(t, syscall.Mkfifo(path, 0600))
+
+	ch1 := loki.NewLogsReceiver()
+	args := DefaultArguments
+	args.Targets = []discovery.Target{{"__path__": path}}
+	args.ForwardTo = []loki.LogsReceiver{ch1}
+
+	c, err := New(opts, args)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Run(ctx)
+
+	// Lines are read from every writer, even after a previous writer closed
+	// the pipe.
+	for _, line := range []string{"first writer", "second writer"} {
+		f, err := os.OpenFile(path, os.O_WRONLY, 0)
+		require.NoError(t, err)
+		_, err = f.WriteString(line + "\n")
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		select {
+		case logEntry := <-ch1.Chan():
+			require.Equal(t, line, logEntry.Line)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "failed waiting for log line", line)
+		}
+	}
+
+	// No position is stored for named pipes.
+	require.Empty(t, c.posFile.GetString(path, "{}"))
+}
@@ -2,18 +2,22 @@ package file
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
+	"github.com/alecthomas/units"
 	"github.com/grafana/agent/internal/component"
 	"github.com/grafana/agent/internal/component/common/loki"
 	"github.com/grafana/agent/internal/component/common/loki/positions"
 	"github.com/grafana/agent/internal/component/discovery"
 	"github.com/grafana/agent/internal/featuregate"
+	filedetector "github.com/grafana/agent/internal/filedetector"
 	"github.com/grafana/agent/internal/flow/logging/level"
 	"github.com/grafana/tail/watch"
 	"github.com/prometheus/common/model"
@@ -45,19 +49,65 @@ type Arguments struct {
 	DecompressionConfig DecompressionConfig `river:"decompression,block,optional"`
 	FileWatch           FileWatch           `river:"file_watch,block,optional"`
 	TailFromEnd         bool                `river:"tail_from_end,attr,optional"`
+	NewFilesOnly        bool                `river:"new_files_only,attr,optional"`
+	NewestOnly          bool                `river:"newest_only,attr,optional"`
 	LegacyPositionsFile string              `river:"legacy_positions_file,attr,optional"`
+	MaxLineBytes        units.Base2Bytes    `river:"max_line_bytes,attr,optional"`
+	TruncatedLineMarker string              `river:"truncated_line_marker,attr,optional"`
+	DedupWindow         time.Duration       `river:"dedup_window,attr,optional"`
+	MaxOpenFiles        int                 `river:"max_open_files,attr,optional"`
+
+	PositionsCleanupGracePeriod time.Duration `river:"positions_cleanup_grace_period,attr,optional"`
 }
 
 type FileWatch struct {
-	MinPollFrequency time.Duration `river:"min_poll_frequency,attr,optional"`
-	MaxPollFrequency time.Duration `river:"max_poll_frequency,attr,optional"`
+	MinPollFrequency time.Duration         `river:"min_poll_frequency,attr,optional"`
+	MaxPollFrequency time.Duration         `river:"max_poll_frequency,attr,optional"`
+	Detector         filedetector.Detector `river:"detector,attr,optional"`
+}
+
+// UnmarshalRiver implements river.Unmarshaler.
+func (fw *FileWatch) UnmarshalRiver(f func(interface{}) error) error {
+	// filedetector.Detector treats an empty string as fsnotify, which isn't
+	// the default of this component, so detector is decoded as a string to
+	// reject empty values.
+	type fileWatch struct {
+		MinPollFrequency time.Duration `river:"min_poll_frequency,attr,optional"`
+		MaxPollFrequency time.Duration `river:"max_poll_frequency,attr,optional"`
+		Detector         string        `river:"detector,attr,optional"`
+	}
+	raw := fileWatch{
+		MinPollFrequency: fw.MinPollFrequency,
+		MaxPollFrequency: fw.MaxPollFrequency,
+		Detector:         fw.Detector.String(),
+	}
+	if err := f(&raw); err != nil {
+		return err
+	}
+
+	if raw.Detector == "" {
+		return fmt.Errorf("detector must not be empty, expected fsnotify or poll")
+	}
+	var detector filedetector.Detector
+	if err := detector.UnmarshalText([]byte(raw.Detector)); err != nil {
+		return err
+	}
+
+	*fw = FileWatch{
+		MinPollFrequency: raw.MinPollFrequency,
+		MaxPollFrequency: raw.MaxPollFrequency,
+		Detector:         detector,
+	}
+	return nil
 }
 
 var DefaultArguments = Arguments{
 	FileWatch: FileWatch{
 		MinPollFrequency: 250 * time.Millisecond,
 		MaxPollFrequency: 250 * time.Millisecond,
+		Detector:         filedetector.DetectorPoll,
 	},
+	PositionsCleanupGracePeriod: time.Minute,
 }
 
 // SetToDefault implements river.Defaulter.
@@ -65,13 +115,33 @@ func (a *Arguments) SetToDefault() {
 	*a = DefaultArguments
 }
 
+// Validate implements river.Validator.
+func (a *Arguments) Validate() error {
+	if a.MaxLineBytes < 0 {
+		return fmt.Errorf("max_line_bytes must not be negative")
+	}
+	if a.DedupWindow < 0 {
+		return fmt.Errorf("dedup_window must not be negative")
+	}
+	if a.MaxOpenFiles < 0 {
+		return fmt.Errorf("max_open_files must not be negative")
+	}
+	if a.PositionsCleanupGracePeriod < 0 {
+		return fmt.Errorf("positions_cleanup_grace_period must not be negative")
+	}
+	return nil
+}
+
 type DecompressionConfig struct {
 	Enabled      bool              `river:"enabled,attr"`
 	InitialDelay time.Duration     `river:"initial_delay,attr,optional"`
 	Format       CompressionFormat `river:"format,attr"`
 }
 
-var _ component.Component = (*Component)(nil)
+var (
+	_ component.Component       = (*Component)(nil)
+	_ component.HealthComponent = (*Component)(nil)
+)
 
 // Component implements the loki.source.file component.
 type Component struct {
@@ -86,6 +156,10 @@ type Component struct {
 	receivers []loki.LogsReceiver
 	posFile   positions.Positions
 	readers   map[positions.Entry]reader
+	failed    map[positions.Entry]error // Targets which failed to start tailing.
+
+	startTime time.Time
+	skipped   map[positions.Entry]os.FileInfo // Files of targets ignored by new_files_only.
 }
 
 // New creates a new loki.source.file component.
@@ -104,6 +178,9 @@ func New(o component.Options, args Arguments) (*Component, error) {
 		PositionsFile:     newPositionsPath,
 		IgnoreInvalidYaml: false,
 		ReadOnly:          false,
+		Registerer:        o.Registerer,
+
+		CleanupGracePeriod: args.PositionsCleanupGracePeriod,
 	})
 	if err != nil {
 		return nil, err
@@ -117,6 +194,9 @@ func New(o component.Options, args Arguments) (*Component, error) {
 		receivers: args.ForwardTo,
 		posFile:   positionsFile,
 		readers:   make(map[positions.Entry]reader),
+		failed:    make(map[positions.Entry]error),
+		startTime: time.Now(),
+		skipped:   make(map[positions.Entry]os.FileInfo),
 	}
 
 	// Call to Update() to start readers and set receivers once at the start.
@@ -162,6 +242,14 @@ func (c *Component) Update(args component.Arguments) error {
 	c.updateMut.Lock()
 	defer c.updateMut.Unlock()
 
+	newArgs := args.(Arguments)
+
+	targets := newArgs.Targets
+	if newArgs.NewestOnly {
+		targets = newestPerDirectory(targets)
+		c.finalizeSuperseded(newArgs.Targets, targets)
+	}
+
 	// Stop all readers so we can recreate them below. This *must* be done before
 	// c.mut is held to avoid a race condition where stopping a reader is
 	// flushing its data, but the flush never succeeds because the Run goroutine
@@ -184,21 +272,31 @@ func (c *Component) Update(args component.Arguments) error {
 	//   and c.stopTailingAndRemovePosition.
 	oldPaths := c.stopReaders()
 
-	newArgs := args.(Arguments)
-
 	c.mut.Lock()
 	defer c.mut.Unlock()
 	c.args = newArgs
 	c.receivers = newArgs.ForwardTo
+	c.posFile.SetCleanupGracePeriod(newArgs.PositionsCleanupGracePeriod)
 
 	c.readers = make(map[positions.Entry]reader)
+	c.failed = make(map[positions.Entry]error)
+
+	c.metrics.filesRefused.Set(0)
 
-	if len(newArgs.Targets) == 0 {
+	if len(targets) == 0 {
 		level.Debug(c.opts.Logger).Log("msg", "no files targets were passed, nothing will be tailed")
+		clear(c.skipped)
 		return nil
 	}
 
-	for _, target := range newArgs.Targets {
+	poll := newArgs.FileWatch.Detector != filedetector.DetectorFSNotify || !canUseFSNotify(c.opts.Logger)
+
+	var (
+		refused = make(map[positions.Entry]struct{}) // Targets over max_open_files.
+		current = make(map[positions.Entry]struct{}, len(targets))
+	)
+
+	for _, target := range targets {
 		path := target[pathLabel]
 
 		labels := make(model.LabelSet)
@@ -211,15 +309,34 @@ func (c *Component) Update(args component.Arguments) error {
 
 		// Deduplicate targets which have the same public label set.
 		readersKey := positions.Entry{Path: path, Labels: labels.String()}
+		current[readersKey] = struct{}{}
 		if _, exist := c.readers[readersKey]; exist {
 			continue
+		} else if _, failed := c.failed[readersKey]; failed {
+			continue
+		}
+		if newArgs.NewFilesOnly && c.skipOldFile(readersKey) {
+			continue
+		}
+
+		if newArgs.MaxOpenFiles > 0 && len(c.readers) >= newArgs.MaxOpenFiles {
+			c.failed[readersKey] = errMaxOpenFiles
+			refused[readersKey] = struct{}{}
+			continue
 		}
 
 		c.reportSize(path, labels.String())
 
-		handler := loki.AddLabelsMiddleware(labels).Wrap(loki.NewEntryHandler(c.handler.Chan(), func() {}))
-		reader, err := c.startTailing(path, labels, handler)
+		handler := c.newEntryHandler(path, labels, int(newArgs.MaxLineBytes), newArgs.TruncatedLineMarker)
+		if newArgs.DedupWindow > 0 {
+			handler = newDedupHandler(handler, newArgs.DedupWindow, func() {
+				c.metrics.dedupedLines.WithLabelValues(path).Inc()
+			})
+		}
+		reader, err := c.startTailing(path, labels, handler, poll)
 		if err != nil {
+			handler.Stop()
+			c.failed[readersKey] = err
 			continue
 		}
 
@@ -229,15 +346,196 @@ func (c *Component) Update(args component.Arguments) error {
 		}
 	}
 
+	// Forget skipped files of targets which are gone.
+	for e := range c.skipped {
+		if _, ok := current[e]; !ok {
+			delete(c.skipped, e)
+		}
+	}
+
+	if len(refused) > 0 {
+		level.Warn(c.opts.Logger).Log("msg", "max_open_files reached, not tailing the remaining files", "max_open_files", newArgs.MaxOpenFiles, "refused", len(refused))
+		c.metrics.filesRefused.Set(float64(len(refused)))
+	}
+
 	// Remove from the positions file any entries that had a Reader before, but
-	// are no longer in the updated set of Targets.
+	// are no longer in the updated set of Targets. Files refused because of
+	// max_open_files keep their position, so they can be resumed later. The
+	// entries of files which no longer exist are left to the positions file,
+	// which removes them once they've been missing for
+	// positions_cleanup_grace_period, so that files which reappear after
+	// being rotated keep their position.
 	for r := range missing(c.readers, oldPaths) {
+		if _, ok := refused[r]; ok {
+			continue
+		}
+		if _, err := os.Stat(r.Path); os.IsNotExist(err) && newArgs.PositionsCleanupGracePeriod > 0 {
+			continue
+		}
 		c.posFile.Remove(r.Path, r.Labels)
 	}
 
 	return nil
 }
 
+// errMaxOpenFiles is reported for targets which aren't tailed because
+// max_open_files files are already tailed.
+var errMaxOpenFiles = errors.New("not tailed because max_open_files was reached")
+
+// finalizeTimeout is how long to wait for the readers of superseded files
+// to reach the end of their file before stopping them.
+var finalizeTimeout = 5 * time.Second
+
+const finalizePollInterval = 10 * time.Millisecond
+
+// newestPerDirectory returns the targets of the most recently modified file
+// of each directory. Targets whose file can't be stat'ed are kept, so that
+// the error is reported when trying to read them.
+func newestPerDirectory(targets []discovery.Target) []discovery.Target {
+	type file struct {
+		path    string
+		modTime time.Time
+	}
+	var (
+		newest = make(map[string]file)
+		failed = make(map[string]struct{})
+	)
+	for _, target := range targets {
+		path := target[pathLabel]
+		fi, err := os.Stat(path)
+		if err != nil {
+			failed[path] = struct{}{}
+			continue
+		}
+		dir := filepath.Dir(path)
+		if cur, ok := newest[dir]; !ok || fi.ModTime().After(cur.modTime) {
+			newest[dir] = file{path: path, modTime: fi.ModTime()}
+		}
+	}
+
+	res := make([]discovery.Target, 0, len(newest))
+	for _, target := range targets {
+		path := target[pathLabel]
+		if _, ok := failed[path]; ok || newest[filepath.Dir(path)].path == path {
+			res = append(res, target)
+		}
+	}
+	return res
+}
+
+// finalizeSuperseded waits for the tailers of files which are in all but not
+// in newest to read their file to the end, so that the lines written to them
+// before a newer file appeared aren't lost when they're stopped. The tailers
+// are waited for concurrently, up to finalizeTimeout in total.
+func (c *Component) finalizeSuperseded(all, newest []discovery.Target) {
+	kept := make(map[string]struct{}, len(newest))
+	for _, target := range newest {
+		kept[target[pathLabel]] = struct{}{}
+	}
+	superseded := make(map[string]struct{})
+	for _, target := range all {
+		if _, ok := kept[target[pathLabel]]; !ok {
+			superseded[target[pathLabel]] = struct{}{}
+		}
+	}
+
+	c.mut.RLock()
+	toFinalize := make(map[positions.Entry]reader)
+	for e, r := range c.readers {
+		if _, ok := superseded[e.Path]; !ok {
+			continue
+		}
+		// Only tailers track byte offsets which can be compared to the size of
+		// the file.
+		if rh, ok := r.(readerWithHandler); ok {
+			if _, ok := rh.reader.(*tailer); ok {
+				toFinalize[e] = r
+			}
+		}
+	}
+	c.mut.RUnlock()
+
+	var (
+		deadline = time.Now().Add(finalizeTimeout)
+		wg       sync.WaitGroup
+	)
+	for e, r := range toFinalize {
+		wg.Add(1)
+		go func(e positions.Entry, r reader) {
+			defer wg.Done()
+			c.waitForEOF(e, r, deadline)
+		}(e, r)
+	}
+	wg.Wait()
+}
+
+// waitForEOF waits until deadline for r to read its file to the end.
+func (c *Component) waitForEOF(e positions.Entry, r reader, deadline time.Time) {
+	for time.Now().Before(deadline) {
+		if err := r.MarkPositionAndSize(); err != nil {
+			return
+		}
+		pos, err := c.posFile.Get(e.Path, e.Labels)
+		if err != nil {
+			return
+		}
+		fi, err := os.Stat(e.Path)
+		if err != nil || pos >= fi.Size() {
+			return
+		}
+		time.Sleep(finalizePollInterval)
+	}
+	level.Warn(c.opts.Logger).Log("msg", "stopping reader of superseded file before reaching its end", "filename", e.Path)
+}
+
+// skipOldFile reports whether the target should be ignored because its file
+// was last modified before the component started and it has no stored
+// position. Skipped files are remembered so that they stay ignored if they're
+// written to later, until the path of the target points to a different file,
+// for example after the file was rotated.
+func (c *Component) skipOldFile(entry positions.Entry) bool {
+	fi, err := os.Stat(entry.Path)
+	if skipped, ok := c.skipped[entry]; ok {
+		if err == nil && os.SameFile(skipped, fi) {
+			return true
+		}
+		delete(c.skipped, entry)
+	}
+	if c.posFile.GetString(entry.Path, entry.Labels) != "" {
+		return false
+	}
+	if err != nil || !fi.ModTime().Before(c.startTime) {
+		return false
+	}
+
+	level.Debug(c.opts.Logger).Log("msg", "ignoring file which existed before startup", "filename", entry.Path)
+	c.skipped[entry] = fi
+	return true
+}
+
+// newEntryHandler returns the handler for entries read from path. It adds
+// labels to the entries, and truncates lines longer than maxLineBytes bytes,
+// appending marker to them. Lines aren't truncated if maxLineBytes is 0.
+func (c *Component) newEntryHandler(path string, labels model.LabelSet, maxLineBytes int, marker string) loki.EntryHandler {
+	return loki.NewEntryMutatorHandler(loki.NewEntryHandler(c.handler.Chan(), func() {}), func(e loki.Entry) loki.Entry {
+		e.Labels = labels.Merge(e.Labels)
+		if maxLineBytes > 0 && len(e.Line) > maxLineBytes {
+			e.Line = truncateLine(e.Line, maxLineBytes) + marker
+			c.metrics.truncatedLines.WithLabelValues(path).Inc()
+		}
+		return e
+	})
+}
+
+// truncateLine truncates line to at most n bytes without splitting a UTF-8
+// encoded character.
+func truncateLine(line string, n int) string {
+	for n > 0 && !utf8.RuneStart(line[n]) {
+		n--
+	}
+	return line[:n]
+}
+
 // readerWithHandler combines a reader with an entry handler associated with
 // it. Closing the reader will also close the handler.
 type readerWithHandler struct {
@@ -266,18 +564,55 @@ func (c *Component) stopReaders() map[positions.Entry]struct{} {
 	return stoppedPaths
 }
 
-// DebugInfo returns information about the status of tailed targets.
+// CurrentHealth implements component.HealthComponent. The component is
+// reported as unhealthy while the positions file can't be written, since
+// targets would lose their read positions on restart.
+func (c *Component) CurrentHealth() component.Health {
+	if err := c.posFile.WriteError(); err != nil {
+		return component.Health{
+			Health:     component.HealthTypeUnhealthy,
+			Message:    fmt.Sprintf("failed to write positions file: %s", err),
+			UpdateTime: time.Now(),
+		}
+	}
+	return component.Health{
+		Health:     component.HealthTypeHealthy,
+		Message:    "positions file written successfully",
+		UpdateTime: time.Now(),
+	}
+}
+
+// DebugInfo returns information about the status of tailed targets. Targets
+// which failed to start tailing are included along with the error which
+// caused them to fail.
 // TODO(@tpaschalis) Decorate with more debug information once it's made
 // available, such as the last time a log line was read.
 func (c *Component) DebugInfo() interface{} {
+	c.mut.RLock()
+	defer c.mut.RUnlock()
+
 	var res readerDebugInfo
 	for e, reader := range c.readers {
 		offset, _ := c.posFile.Get(e.Path, e.Labels)
-		res.TargetsInfo = append(res.TargetsInfo, targetInfo{
+		info := targetInfo{
 			Path:       e.Path,
 			Labels:     e.Labels,
 			IsRunning:  reader.IsRunning(),
 			ReadOffset: offset,
+		}
+		if err := reader.LastEncodingError(); err != nil {
+			info.LastEncodingError = err.Error()
+		}
+		res.TargetsInfo = append(res.TargetsInfo, info)
+	}
+	for e, err := range c.failed {
+		offset, _ := c.posFile.Get(e.Path, e.Labels)
+		res.TargetsInfo = append(res.TargetsInfo, targetInfo{
+			Path:       e.Path,
+			Labels:     e.Labels,
+			IsRunning:  false,
+			ReadOffset: offset,
+			LastError:  err.Error(),
 		})
 	}
 	return res
@@ -292,6 +627,9 @@ type targetInfo struct {
 	Labels     string `river:"labels,attr"`
 	IsRunning  bool   `river:"is_running,attr"`
 	ReadOffset int64  `river:"read_offset,attr"`
+	LastError  string `river:"last_error,attr,optional"`
+
+	LastEncodingError string `river:"last_encoding_error,attr,optional"`
 }
 
 // Returns the elements from set b which are missing from set a
@@ -307,13 +645,15 @@ func missing(as map[positions.Entry]reader, bs map[positions.Entry]struct{}) map
 
 // startTailing starts and returns a reader for the given path. For most files,
 // this will be a tailer implementation. If the file suffix alludes to it being
-// a compressed file, then a decompressor will be started instead.
-func (c *Component) startTailing(path string, labels model.LabelSet, handler loki.EntryHandler) (reader, error) {
+// a compressed file, then a decompressor will be started instead, and named
+// pipes are read with a fifoReader. Tailers poll the file for changes if poll
+// is true, and wait for filesystem events otherwise.
+func (c *Component) startTailing(path string, labels model.LabelSet, handler loki.EntryHandler, poll bool) (reader, error) {
 	fi, err := os.Stat(path)
 	if err != nil {
 		level.Error(c.opts.Logger).Log("msg", "failed to tail file, stat failed", "error", err, "filename", path)
 		c.metrics.totalBytes.DeleteLabelValues(path)
-		return nil, fmt.Errorf("failed to stat path %s", path)
+		return nil, fmt.Errorf("failed to stat path %s: %w", path, err)
 	}
 
 	if fi.IsDir() {
@@ -322,9 +662,34 @@ func (c *Component) startTailing(path string, labels model.LabelSet, handler lok
 		return nil, fmt.Errorf("failed to tail file, it was a directory %s", path)
 	}
 
-	var reader reader
-	if c.args.DecompressionConfig.Enabled {
-		level.Debug(c.opts.Logger).Log("msg", "reading from compressed file", "filename", path)
+	if fi.Mode()&os.ModeNamedPipe != 0 {
+		level.Debug(c.opts.Logger).Log("msg", "reading from named pipe", "filename", path)
+		fifo, err := newFifoReader(
+			c.metrics,
+			c.opts.Logger,
+			handler,
+			path,
+			c.args.Encoding,
+			c.args.FileWatch.MinPollFrequency,
+		)
+		if err != nil {
+			level.Error(c.opts.Logger).Log("msg", "failed to start fifo reader", "error", err, "filename", path)
+			return nil, fmt.Errorf("failed to start fifo reader %s", err)
+		}
+		return fifo, nil
+	}
+
+	var (
+		reader     reader
+		compressed bool
+		decompCfg  = c.args.DecompressionConfig
+	)
+	if decompCfg.Enabled {
+		decompCfg.Format, compressed = decompCfg.Format.formatForPath(path)
+	}
+
+	if compressed {
+		level.Debug(c.opts.Logger).Log("msg", "reading from compressed file", "filename", path, "format", decompCfg.Format)
 		decompressor, err := newDecompressor(
 			c.metrics,
 			c.opts.Logger,
@@ -333,7 +698,7 @@ func (c *Component) startTailing(path string, labels model.LabelSet, handler lok
 			path,
 			labels.String(),
 			c.args.Encoding,
-			c.args.DecompressionConfig,
+			decompCfg,
 		)
 		if err != nil {
 			level.Error(c.opts.Logger).Log("msg", "failed to start decompressor", "error", err, "filename", path)
@@ -354,6 +719,7 @@ func (c *Component) startTailing(path string, labels model.LabelSet, handler lok
 			path,
 			labels.String(),
 			c.args.Encoding,
+			poll,
 			pollOptions,
 			c.args.TailFromEnd,
 		)
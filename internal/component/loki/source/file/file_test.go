@@ -5,18 +5,25 @@ package file
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/grafana/agent/internal/component"
 	"github.com/grafana/agent/internal/component/common/loki"
+	"github.com/grafana/agent/internal/component/common/loki/positions"
 	"github.com/grafana/agent/internal/component/discovery"
+	filedetector "github.com/grafana/agent/internal/filedetector"
 	"github.com/grafana/agent/internal/flow/componenttest"
 	"github.com/grafana/agent/internal/util"
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/grafana/river"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/prometheus/common/model"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/goleak"
@@ -314,3 +321,955 @@ func TestEncoding(t *testing.T) {
 		"expected positions.yml file to be written eventually",
 	)
 }
+
+func TestDebugInfo_FailedTarget(t *testing.T) {
+	opts := component.Options{
+		Logger:        util.TestFlowLogger(t),
+		Registerer:    prometheus.NewRegistry(),
+		OnStateChange: func(e component.Exports) {},
+		DataPath:      t.TempDir(),
+	}
+
+	path := filepath.Join(opts.DataPath, "missing.log")
+
+	args := Arguments{}
+	args.Targets = []discovery.Target{{"__path__": path, "foo": "bar"}}
+	args.ForwardTo = []loki.LogsReceiver{loki.NewLogsReceiver()}
+
+	c, err := New(opts, args)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Run(ctx)
+
+	// The target can't be tailed, so its error is reported.
+	info := c.DebugInfo().(readerDebugInfo)
+	require.Len(t, info.TargetsInfo, 1)
+	require.Equal(t, path, info.TargetsInfo[0].Path)
+	require.False(t, info.TargetsInfo[0].IsRunning)
+	require.Contains(t, info.TargetsInfo[0].LastError, "failed to stat path "+path)
+
+	// Once the file exists, the target recovers and the error is cleared.
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer f.Close()
+	require.NoError(t, c.Update(args))
+
+	info = c.DebugInfo().(readerDebugInfo)
+	require.Len(t, info.TargetsInfo, 1)
+	require.Equal(t, path, info.TargetsInfo[0].Path)
+	require.Empty(t, info.TargetsInfo[0].LastError)
+	require.Eventually(t, func() bool {
+		return c.DebugInfo().(readerDebugInfo).TargetsInfo[0].IsRunning
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestTailFromEnd(t *testing.T) {
+	opts := component.Options{
+		Logger:        util.TestFlowLogger(t),
+		Registerer:    prometheus.NewRegistry(),
+		OnStateChange: func(e component.Exports) {},
+		DataPath:      t.TempDir(),
+	}
+
+	// Create a file which already has content before the component starts.
+	f, err := os.CreateTemp(opts.DataPath, "example")
+	require.NoError(t, err)
+	defer f.Close()
+	_, err = f.Write([]byte("old line\n"))
+	require.NoError(t, err)
+
+	ch1 := loki.NewLogsReceiver()
+	args := Arguments{}
+	args.Targets = []discovery.Target{{"__path__": f.Name(), "foo": "bar"}}
+	args.ForwardTo = []loki.LogsReceiver{ch1}
+	args.TailFromEnd = true
+
+	c, err := New(opts, args)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Run(ctx)
+	require.Eventually(t, func() bool {
+		info := c.DebugInfo().(readerDebugInfo)
+		return len(info.TargetsInfo) == 1 && info.TargetsInfo[0].IsRunning
+	}, 5*time.Second, 10*time.Millisecond)
+
+	_, err = f.Write([]byte("new line\n"))
+	require.NoError(t, err)
+
+	// Only lines written after the component started are read.
+	select {
+	case logEntry := <-ch1.Chan():
+		require.Equal(t, "new line", logEntry.Line)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "failed waiting for log line")
+	}
+}
+
+func TestInvalidUTF8(t *testing.T) {
+	opts := component.Options{
+		Logger:        util.TestFlowLogger(t),
+		Registerer:    prometheus.NewRegistry(),
+		OnStateChange: func(e component.Exports) {},
+		DataPath:      t.TempDir(),
+	}
+
+	f, err := os.CreateTemp(opts.DataPath, "example")
+	require.NoError(t, err)
+	defer f.Close()
+
+	ch1 := loki.NewLogsReceiver()
+	args := Arguments{}
+	args.Targets = []discovery.Target{{"__path__": f.Name(), "foo": "bar"}}
+	args.ForwardTo = []loki.LogsReceiver{ch1}
+
+	c, err := New(opts, args)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Run(ctx)
+	require.Eventually(t, func() bool {
+		info := c.DebugInfo().(readerDebugInfo)
+		return len(info.TargetsInfo) == 1 && info.TargetsInfo[0].IsRunning
+	}, 5*time.Second, 10*time.Millisecond)
+
+	_, err = f.Write([]byte("bad \xff\xfe line\ngood line\n"))
+	require.NoError(t, err)
+
+	// Invalid lines are still forwarded and don't stop tailing the rest of
+	// the file.
+	for _, want := range []string{"bad \xff\xfe line", "good line"} {
+		select {
+		case logEntry := <-ch1.Chan():
+			require.Equal(t, want, logEntry.Line)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "failed waiting for log line")
+		}
+	}
+
+	require.Equal(t, 1.0, testutil.ToFloat64(c.metrics.encodingFailures.WithLabelValues(f.Name())))
+	info := c.DebugInfo().(readerDebugInfo)
+	require.Len(t, info.TargetsInfo, 1)
+	require.Equal(t, errInvalidUTF8.Error(), info.TargetsInfo[0].LastEncodingError)
+}
+
+func TestRotation(t *testing.T) {
+	tt := []struct {
+		name   string
+		rotate func(t *testing.T, path string)
+	}{
+		{
+			name: "rename and create",
+			rotate: func(t *testing.T, path string) {
+				require.NoError(t, os.Rename(path, path+".1"))
+				require.NoError(t, os.WriteFile(path, nil, 0644))
+			},
+		},
+		{
+			name: "copy and truncate",
+			rotate: func(t *testing.T, path string) {
+				content, err := os.ReadFile(path)
+				require.NoError(t, err)
+				require.NoError(t, os.WriteFile(path+".1", content, 0644))
+				require.NoError(t, os.Truncate(path, 0))
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := component.Options{
+				Logger:        util.TestFlowLogger(t),
+				Registerer:    prometheus.NewRegistry(),
+				OnStateChange: func(e component.Exports) {},
+				DataPath:      t.TempDir(),
+			}
+
+			path := filepath.Join(opts.DataPath, "example.log")
+			require.NoError(t, os.WriteFile(path, []byte("first line\n"), 0644))
+
+			ch1 := loki.NewLogsReceiver()
+			args := DefaultArguments
+			args.Targets = []discovery.Target{{"__path__": path, "foo": "bar"}}
+			args.ForwardTo = []loki.LogsReceiver{ch1}
+			args.FileWatch = FileWatch{
+				MinPollFrequency: 50 * time.Millisecond,
+				MaxPollFrequency: 50 * time.Millisecond,
+			}
+
+			c, err := New(opts, args)
+			require.NoError(t, err)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go c.Run(ctx)
+
+			requireLine(t, ch1, "first line")
+
+			// Give the watcher time to notice the rotation before writing to
+			// the new file.
+			tc.rotate(t, path)
+			time.Sleep(4 * args.FileWatch.MaxPollFrequency)
+			appendLine(t, path, "after rotation")
+
+			// The rotated file is read from the start.
+			requireLine(t, ch1, "after rotation")
+		})
+	}
+}
+
+// Test that a file which was truncated while the component wasn't running is
+// read from the start.
+func TestTruncatedWhileStopped(t *testing.T) {
+	opts := component.Options{
+		Logger:        util.TestFlowLogger(t),
+		Registerer:    prometheus.NewRegistry(),
+		OnStateChange: func(e component.Exports) {},
+		DataPath:      t.TempDir(),
+	}
+
+	path := filepath.Join(opts.DataPath, "example.log")
+	require.NoError(t, os.WriteFile(path, []byte("new line\n"), 0644))
+
+	// Store a position which is past the end of the file.
+	posFile, err := positions.New(util.TestLogger(t), positions.Config{
+		SyncPeriod:    10 * time.Second,
+		PositionsFile: filepath.Join(opts.DataPath, "positions.yml"),
+	})
+	require.NoError(t, err)
+	posFile.Put(path, `{foo="bar"}`, 1000)
+	posFile.Stop()
+
+	ch1 := loki.NewLogsReceiver()
+	args := Arguments{}
+	args.Targets = []discovery.Target{{"__path__": path, "foo": "bar"}}
+	args.ForwardTo = []loki.LogsReceiver{ch1}
+
+	c, err := New(opts, args)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Run(ctx)
+
+	requireLine(t, ch1, "new line")
+}
+
+// Test that a file which is deleted and recreated within
+// positions_cleanup_grace_period is resumed from its position.
+func TestPositionsCleanupGracePeriod(t *testing.T) {
+	tt := []struct {
+		name        string
+		gracePeriod time.Duration
+		expected    string
+	}{
+		{
+			name:        "recreated within grace period",
+			gracePeriod: time.Minute,
+			expected:    "second line",
+		},
+		{
+			name:        "no grace period",
+			gracePeriod: 0,
+			expected:    "first line",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := component.Options{
+				Logger:        util.TestFlowLogger(t),
+				Registerer:    prometheus.NewRegistry(),
+				OnStateChange: func(e component.Exports) {},
+				DataPath:      t.TempDir(),
+			}
+
+			path := filepath.Join(opts.DataPath, "example.log")
+			other := filepath.Join(opts.DataPath, "other.log")
+			require.NoError(t, os.WriteFile(path, []byte("first line\n"), 0644))
+			require.NoError(t, os.WriteFile(other, nil, 0644))
+
+			ch1 := loki.NewLogsReceiver()
+			args := DefaultArguments
+			args.Targets = []discovery.Target{{"__path__": path, "foo": "bar"}, {"__path__": other}}
+			args.ForwardTo = []loki.LogsReceiver{ch1}
+			args.PositionsCleanupGracePeriod = tc.gracePeriod
+
+			c, err := New(opts, args)
+			require.NoError(t, err)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go c.Run(ctx)
+
+			requireLine(t, ch1, "first line")
+
+			// The file is deleted, so discovery no longer returns it.
+			require.NoError(t, os.Remove(path))
+			targets := args.Targets
+			args.Targets = targets[1:]
+			require.NoError(t, c.Update(args))
+
+			// The file is recreated with its previous content and a new line.
+			require.NoError(t, os.WriteFile(path, []byte("first line\nsecond line\n"), 0644))
+			args.Targets = targets
+			require.NoError(t, c.Update(args))
+
+			requireLine(t, ch1, tc.expected)
+		})
+	}
+}
+
+func appendLine(t *testing.T, path string, line string) {
+	t.Helper()
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	defer f.Close()
+
+	_, err = f.WriteString(line + "\n")
+	require.NoError(t, err)
+}
+
+func requireLine(t *testing.T, ch loki.LogsReceiver, line string) {
+	t.Helper()
+
+	select {
+	case logEntry := <-ch.Chan():
+		require.Equal(t, line, logEntry.Line)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "failed waiting for log line", line)
+	}
+}
+
+func TestMaxLineBytes(t *testing.T) {
+	opts := component.Options{
+		Logger:        util.TestFlowLogger(t),
+		Registerer:    prometheus.NewRegistry(),
+		OnStateChange: func(e component.Exports) {},
+		DataPath:      t.TempDir(),
+	}
+
+	f, err := os.CreateTemp(opts.DataPath, "example")
+	require.NoError(t, err)
+	defer f.Close()
+
+	ch1 := loki.NewLogsReceiver()
+	args := DefaultArguments
+	args.Targets = []discovery.Target{{"__path__": f.Name(), "foo": "bar"}}
+	args.ForwardTo = []loki.LogsReceiver{ch1}
+	args.MaxLineBytes = 10
+	args.TruncatedLineMarker = "..."
+
+	c, err := New(opts, args)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Run(ctx)
+
+	_, err = f.Write([]byte("short\nthis line is too long\n0123456789\n"))
+	require.NoError(t, err)
+
+	requireLine(t, ch1, "short")
+	requireLine(t, ch1, "this line ...")
+	requireLine(t, ch1, "0123456789")
+
+	require.Equal(t, 1.0, testutil.ToFloat64(c.metrics.truncatedLines.WithLabelValues(f.Name())))
+}
+
+func TestDedupWindow(t *testing.T) {
+	opts := component.Options{
+		Logger:        util.TestFlowLogger(t),
+		Registerer:    prometheus.NewRegistry(),
+		OnStateChange: func(e component.Exports) {},
+		DataPath:      t.TempDir(),
+	}
+
+	f, err := os.CreateTemp(opts.DataPath, "example")
+	require.NoError(t, err)
+	defer f.Close()
+
+	ch1 := loki.NewLogsReceiver()
+	args := DefaultArguments
+	args.Targets = []discovery.Target{{"__path__": f.Name(), "foo": "bar"}}
+	args.ForwardTo = []loki.LogsReceiver{ch1}
+	args.DedupWindow = time.Minute
+
+	c, err := New(opts, args)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Run(ctx)
+
+	_, err = f.Write([]byte("retrying\nretrying\nretrying\nconnected\nretrying\nstopped\n"))
+	require.NoError(t, err)
+
+	requireLine(t, ch1, "retrying (repeated 3 times)")
+	requireLine(t, ch1, "connected")
+	requireLine(t, ch1, "retrying")
+
+	// The last line is held back until dedup_window elapses or the reader is
+	// stopped.
+	c.Update(Arguments{ForwardTo: args.ForwardTo})
+	requireLine(t, ch1, "stopped")
+
+	require.Equal(t, 2.0, testutil.ToFloat64(c.metrics.dedupedLines.WithLabelValues(f.Name())))
+}
+
+func TestMaxOpenFiles(t *testing.T) {
+	opts := component.Options{
+		Logger:        util.TestFlowLogger(t),
+		Registerer:    prometheus.NewRegistry(),
+		OnStateChange: func(e component.Exports) {},
+		DataPath:      t.TempDir(),
+	}
+
+	var targets []discovery.Target
+	for i := 0; i < 4; i++ {
+		f, err := os.CreateTemp(opts.DataPath, "example")
+		require.NoError(t, err)
+		_, err = f.WriteString("line\n")
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+		targets = append(targets, discovery.Target{"__path__": f.Name()})
+	}
+
+	ch1 := loki.NewLogsReceiver()
+	args := DefaultArguments
+	args.Targets = targets
+	args.ForwardTo = []loki.LogsReceiver{ch1}
+	args.MaxOpenFiles = 2
+
+	c, err := New(opts, args)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Run(ctx)
+
+	requireLine(t, ch1, "line")
+	requireLine(t, ch1, "line")
+	select {
+	case e := <-ch1.Chan():
+		require.FailNow(t, "unexpected line from a file over max_open_files", e.Line)
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	c.mut.RLock()
+	require.Len(t, c.readers, 2)
+	require.Len(t, c.failed, 2)
+	for _, err := range c.failed {
+		require.ErrorIs(t, err, errMaxOpenFiles)
+	}
+	c.mut.RUnlock()
+	require.Equal(t, 2.0, testutil.ToFloat64(c.metrics.filesRefused))
+
+	// Files are tailed again once the limit allows it.
+	args.MaxOpenFiles = 0
+	require.NoError(t, c.Update(args))
+	requireLine(t, ch1, "line")
+	requireLine(t, ch1, "line")
+	require.Equal(t, 0.0, testutil.ToFloat64(c.metrics.filesRefused))
+}
+
+func TestDedupHandler_Window(t *testing.T) {
+	out := make(chan loki.Entry)
+	handler := newDedupHandler(loki.NewEntryHandler(out, func() {}), 50*time.Millisecond, func() {})
+	defer handler.Stop()
+
+	send := func(line string) {
+		handler.Chan() <- loki.Entry{Entry: logproto.Entry{Timestamp: time.Now(), Line: line}}
+	}
+	receive := func() string {
+		select {
+		case e := <-out:
+			return e.Line
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "timed out waiting for an entry")
+			return ""
+		}
+	}
+
+	send("retrying")
+	send("retrying")
+	require.Equal(t, "retrying (repeated 2 times)", receive())
+
+	// Identical lines after the window has elapsed start a new run.
+	send("retrying")
+	require.Equal(t, "retrying", receive())
+	send("retrying")
+	require.Equal(t, "retrying", receive())
+}
+
+func TestTruncateLine(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		n        int
+		expected string
+	}{
+		{
+			name:     "ASCII",
+			line:     "Hello, World!",
+			n:        5,
+			expected: "Hello",
+		},
+		{
+			name:     "Multi-byte character at the limit",
+			line:     "Hello, 世界",
+			n:        9, // "世" spans bytes 7 to 9.
+			expected: "Hello, ",
+		},
+		{
+			name:     "Multi-byte character before the limit",
+			line:     "Hello, 世界",
+			n:        10,
+			expected: "Hello, 世",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, truncateLine(tt.line, tt.n))
+		})
+	}
+}
+
+func TestDecompressionAutoFormat(t *testing.T) {
+	opts := component.Options{
+		Logger:        util.TestFlowLogger(t),
+		Registerer:    prometheus.NewRegistry(),
+		OnStateChange: func(e component.Exports) {},
+		DataPath:      t.TempDir(),
+	}
+
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "app.log")
+	compressed := filepath.Join(dir, "app.log.1.gz")
+	writeGzipFile(t, compressed, "rotated line")
+	require.NoError(t, os.WriteFile(plain, nil, 0600))
+
+	ch1 := loki.NewLogsReceiver()
+	args := DefaultArguments
+	args.Targets = []discovery.Target{{"__path__": plain}, {"__path__": compressed}}
+	args.ForwardTo = []loki.LogsReceiver{ch1}
+	args.DecompressionConfig = DecompressionConfig{Enabled: true, Format: autoCompressionFormat}
+
+	c, err := New(opts, args)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Run(ctx)
+
+	select {
+	case logEntry := <-ch1.Chan():
+		require.Equal(t, "rotated line", logEntry.Line)
+		require.Equal(t, model.LabelValue(compressed), logEntry.Labels["filename"])
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "failed waiting for log line")
+	}
+
+	// The plain file is still tailed for new lines.
+	appendLine(t, plain, "live line")
+	requireLine(t, ch1, "live line")
+}
+
+func TestNewFilesOnly(t *testing.T) {
+	opts := component.Options{
+		Logger:        util.TestFlowLogger(t),
+		Registerer:    prometheus.NewRegistry(),
+		OnStateChange: func(e component.Exports) {},
+		DataPath:      t.TempDir(),
+	}
+
+	dir := t.TempDir()
+	old := filepath.Join(dir, "old.log")
+	known := filepath.Join(dir, "known.log")
+	newFile := filepath.Join(dir, "new.log")
+
+	// Both files existed before startup, but only known.log was read before.
+	lastHour := time.Now().Add(-time.Hour)
+	for _, path := range []string{old, known} {
+		require.NoError(t, os.WriteFile(path, []byte("archived line\n"), 0600))
+		require.NoError(t, os.Chtimes(path, lastHour, lastHour))
+	}
+	ps, err := positions.New(opts.Logger, positions.Config{
+		SyncPeriod:    10 * time.Second,
+		PositionsFile: filepath.Join(opts.DataPath, "positions.yml"),
+	})
+	require.NoError(t, err)
+	ps.Put(known, "{}", int64(len("archived line\n")))
+	ps.Stop()
+
+	ch1 := loki.NewLogsReceiver()
+	args := DefaultArguments
+	args.Targets = []discovery.Target{{"__path__": old}, {"__path__": known}}
+	args.ForwardTo = []loki.LogsReceiver{ch1}
+	args.NewFilesOnly = true
+
+	c, err := New(opts, args)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Run(ctx)
+
+	// Files known in positions continue normally.
+	appendLine(t, known, "known line")
+	requireLine(t, ch1, "known line")
+
+	// Files created after startup are read from the beginning. File
+	// timestamps can be slightly behind the wall clock, so wait a bit.
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, os.WriteFile(newFile, []byte("new line\n"), 0600))
+	args.Targets = append(args.Targets, discovery.Target{"__path__": newFile})
+	require.NoError(t, c.Update(args))
+	requireLine(t, ch1, "new line")
+
+	// Files which existed before startup stay ignored, even when written to.
+	appendLine(t, old, "old line")
+	select {
+	case logEntry := <-ch1.Chan():
+		require.FailNow(t, "unexpected log line", logEntry.Line)
+	case <-time.After(time.Second):
+	}
+	require.Len(t, c.DebugInfo().(readerDebugInfo).TargetsInfo, 2)
+}
+
+// Test that a skipped file which is rotated is replaced by the new file at
+// its path, which is read from the beginning.
+func TestNewFilesOnlyRotation(t *testing.T) {
+	opts := component.Options{
+		Logger:        util.TestFlowLogger(t),
+		Registerer:    prometheus.NewRegistry(),
+		OnStateChange: func(e component.Exports) {},
+		DataPath:      t.TempDir(),
+	}
+
+	path := filepath.Join(t.TempDir(), "app.log")
+	lastHour := time.Now().Add(-time.Hour)
+	require.NoError(t, os.WriteFile(path, []byte("archived line\n"), 0600))
+	require.NoError(t, os.Chtimes(path, lastHour, lastHour))
+
+	ch1 := loki.NewLogsReceiver()
+	args := DefaultArguments
+	args.Targets = []discovery.Target{{"__path__": path}}
+	args.ForwardTo = []loki.LogsReceiver{ch1}
+	args.NewFilesOnly = true
+
+	c, err := New(opts, args)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Run(ctx)
+
+	// The file is still skipped after it's written to.
+	appendLine(t, path, "old line")
+	require.NoError(t, c.Update(args))
+	require.Len(t, c.skipped, 1)
+
+	// The file is rotated, and the file created in its place is read.
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, os.Rename(path, path+".1"))
+	require.NoError(t, os.WriteFile(path, []byte("after rotation\n"), 0600))
+	require.NoError(t, c.Update(args))
+	requireLine(t, ch1, "after rotation")
+	require.Empty(t, c.skipped)
+
+	// Skipped files of targets which are gone are forgotten.
+	require.NoError(t, os.Chtimes(path+".1", lastHour, lastHour))
+	args.Targets = []discovery.Target{{"__path__": path + ".1"}}
+	require.NoError(t, c.Update(args))
+	require.Len(t, c.skipped, 1)
+	args.Targets = []discovery.Target{{"__path__": path}}
+	require.NoError(t, c.Update(args))
+	require.Empty(t, c.skipped)
+}
+
+func TestNewestOnly(t *testing.T) {
+	opts := component.Options{
+		Logger:        util.TestFlowLogger(t),
+		Registerer:    prometheus.NewRegistry(),
+		OnStateChange: func(e component.Exports) {},
+		DataPath:      t.TempDir(),
+	}
+
+	dir := t.TempDir()
+	ch1 := loki.NewLogsReceiver()
+	args := DefaultArguments
+	args.ForwardTo = []loki.LogsReceiver{ch1}
+	args.NewestOnly = true
+
+	c, err := New(opts, args)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Run(ctx)
+
+	base := time.Now().Add(-time.Hour)
+	var prev string
+	for i := 1; i <= 3; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("service-%d.log", i))
+		require.NoError(t, os.WriteFile(path, []byte(fmt.Sprintf("line %d\n", i)), 0600))
+		modTime := base.Add(time.Duration(i) * time.Minute)
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+
+		args.Targets = append(args.Targets, discovery.Target{"__path__": path})
+		require.NoError(t, c.Update(args))
+
+		// Only the newest file is read.
+		requireLine(t, ch1, fmt.Sprintf("line %d", i))
+		info := c.DebugInfo().(readerDebugInfo).TargetsInfo
+		require.Len(t, info, 1)
+		require.Equal(t, path, info[0].Path)
+
+		if prev != "" {
+			// Keep the previous file older than the newest one.
+			appendLine(t, prev, "stale line")
+			require.NoError(t, os.Chtimes(prev, base, base))
+			select {
+			case logEntry := <-ch1.Chan():
+				require.FailNow(t, "unexpected log line", logEntry.Line)
+			case <-time.After(500 * time.Millisecond):
+			}
+		}
+		prev = path
+	}
+}
+
+func TestNewestOnlyFinalizeConcurrently(t *testing.T) {
+	defer func(timeout time.Duration) { finalizeTimeout = timeout }(finalizeTimeout)
+	finalizeTimeout = 500 * time.Millisecond
+
+	opts := component.Options{
+		Logger:        util.TestFlowLogger(t),
+		Registerer:    prometheus.NewRegistry(),
+		OnStateChange: func(e component.Exports) {},
+		DataPath:      t.TempDir(),
+	}
+
+	ch1 := loki.NewLogsReceiver()
+	args := DefaultArguments
+	args.ForwardTo = []loki.LogsReceiver{ch1}
+	args.NewestOnly = true
+
+	// Each file is too large to be read while the component isn't running,
+	// so none of them reaches its end.
+	var all, newest []discovery.Target
+	lines := strings.Repeat("some log line\n", 100_000)
+	for i := 0; i < 4; i++ {
+		dir := t.TempDir()
+		old := filepath.Join(dir, "old.log")
+		require.NoError(t, os.WriteFile(old, []byte(lines), 0600))
+		all = append(all, discovery.Target{"__path__": old})
+	}
+	args.Targets = all
+
+	c, err := New(opts, args)
+	require.NoError(t, err)
+
+	for _, target := range all {
+		path := filepath.Join(filepath.Dir(target["__path__"]), "new.log")
+		require.NoError(t, os.WriteFile(path, nil, 0600))
+		newest = append(newest, discovery.Target{"__path__": path})
+	}
+
+	// The superseded files are waited for concurrently rather than one
+	// after the other.
+	start := time.Now()
+	c.finalizeSuperseded(append(all, newest...), newest)
+	require.Less(t, time.Since(start), 2*finalizeTimeout)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Run(ctx)
+	go func() {
+		for {
+			select {
+			case <-ch1.Chan():
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func TestNewestPerDirectory(t *testing.T) {
+	dirA, dirB := t.TempDir(), t.TempDir()
+	base := time.Now().Add(-time.Hour)
+
+	newFile := func(dir, name string, age time.Duration) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, nil, 0600))
+		require.NoError(t, os.Chtimes(path, base.Add(-age), base.Add(-age)))
+		return path
+	}
+	oldA := newFile(dirA, "old.log", time.Minute)
+	newA := newFile(dirA, "new.log", 0)
+	newB := newFile(dirB, "new.log", 0)
+	oldB := newFile(dirB, "old.log", time.Minute)
+	missing := filepath.Join(dirA, "missing.log")
+
+	targets := []discovery.Target{
+		{"__path__": oldA},
+		{"__path__": newA, "job": "a"},
+		{"__path__": newA, "job": "b"},
+		{"__path__": newB},
+		{"__path__": oldB},
+		{"__path__": missing},
+	}
+	require.Equal(t, []discovery.Target{
+		{"__path__": newA, "job": "a"},
+		{"__path__": newA, "job": "b"},
+		{"__path__": newB},
+		{"__path__": missing},
+	}, newestPerDirectory(targets))
+}
+
+func TestPositionsWriteErrorHealth(t *testing.T) {
+	dataPath := filepath.Join(t.TempDir(), "data")
+	opts := component.Options{
+		Logger:        util.TestFlowLogger(t),
+		Registerer:    prometheus.NewRegistry(),
+		OnStateChange: func(e component.Exports) {},
+		DataPath:      dataPath,
+	}
+
+	f, err := os.CreateTemp(t.TempDir(), "example")
+	require.NoError(t, err)
+	defer f.Close()
+
+	c, err := New(opts, Arguments{
+		Targets:   []discovery.Target{{"__path__": f.Name()}},
+		ForwardTo: []loki.LogsReceiver{loki.NewLogsReceiver()},
+	})
+	require.NoError(t, err)
+	require.Equal(t, component.HealthTypeHealthy, c.CurrentHealth().Health)
+
+	// Removing the data directory makes the positions file unwritable.
+	require.NoError(t, os.RemoveAll(dataPath))
+	c.posFile.Put(f.Name(), "{}", 10)
+	c.posFile.Sync()
+
+	health := c.CurrentHealth()
+	require.Equal(t, component.HealthTypeUnhealthy, health.Health)
+	require.Contains(t, health.Message, "failed to write positions file")
+
+	// The component becomes healthy again once the positions file is written.
+	require.NoError(t, os.MkdirAll(dataPath, 0750))
+	c.posFile.Sync()
+	require.Equal(t, component.HealthTypeHealthy, c.CurrentHealth().Health)
+
+	for _, r := range c.readers {
+		r.Stop()
+	}
+	c.posFile.Stop()
+}
+
+func TestFileWatch_FSNotify(t *testing.T) {
+	ctx, cancel := context.WithCancel(componenttest.TestContext(t))
+	defer cancel()
+
+	// Create file to log to.
+	f, err := os.CreateTemp(t.TempDir(), "example")
+	require.NoError(t, err)
+	defer f.Close()
+
+	ctrl, err := componenttest.NewControllerFromID(util.TestLogger(t), "loki.source.file")
+	require.NoError(t, err)
+
+	ch1 := loki.NewLogsReceiver()
+
+	args := Arguments{
+		Targets: []discovery.Target{{
+			"__path__": f.Name(),
+			"foo":      "bar",
+		}},
+		ForwardTo: []loki.LogsReceiver{ch1},
+		FileWatch: FileWatch{
+			// Poll rarely enough that entries read in time can only come from
+			// filesystem events.
+			MinPollFrequency: time.Minute,
+			MaxPollFrequency: time.Minute,
+			Detector:         filedetector.DetectorFSNotify,
+		},
+	}
+
+	go func() {
+		err := ctrl.Run(ctx, args)
+		require.NoError(t, err)
+	}()
+
+	err = ctrl.WaitRunning(time.Minute)
+	require.NoError(t, err)
+
+	for _, line := range []string{"first line", "second line"} {
+		timeBeforeWriting := time.Now()
+
+		_, err = f.Write([]byte(line + "\n"))
+		require.NoError(t, err)
+
+		select {
+		case logEntry := <-ch1.Chan():
+			require.Less(t, time.Since(timeBeforeWriting), 5*time.Second)
+			require.Equal(t, line, logEntry.Line)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "failed waiting for log line")
+		}
+	}
+}
+
+func TestFileWatch_Detector(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   string
+		expected filedetector.Detector
+		err      string
+	}{
+		{
+			name:     "default",
+			config:   `file_watch { }`,
+			expected: filedetector.DetectorPoll,
+		},
+		{
+			name:     "fsnotify",
+			config:   `file_watch { detector = "fsnotify" }`,
+			expected: filedetector.DetectorFSNotify,
+		},
+		{
+			name:   "empty",
+			config: `file_watch { detector = "" }`,
+			err:    "detector must not be empty, expected fsnotify or poll",
+		},
+		{
+			name:   "unrecognized",
+			config: `file_watch { detector = "inotify" }`,
+			err:    `unrecognized detector "inotify", expected fsnotify or poll`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := "targets = []\nforward_to = []\n" + tt.config
+
+			var args Arguments
+			err := river.Unmarshal([]byte(cfg), &args)
+			if tt.err != "" {
+				require.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, args.FileWatch.Detector)
+			require.Equal(t, DefaultArguments.FileWatch.MinPollFrequency, args.FileWatch.MinPollFrequency)
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package file
+
+import (
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-kit/log"
+	"github.com/grafana/agent/internal/flow/logging/level"
+)
+
+// canUseFSNotify returns true if filesystem events can be used to detect
+// changes to files. If they're unavailable, a warning is logged and files
+// should be polled instead.
+//
+// Tailers share a single, process-wide watcher which exits the process if it
+// can't be created, for example when the limit of inotify instances (EMFILE)
+// is reached. Files which can't be watched once it exists, for example when
+// the limit of inotify watches (ENOSPC) is reached, are polled by their
+// tailer instead.
+func canUseFSNotify(logger log.Logger) bool {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		level.Warn(logger).Log("msg", "filesystem events are unavailable, falling back to polling files", "err", err)
+		return false
+	}
+	_ = watcher.Close()
+	return true
+}
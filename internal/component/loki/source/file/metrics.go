@@ -16,7 +16,10 @@ type metrics struct {
 	totalBytes       *prometheus.GaugeVec
 	readLines        *prometheus.CounterVec
 	encodingFailures *prometheus.CounterVec
+	truncatedLines   *prometheus.CounterVec
+	dedupedLines     *prometheus.CounterVec
 	filesActive      prometheus.Gauge
+	filesRefused     prometheus.Gauge
 }
 
 // newMetrics creates a new set of file metrics. If reg is non-nil, the metrics
@@ -41,18 +44,34 @@ func newMetrics(reg prometheus.Registerer) *metrics {
 		Name: "loki_source_file_encoding_failures_total",
 		Help: "Number of encoding failures.",
 	}, []string{"path"})
+	m.truncatedLines = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "loki_source_file_truncated_lines_total",
+		Help: "Number of lines truncated because they were longer than max_line_bytes.",
+	}, []string{"path"})
+	m.dedupedLines = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "loki_source_file_deduplicated_lines_total",
+		Help: "Number of lines dropped because they repeated the previous line within dedup_window.",
+	}, []string{"path"})
 	m.filesActive = prometheus.NewGauge(prometheus.GaugeOpts{
 		Name: "loki_source_file_files_active_total",
 		Help: "Number of active files.",
 	})
 
+	m.filesRefused = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "loki_source_file_files_refused",
+		Help: "Number of files not tailed because max_open_files was reached.",
+	})
+
 	if reg != nil {
 		reg.MustRegister(
 			m.readBytes,
 			m.totalBytes,
 			m.readLines,
 			m.encodingFailures,
+			m.truncatedLines,
+			m.dedupedLines,
 			m.filesActive,
+			m.filesRefused,
 		)
 	}
 
@@ -10,6 +10,7 @@ import (
 	"os"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/go-kit/log"
 	"github.com/grafana/agent/internal/component/common/loki"
@@ -32,9 +33,14 @@ type tailer struct {
 	handler   loki.EntryHandler
 	positions positions.Positions
 
-	path   string
-	labels string
-	tail   *tail.Tail
+	path        string
+	labels      string
+	pollOptions watch.PollingFileWatcherOptions
+
+	tailMtx  sync.Mutex
+	tail     *tail.Tail
+	poll     bool // Whether tail polls the file rather than waiting for filesystem events.
+	stopping bool
 
 	posAndSizeMtx sync.Mutex
 	stopOnce      sync.Once
@@ -45,10 +51,13 @@ type tailer struct {
 	done    chan struct{}
 
 	decoder *encoding.Decoder
+
+	// lastEncodingErr holds the last error encountered while decoding a line.
+	lastEncodingErr atomic.Error
 }
 
 func newTailer(metrics *metrics, logger log.Logger, handler loki.EntryHandler, positions positions.Positions, path string,
-	labels string, encoding string, pollOptions watch.PollingFileWatcherOptions, tailFromEnd bool) (*tailer, error) {
+	labels string, encoding string, poll bool, pollOptions watch.PollingFileWatcherOptions, tailFromEnd bool) (*tailer, error) {
 	// Simple check to make sure the file we are tailing doesn't
 	// have a position already saved which is past the end of the file.
 	fi, err := os.Stat(path)
@@ -62,6 +71,7 @@ func newTailer(metrics *metrics, logger log.Logger, handler loki.EntryHandler, p
 
 	if fi.Size() < pos {
 		positions.Remove(path, labels)
+		pos = 0
 	}
 
 	// If no cached position is found and the tailFromEnd option is enabled.
@@ -75,35 +85,26 @@ func newTailer(metrics *metrics, logger log.Logger, handler loki.EntryHandler, p
 		}
 	}
 
-	tail, err := tail.TailFile(path, tail.Config{
-		Follow:    true,
-		Poll:      true,
-		ReOpen:    true,
-		MustExist: true,
-		Location: &tail.SeekInfo{
-			Offset: pos,
-			Whence: 0,
-		},
-		Logger:      util.NewLogAdapter(logger),
-		PollOptions: pollOptions,
-	})
+	tail, err := startTail(logger, path, pos, poll, pollOptions)
 	if err != nil {
 		return nil, err
 	}
 
 	logger = log.With(logger, "component", "tailer")
 	tailer := &tailer{
-		metrics:   metrics,
-		logger:    logger,
-		handler:   loki.AddLabelsMiddleware(model.LabelSet{filenameLabel: model.LabelValue(path)}).Wrap(handler),
-		positions: positions,
-		path:      path,
-		labels:    labels,
-		tail:      tail,
-		running:   atomic.NewBool(false),
-		posquit:   make(chan struct{}),
-		posdone:   make(chan struct{}),
-		done:      make(chan struct{}),
+		metrics:     metrics,
+		logger:      logger,
+		handler:     loki.AddLabelsMiddleware(model.LabelSet{filenameLabel: model.LabelValue(path)}).Wrap(handler),
+		positions:   positions,
+		path:        path,
+		labels:      labels,
+		pollOptions: pollOptions,
+		tail:        tail,
+		poll:        poll,
+		running:     atomic.NewBool(false),
+		posquit:     make(chan struct{}),
+		posdone:     make(chan struct{}),
+		done:        make(chan struct{}),
 	}
 
 	if encoding != "" {
@@ -122,6 +123,25 @@ func newTailer(metrics *metrics, logger log.Logger, handler loki.EntryHandler, p
 	return tailer, nil
 }
 
+// tailFile starts tailing a file. It's a variable so tests can replace it.
+var tailFile = tail.TailFile
+
+// startTail starts tailing the file at path from the offset pos.
+func startTail(logger log.Logger, path string, pos int64, poll bool, pollOptions watch.PollingFileWatcherOptions) (*tail.Tail, error) {
+	return tailFile(path, tail.Config{
+		Follow:    true,
+		Poll:      poll,
+		ReOpen:    true,
+		MustExist: true,
+		Location: &tail.SeekInfo{
+			Offset: pos,
+			Whence: 0,
+		},
+		Logger:      util.NewLogAdapter(logger),
+		PollOptions: pollOptions,
+	})
+}
+
 // getLastLinePosition returns the offset of the start of the last line in the file at the given path.
 // It will read chunks of bytes starting from the end of the file to return the position of the last '\n' + 1.
 // If it cannot find any '\n' it will return 0.
@@ -200,7 +220,7 @@ func (t *tailer) updatePosition() {
 			err := t.MarkPositionAndSize()
 			if err != nil {
 				level.Error(t.logger).Log("msg", "position timer: error getting tail position and/or size, stopping tailer", "path", t.path, "error", err)
-				err := t.tail.Stop()
+				err := t.getTail().Stop()
 				if err != nil {
 					level.Error(t.logger).Log("msg", "position timer: error stopping tailer", "path", t.path, "error", err)
 				}
@@ -233,10 +253,15 @@ func (t *tailer) readLines() {
 		close(t.posquit)
 	}()
 	entries := t.handler.Chan()
+	tail := t.getTail()
 	for {
-		line, ok := <-t.tail.Lines
+		line, ok := <-tail.Lines
 		if !ok {
-			level.Info(t.logger).Log("msg", "tail routine: tail channel closed, stopping tailer", "path", t.path, "reason", t.tail.Tomb.Err())
+			if polling := t.fallBackToPolling(tail); polling != nil {
+				tail = polling
+				continue
+			}
+			level.Info(t.logger).Log("msg", "tail routine: tail channel closed, stopping tailer", "path", t.path, "reason", tail.Tomb.Err())
 			return
 		}
 
@@ -253,10 +278,16 @@ func (t *tailer) readLines() {
 			if err != nil {
 				level.Debug(t.logger).Log("msg", "failed to convert encoding", "error", err)
 				t.metrics.encodingFailures.WithLabelValues(t.path).Inc()
+				t.lastEncodingErr.Store(err)
 				text = fmt.Sprintf("the requested encoding conversion for this line failed in Grafana Agent Flow: %s", err.Error())
 			}
 		} else {
 			text = line.Text
+			if !utf8.ValidString(text) {
+				level.Debug(t.logger).Log("msg", "line contains invalid UTF-8", "path", t.path)
+				t.metrics.encodingFailures.WithLabelValues(t.path).Inc()
+				t.lastEncodingErr.Store(errInvalidUTF8)
+			}
 		}
 
 		t.metrics.readLines.WithLabelValues(t.path).Inc()
@@ -270,12 +301,55 @@ func (t *tailer) readLines() {
 	}
 }
 
+// fallBackToPolling replaces failed, a tail which waited for filesystem events
+// and stopped with an error, with a tail which polls the file. This happens
+// when the file can't be watched, for example because the limit of inotify
+// watches is reached. The new tail resumes from the last saved position, so
+// lines read since then are sent again. It returns nil if failed shouldn't be
+// replaced.
+func (t *tailer) fallBackToPolling(failed *tail.Tail) *tail.Tail {
+	t.tailMtx.Lock()
+	defer t.tailMtx.Unlock()
+
+	reason := failed.Err()
+	if t.poll || t.stopping || reason == nil {
+		return nil
+	}
+
+	pos, err := t.positions.Get(t.path, t.labels)
+	if err != nil {
+		level.Error(t.logger).Log("msg", "failed to get position to resume polling from", "path", t.path, "error", err)
+		return nil
+	}
+	if fi, err := os.Stat(t.path); err == nil && fi.Size() < pos {
+		pos = 0
+	}
+
+	polling, err := startTail(t.logger, t.path, pos, true, t.pollOptions)
+	if err != nil {
+		level.Error(t.logger).Log("msg", "failed to fall back to polling", "path", t.path, "error", err)
+		return nil
+	}
+	level.Warn(t.logger).Log("msg", "failed waiting for filesystem events, falling back to polling", "path", t.path, "reason", reason)
+
+	t.tail = polling
+	t.poll = true
+	return polling
+}
+
+func (t *tailer) getTail() *tail.Tail {
+	t.tailMtx.Lock()
+	defer t.tailMtx.Unlock()
+	return t.tail
+}
+
 func (t *tailer) MarkPositionAndSize() error {
 	// Lock this update as there are 2 timers calling this routine, the sync in filetarget and the positions sync in this file.
 	t.posAndSizeMtx.Lock()
 	defer t.posAndSizeMtx.Unlock()
 
-	size, err := t.tail.Size()
+	tail := t.getTail()
+	size, err := tail.Size()
 	if err != nil {
 		// If the file no longer exists, no need to save position information
 		if err == os.ErrNotExist {
@@ -285,7 +359,7 @@ func (t *tailer) MarkPositionAndSize() error {
 		return err
 	}
 
-	pos, err := t.tail.Tell()
+	pos, err := tail.Tell()
 	if err != nil {
 		return err
 	}
@@ -308,8 +382,13 @@ func (t *tailer) Stop() {
 			level.Error(t.logger).Log("msg", "error marking file position when stopping tailer", "path", t.path, "error", err)
 		}
 
-		// Stop the underlying tailer
-		err = t.tail.Stop()
+		// Stop the underlying tailer. Marking the tailer as stopping prevents
+		// readLines from replacing it while it's stopped.
+		t.tailMtx.Lock()
+		t.stopping = true
+		tail := t.tail
+		t.tailMtx.Unlock()
+		err = tail.Stop()
 		if err != nil {
 			level.Error(t.logger).Log("msg", "error stopping tailer", "path", t.path, "error", err)
 		}
@@ -326,6 +405,12 @@ func (t *tailer) IsRunning() bool {
 	return t.running.Load()
 }
 
+// LastEncodingError returns the last error encountered while decoding a line,
+// if any.
+func (t *tailer) LastEncodingError() error {
+	return t.lastEncodingErr.Load()
+}
+
 func (t *tailer) convertToUTF8(text string) (string, error) {
 	res, _, err := transform.String(t.decoder, text)
 	if err != nil {
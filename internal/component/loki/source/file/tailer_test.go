@@ -2,8 +2,19 @@ package file
 
 import (
 	"bytes"
+	"errors"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/agent/internal/component/common/loki/client/fake"
+	"github.com/grafana/agent/internal/component/common/loki/positions"
+	"github.com/grafana/tail"
+	"github.com/grafana/tail/watch"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
 )
 
 func createTempFileWithContent(t *testing.T, content []byte) string {
@@ -78,3 +89,54 @@ func TestGetLastLinePosition(t *testing.T) {
 		})
 	}
 }
+
+// TestTailerFallBackToPolling checks that a tailer whose tail stops with an
+// error while waiting for filesystem events, such as when the file can't be
+// watched, keeps reading the file by polling it.
+func TestTailerFallBackToPolling(t *testing.T) {
+	defer func(f func(string, tail.Config) (*tail.Tail, error)) { tailFile = f }(tailFile)
+	tailFile = func(filename string, config tail.Config) (*tail.Tail, error) {
+		tl, err := tail.TailFile(filename, config)
+		if err == nil && !config.Poll {
+			tl.Kill(errors.New("no space left on device"))
+		}
+		return tl, err
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	require.NoError(t, os.WriteFile(path, []byte("line1\n"), 0644))
+
+	ps, err := positions.New(log.NewNopLogger(), positions.Config{
+		SyncPeriod:    10 * time.Second,
+		PositionsFile: filepath.Join(dir, "positions.yml"),
+	})
+	require.NoError(t, err)
+	defer ps.Stop()
+
+	handler := fake.NewClient(func() {})
+	defer handler.Stop()
+
+	pollOptions := watch.PollingFileWatcherOptions{
+		MinPollFrequency: 10 * time.Millisecond,
+		MaxPollFrequency: 10 * time.Millisecond,
+	}
+	tailer, err := newTailer(newMetrics(prometheus.NewRegistry()), log.NewNopLogger(), handler, ps, path, "{}", "", false, pollOptions, false)
+	require.NoError(t, err)
+	defer tailer.Stop()
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	defer f.Close()
+	_, err = f.WriteString("line2\n")
+	require.NoError(t, err)
+
+	// The polling tail resumes from the last saved position, so lines read
+	// since then may be sent again.
+	require.Eventually(t, func() bool {
+		received := handler.Received()
+		return len(received) > 0 && received[len(received)-1].Line == "line2"
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, "line1", handler.Received()[0].Line)
+	require.True(t, tailer.IsRunning())
+}
@@ -14,6 +14,27 @@ type PullConfig struct {
 	Labels               map[string]string `river:"labels,attr,optional"`
 	UseIncomingTimestamp bool              `river:"use_incoming_timestamp,attr,optional"`
 	UseFullLine          bool              `river:"use_full_line,attr,optional"`
+	BatchSize            int               `river:"batch_size,attr,optional"`
+	BatchWait            time.Duration     `river:"batch_wait,attr,optional"`
+}
+
+// SetToDefault implements river.Defaulter.
+func (p *PullConfig) SetToDefault() {
+	*p = PullConfig{
+		BatchSize: 1,
+		BatchWait: time.Second,
+	}
+}
+
+// Validate implements river.Validator.
+func (p *PullConfig) Validate() error {
+	if p.BatchSize < 1 {
+		return fmt.Errorf("batch_size must be at least 1")
+	}
+	if p.BatchWait <= 0 {
+		return fmt.Errorf("batch_wait must be greater than zero")
+	}
+	return nil
 }
 
 // PushConfig configures a GCPLog target with the 'push' strategy.
@@ -15,6 +15,8 @@ type Metrics struct {
 	gcplogEntries                 *prometheus.CounterVec
 	gcplogErrors                  *prometheus.CounterVec
 	gcplogTargetLastSuccessScrape *prometheus.GaugeVec
+	gcplogBatchSize               *prometheus.HistogramVec
+	gcplogFlushDuration           *prometheus.HistogramVec
 
 	gcpPushEntries *prometheus.CounterVec
 	gcpPushErrors  *prometheus.CounterVec
@@ -41,6 +43,18 @@ func NewMetrics(reg prometheus.Registerer) *Metrics {
 		Help: "Timestamp of target's last successful poll",
 	}, []string{"project", "target"})
 
+	m.gcplogBatchSize = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "loki_source_gcplog_pull_batch_size",
+		Help:    "Number of entries in the batches handed off by the gcplog target",
+		Buckets: prometheus.ExponentialBuckets(1, 2, 11),
+	}, []string{"project"})
+
+	m.gcplogFlushDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "loki_source_gcplog_pull_flush_duration_seconds",
+		Help:    "Time taken to hand off a batch of entries by the gcplog target",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"project"})
+
 	// Push subscription metrics
 	m.gcpPushEntries = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Name: "loki_source_gcplog_push_entries_total",
@@ -56,6 +70,8 @@ func NewMetrics(reg prometheus.Registerer) *Metrics {
 		m.gcplogEntries,
 		m.gcplogErrors,
 		m.gcplogTargetLastSuccessScrape,
+		m.gcplogBatchSize,
+		m.gcplogFlushDuration,
 		m.gcpPushEntries,
 		m.gcpPushErrors,
 	)
@@ -102,9 +102,33 @@ func (t *PullTarget) run() error {
 		lbls[model.LabelName(k)] = model.LabelValue(v)
 	}
 
+	batchSize := t.config.BatchSize
+	if batchSize < 1 {
+		batchSize = 1
+	}
+
+	var (
+		batch   = make([]pendingEntry, 0, batchSize)
+		timer   *time.Timer
+		flushCh <-chan time.Time
+	)
+	flush := func() {
+		if timer != nil {
+			timer.Stop()
+		}
+		flushCh = nil
+		t.flush(batch)
+		batch = batch[:0]
+	}
+
 	for {
 		select {
 		case <-t.ctx.Done():
+			// Messages of an incomplete batch weren't handed off, so they're
+			// redelivered.
+			for _, p := range batch {
+				p.msg.Nack()
+			}
 			return t.ctx.Err()
 		case m := <-t.msgs:
 			entry, err := parseGCPLogsEntry(m.Data, lbls, nil, t.config.UseIncomingTimestamp, t.config.UseFullLine, t.relabelConfig)
@@ -113,13 +137,50 @@ func (t *PullTarget) run() error {
 				m.Ack()
 				break
 			}
-			t.handler.Chan() <- entry
-			m.Ack() // Ack only after log is sent.
-			t.metrics.gcplogEntries.WithLabelValues(t.config.ProjectID).Inc()
+			batch = append(batch, pendingEntry{msg: m, entry: entry})
+			if len(batch) >= batchSize {
+				flush()
+			} else if len(batch) == 1 {
+				timer = time.NewTimer(t.config.BatchWait)
+				flushCh = timer.C
+			}
+		case <-flushCh:
+			flush()
 		}
 	}
 }
 
+// pendingEntry is a log entry waiting in a batch, along with the message it
+// was parsed from.
+type pendingEntry struct {
+	msg   *pubsub.Message
+	entry loki.Entry
+}
+
+// flush hands off the entries of batch to the handler, and acks their
+// messages only once the whole batch was handed off. If the target is stopped
+// before that, all messages of the batch are nacked so that they're
+// redelivered, even if some of their entries were already sent.
+func (t *PullTarget) flush(batch []pendingEntry) {
+	start := time.Now()
+	for _, p := range batch {
+		select {
+		case <-t.ctx.Done():
+			for _, p := range batch {
+				p.msg.Nack()
+			}
+			return
+		case t.handler.Chan() <- p.entry:
+		}
+	}
+	for _, p := range batch {
+		p.msg.Ack() // Ack only after the batch is sent.
+	}
+	t.metrics.gcplogEntries.WithLabelValues(t.config.ProjectID).Add(float64(len(batch)))
+	t.metrics.gcplogBatchSize.WithLabelValues(t.config.ProjectID).Observe(float64(len(batch)))
+	t.metrics.gcplogFlushDuration.WithLabelValues(t.config.ProjectID).Observe(time.Since(start).Seconds())
+}
+
 func (t *PullTarget) consumeSubscription() {
 	// NOTE(kavi): `cancel` the context as exiting from this goroutine should stop main `run` loop
 	// It makesense as no more messages will be received.
@@ -4,16 +4,23 @@ import (
 	"context"
 	"errors"
 	"io"
+	"strings"
 	"testing"
 	"time"
 
 	"cloud.google.com/go/pubsub"
+	"cloud.google.com/go/pubsub/pstest"
 	"github.com/go-kit/log"
 	"github.com/grafana/dskit/backoff"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 	"gotest.tools/assert"
 
+	"github.com/grafana/agent/internal/component/common/loki"
 	"github.com/grafana/agent/internal/component/common/loki/client/fake"
 	"github.com/grafana/agent/internal/component/loki/source/gcplog/gcptypes"
 )
@@ -87,6 +94,127 @@ func TestPullTarget_RunStop(t *testing.T) {
 	})
 }
 
+func TestPullTarget_Batching(t *testing.T) {
+	t.Run("it hands off and acks messages in batches", func(t *testing.T) {
+		srv, opt := testPubsubServer(t)
+		promClient := fake.NewClient(func() {})
+		reg := prometheus.NewRegistry()
+
+		target, err := NewPullTarget(NewMetrics(reg), log.NewNopLogger(), promClient, "test", &gcptypes.PullConfig{
+			ProjectID:    project,
+			Subscription: subscription,
+			BatchSize:    3,
+			BatchWait:    time.Hour,
+		}, nil, opt)
+		require.NoError(t, err)
+		defer target.Stop()
+
+		var ids []string
+		for i := 0; i < 2; i++ {
+			ids = append(ids, srv.Publish(testTopic, []byte(gcpLogEntry), nil))
+		}
+
+		// The batch isn't full yet, so nothing is handed off or acked.
+		require.Eventually(t, func() bool {
+			return srv.Message(ids[0]).Deliveries > 0 && srv.Message(ids[1]).Deliveries > 0
+		}, 5*time.Second, 10*time.Millisecond)
+		time.Sleep(100 * time.Millisecond)
+		require.Empty(t, promClient.Received())
+		for _, id := range ids {
+			require.Zero(t, srv.Message(id).Acks)
+		}
+
+		ids = append(ids, srv.Publish(testTopic, []byte(gcpLogEntry), nil))
+		require.Eventually(t, func() bool {
+			return len(promClient.Received()) == 3
+		}, 5*time.Second, 10*time.Millisecond)
+		require.Eventually(t, func() bool {
+			for _, id := range ids {
+				if srv.Message(id).Acks == 0 {
+					return false
+				}
+			}
+			return true
+		}, 5*time.Second, 10*time.Millisecond)
+
+		require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+			# HELP loki_source_gcplog_pull_entries_total Number of entries received by the gcplog target
+			# TYPE loki_source_gcplog_pull_entries_total counter
+			loki_source_gcplog_pull_entries_total{project="test-project"} 3
+		`), "loki_source_gcplog_pull_entries_total"))
+		require.Equal(t, 1, testutil.CollectAndCount(target.metrics.gcplogBatchSize))
+		require.Equal(t, 1, testutil.CollectAndCount(target.metrics.gcplogFlushDuration))
+	})
+
+	t.Run("it flushes an incomplete batch after batch_wait", func(t *testing.T) {
+		srv, opt := testPubsubServer(t)
+		promClient := fake.NewClient(func() {})
+
+		target, err := NewPullTarget(NewMetrics(prometheus.NewRegistry()), log.NewNopLogger(), promClient, "test", &gcptypes.PullConfig{
+			ProjectID:    project,
+			Subscription: subscription,
+			BatchSize:    10,
+			BatchWait:    100 * time.Millisecond,
+		}, nil, opt)
+		require.NoError(t, err)
+		defer target.Stop()
+
+		id := srv.Publish(testTopic, []byte(gcpLogEntry), nil)
+		require.Eventually(t, func() bool {
+			return len(promClient.Received()) == 1 && srv.Message(id).Acks == 1
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("it doesn't ack messages which weren't handed off", func(t *testing.T) {
+		srv, opt := testPubsubServer(t)
+
+		// Nothing reads from the handler, so the batch is never handed off.
+		handler := loki.NewEntryHandler(make(chan loki.Entry), func() {})
+
+		target, err := NewPullTarget(NewMetrics(prometheus.NewRegistry()), log.NewNopLogger(), handler, "test", &gcptypes.PullConfig{
+			ProjectID:    project,
+			Subscription: subscription,
+			BatchSize:    1,
+			BatchWait:    time.Second,
+		}, nil, opt)
+		require.NoError(t, err)
+
+		id := srv.Publish(testTopic, []byte(gcpLogEntry), nil)
+		require.Eventually(t, func() bool {
+			return srv.Message(id).Deliveries > 0
+		}, 5*time.Second, 10*time.Millisecond)
+
+		require.NoError(t, target.Stop())
+		require.Zero(t, srv.Message(id).Acks)
+	})
+}
+
+const testTopic = "projects/" + project + "/topics/test-topic"
+
+// testPubsubServer starts a fake Pub/Sub server with a subscription to the
+// test topic, and returns the client option to connect to it.
+func testPubsubServer(t *testing.T) (*pstest.Server, option.ClientOption) {
+	t.Helper()
+
+	srv := pstest.NewServer()
+	t.Cleanup(func() { srv.Close() })
+
+	conn, err := grpc.Dial(srv.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	opt := option.WithGRPCConn(conn)
+
+	ctx := context.Background()
+	client, err := pubsub.NewClient(ctx, project, opt)
+	require.NoError(t, err)
+	topic, err := client.CreateTopic(ctx, "test-topic")
+	require.NoError(t, err)
+	_, err = client.CreateSubscription(ctx, subscription, pubsub.SubscriptionConfig{Topic: topic})
+	require.NoError(t, err)
+
+	return srv, opt
+}
+
 // func TestPullTarget_Ready(t *testing.T) {
 // 	tc := testPullTarget(t)
 // 	assert.Equal(t, true, tc.target.Ready())
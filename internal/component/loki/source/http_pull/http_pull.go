@@ -0,0 +1,240 @@
+// Package http_pull implements the loki.source.http_pull component.
+package http_pull //nolint:golint
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/grafana/agent/internal/component"
+	component_config "github.com/grafana/agent/internal/component/common/config"
+	"github.com/grafana/agent/internal/component/common/loki"
+	"github.com/grafana/agent/internal/component/common/loki/positions"
+	"github.com/grafana/agent/internal/featuregate"
+	prom_config "github.com/prometheus/common/config"
+	"github.com/prometheus/common/model"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:      "loki.source.http_pull",
+		Stability: featuregate.StabilityBeta,
+		Args:      Arguments{},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return New(opts, args.(Arguments))
+		},
+	})
+}
+
+// Arguments holds values which are used to configure the
+// loki.source.http_pull component.
+type Arguments struct {
+	URL          string              `river:"url,attr"`
+	PollInterval time.Duration       `river:"poll_interval,attr,optional"`
+	EntriesField string              `river:"entries_field,attr,optional"`
+	LineField    string              `river:"line_field,attr,optional"`
+	CursorField  string              `river:"cursor_field,attr,optional"`
+	Receivers    []loki.LogsReceiver `river:"forward_to,attr"`
+	Labels       map[string]string   `river:"labels,attr,optional"`
+
+	HTTPClientConfig component_config.HTTPClientConfig `river:",squash"`
+}
+
+// SetToDefault implements river.Defaulter.
+func (args *Arguments) SetToDefault() {
+	*args = Arguments{
+		PollInterval:     time.Minute,
+		HTTPClientConfig: component_config.DefaultHTTPClientConfig,
+	}
+}
+
+// Validate implements river.Validator.
+func (args *Arguments) Validate() error {
+	tmpl, err := args.urlTemplate()
+	if err != nil {
+		return err
+	}
+	u, err := executeURL(tmpl, "")
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid url %q: scheme must be http or https", args.URL)
+	}
+	if args.PollInterval <= 0 {
+		return fmt.Errorf("poll_interval must be greater than 0")
+	}
+	for name, field := range map[string]string{
+		"entries_field": args.EntriesField,
+		"line_field":    args.LineField,
+		"cursor_field":  args.CursorField,
+	} {
+		if _, err := parseFieldPath(field); err != nil {
+			return fmt.Errorf("invalid %s: %w", name, err)
+		}
+	}
+
+	// We must explicitly Validate because HTTPClientConfig is squashed and it won't run otherwise
+	return args.HTTPClientConfig.Validate()
+}
+
+// urlTemplate parses the url argument as a template of the URL to request.
+func (args *Arguments) urlTemplate() (*template.Template, error) {
+	tmpl, err := template.New("url").Option("missingkey=error").Parse(args.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// executeURL returns the URL to request for the given cursor.
+func executeURL(tmpl *template.Template, cursor string) (*url.URL, error) {
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, struct{ Cursor string }{cursor}); err != nil {
+		return nil, fmt.Errorf("invalid url template: %w", err)
+	}
+	u, err := url.Parse(sb.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+	return u, nil
+}
+
+var (
+	_ component.Component       = (*Component)(nil)
+	_ component.HealthComponent = (*Component)(nil)
+)
+
+// Component implements the loki.source.http_pull component.
+type Component struct {
+	opts      component.Options
+	metrics   *metrics
+	handler   chan loki.Entry
+	positions positions.Positions
+
+	mut       sync.RWMutex
+	r         *reader
+	receivers []loki.LogsReceiver
+}
+
+// New creates a new loki.source.http_pull component.
+func New(o component.Options, args Arguments) (*Component, error) {
+	err := os.MkdirAll(o.DataPath, 0750)
+	if err != nil {
+		return nil, err
+	}
+
+	positionsFile, err := positions.New(o.Logger, positions.Config{
+		SyncPeriod:        10 * time.Second,
+		PositionsFile:     filepath.Join(o.DataPath, "positions.yml"),
+		IgnoreInvalidYaml: false,
+		ReadOnly:          false,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Component{
+		opts:      o,
+		metrics:   newMetrics(o.Registerer),
+		handler:   make(chan loki.Entry),
+		positions: positionsFile,
+	}
+	if err := c.Update(args); err != nil {
+		positionsFile.Stop()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Run implements component.Component.
+func (c *Component) Run(ctx context.Context) error {
+	defer func() {
+		c.mut.Lock()
+		if c.r != nil {
+			c.r.Stop()
+		}
+		c.mut.Unlock()
+		c.positions.Stop()
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case entry := <-c.handler:
+			c.mut.RLock()
+			for _, receiver := range c.receivers {
+				receiver.Chan() <- entry
+			}
+			c.mut.RUnlock()
+		}
+	}
+}
+
+// Update implements component.Component.
+func (c *Component) Update(args component.Arguments) error {
+	newArgs := args.(Arguments)
+
+	tmpl, err := newArgs.urlTemplate()
+	if err != nil {
+		return err
+	}
+	client, err := prom_config.NewClientFromConfig(*newArgs.HTTPClientConfig.Convert(), c.opts.ID)
+	if err != nil {
+		return err
+	}
+
+	// The fields were checked by Validate.
+	entriesField, _ := parseFieldPath(newArgs.EntriesField)
+	lineField, _ := parseFieldPath(newArgs.LineField)
+	cursorField, _ := parseFieldPath(newArgs.CursorField)
+
+	labels := model.LabelSet{
+		model.LabelName("job"): model.LabelValue(c.opts.ID),
+	}
+	for k, v := range newArgs.Labels {
+		labels[model.LabelName(k)] = model.LabelValue(v)
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	// Stop the previous reader before starting a new one so that both don't
+	// write the cursor at the same time.
+	if c.r != nil {
+		c.r.Stop()
+	}
+
+	c.receivers = newArgs.Receivers
+	c.r = newReader(readerConfig{
+		Logger:       c.opts.Logger,
+		Client:       client,
+		URL:          tmpl,
+		PositionKey:  newArgs.URL,
+		PollInterval: newArgs.PollInterval,
+		EntriesField: entriesField,
+		LineField:    lineField,
+		CursorField:  cursorField,
+		Labels:       labels,
+	}, c.positions, c.metrics, c.handler)
+	return nil
+}
+
+// CurrentHealth implements component.HealthComponent. The component is
+// reported as unhealthy while the last poll of the endpoint failed.
+func (c *Component) CurrentHealth() component.Health {
+	c.mut.RLock()
+	defer c.mut.RUnlock()
+	if c.r == nil {
+		return component.Health{}
+	}
+	return c.r.CurrentHealth()
+}
@@ -0,0 +1,332 @@
+package http_pull //nolint:golint
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/internal/component"
+	"github.com/grafana/agent/internal/component/common/loki"
+	"github.com/grafana/agent/internal/util"
+	"github.com/grafana/river"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+)
+
+// stubEndpoint serves entries in pages of two, using the index of the first
+// entry of a page as its cursor.
+type stubEndpoint struct {
+	mut      sync.Mutex
+	entries  []string
+	requests []*http.Request
+}
+
+func (e *stubEndpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mut.Lock()
+	defer e.mut.Unlock()
+	e.requests = append(e.requests, r)
+
+	if user, pass, _ := r.BasicAuth(); user != "user" || pass != "pass" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var start int
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		start, _ = strconv.Atoi(cursor)
+	}
+	end := min(start+2, len(e.entries))
+
+	items := []map[string]any{}
+	for _, entry := range e.entries[start:end] {
+		items = append(items, map[string]any{"message": entry})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"data": map[string]any{"items": items},
+		"next": end,
+	})
+}
+
+func (e *stubEndpoint) Add(entries ...string) {
+	e.mut.Lock()
+	defer e.mut.Unlock()
+	e.entries = append(e.entries, entries...)
+}
+
+func (e *stubEndpoint) Cursors() []string {
+	e.mut.Lock()
+	defer e.mut.Unlock()
+	var cursors []string
+	for _, r := range e.requests {
+		cursors = append(cursors, r.URL.Query().Get("cursor"))
+	}
+	return cursors
+}
+
+func TestHTTPPull(t *testing.T) {
+	endpoint := &stubEndpoint{}
+	endpoint.Add("first", "second", "third")
+	srv := httptest.NewServer(endpoint)
+	defer srv.Close()
+
+	dataPath := t.TempDir()
+	receiver := loki.NewLogsReceiver()
+
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(`
+		url           = "`+srv.URL+`/logs?cursor={{.Cursor}}"
+		poll_interval = "1h"
+		entries_field = "$.data.items"
+		line_field    = "$.message"
+		cursor_field  = "$.next"
+		forward_to    = []
+		labels        = { "source" = "endpoint" }
+		basic_auth {
+			username = "user"
+			password = "pass"
+		}
+	`), &args))
+	args.Receivers = []loki.LogsReceiver{receiver}
+
+	// The first run pages through every entry until the cursor stops changing.
+	runComponent(t, dataPath, args, func(c *Component) {
+		for _, msg := range []string{"first", "second", "third"} {
+			requireEntry(t, receiver, msg)
+		}
+		require.Eventually(t, func() bool {
+			return c.CurrentHealth().Health == component.HealthTypeHealthy
+		}, 5*time.Second, 10*time.Millisecond)
+		require.Equal(t, 3.0, testutil.ToFloat64(c.metrics.lines))
+		require.Equal(t, 3.0, testutil.ToFloat64(c.metrics.requests))
+	})
+	require.Equal(t, []string{"", "2", "3"}, endpoint.Cursors())
+
+	// The second run resumes from the saved cursor.
+	endpoint.Add("fourth")
+	runComponent(t, dataPath, args, func(*Component) {
+		requireEntry(t, receiver, "fourth")
+	})
+	require.Equal(t, []string{"", "2", "3", "3"}, endpoint.Cursors()[:4])
+}
+
+func TestHTTPPullUnhealthy(t *testing.T) {
+	srv := httptest.NewServer(&stubEndpoint{})
+	defer srv.Close()
+
+	var args Arguments
+	args.SetToDefault()
+	args.URL = srv.URL
+	args.Receivers = []loki.LogsReceiver{loki.NewLogsReceiver()}
+
+	runComponent(t, t.TempDir(), args, func(c *Component) {
+		require.Eventually(t, func() bool {
+			return c.CurrentHealth().Health == component.HealthTypeUnhealthy
+		}, 5*time.Second, 10*time.Millisecond)
+		require.Contains(t, c.CurrentHealth().Message, "unexpected status code 401")
+		require.Equal(t, 1.0, testutil.ToFloat64(c.metrics.errors.WithLabelValues(requestError)))
+	})
+}
+
+func TestReadPage(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		lineField     string
+		expectedLines []string
+		expectedNext  string
+		expectedErr   string
+	}{
+		{
+			name:          "whole entries",
+			body:          `[{"msg":"a"},"b",1]`,
+			expectedLines: []string{`{"msg":"a"}`, "b", "1"},
+		},
+		{
+			name:          "entries without a line are skipped",
+			body:          `[{"msg":"a"},{"other":"b"},{"msg":null}]`,
+			lineField:     "msg",
+			expectedLines: []string{"a"},
+		},
+		{
+			name:        "not an array",
+			body:        `{"msg":"a"}`,
+			expectedErr: "invalid response: $ isn't an array",
+		},
+		{
+			name:        "invalid json",
+			body:        `[`,
+			expectedErr: "invalid response: unexpected EOF",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = w.Write([]byte(tc.body))
+			}))
+			defer srv.Close()
+
+			args := Arguments{URL: srv.URL}
+			tmpl, err := args.urlTemplate()
+			require.NoError(t, err)
+			lineField, err := parseFieldPath(tc.lineField)
+			require.NoError(t, err)
+
+			handler := make(chan loki.Entry, 10)
+			r := &reader{
+				cfg: readerConfig{
+					Logger:    util.TestFlowLogger(t),
+					Client:    srv.Client(),
+					URL:       tmpl,
+					LineField: lineField,
+					Labels:    model.LabelSet{"job": "test"},
+				},
+				metrics: newMetrics(nil),
+				handler: handler,
+			}
+
+			next, err := r.readPage(context.Background(), "")
+			close(handler)
+			if tc.expectedErr != "" {
+				require.EqualError(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedNext, next)
+
+			var lines []string
+			for entry := range handler {
+				lines = append(lines, entry.Line)
+			}
+			require.Equal(t, tc.expectedLines, lines)
+		})
+	}
+}
+
+func TestArguments(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         string
+		expectedErr string
+	}{
+		{
+			name: "valid",
+			cfg: `
+				url          = "https://logs.example.com/api/logs?after={{.Cursor}}"
+				line_field   = "$.message"
+				cursor_field = "next"
+				forward_to   = []
+				authorization {
+					type        = "Bearer"
+					credentials = "token"
+				}
+			`,
+		},
+		{
+			name: "invalid scheme",
+			cfg: `
+				url        = "tcp://logs.example.com"
+				forward_to = []
+			`,
+			expectedErr: `invalid url "tcp://logs.example.com": scheme must be http or https`,
+		},
+		{
+			name: "invalid template",
+			cfg: `
+				url        = "https://logs.example.com/?after={{.Cursor"
+				forward_to = []
+			`,
+			expectedErr: `invalid url template: template: url:1: unclosed action`,
+		},
+		{
+			name: "unknown template field",
+			cfg: `
+				url        = "https://logs.example.com/?after={{.Offset}}"
+				forward_to = []
+			`,
+			expectedErr: `invalid url template: template: url:1:34: executing "url" at <.Offset>: can't evaluate field Offset in type struct { Cursor string }`,
+		},
+		{
+			name: "invalid field",
+			cfg: `
+				url         = "https://logs.example.com"
+				line_field  = "$.data..message"
+				forward_to  = []
+			`,
+			expectedErr: `invalid line_field: empty field name in "data..message"`,
+		},
+		{
+			name: "invalid poll interval",
+			cfg: `
+				url           = "https://logs.example.com"
+				poll_interval = "0s"
+				forward_to    = []
+			`,
+			expectedErr: `poll_interval must be greater than 0`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var args Arguments
+			err := river.Unmarshal([]byte(tc.cfg), &args)
+			if tc.expectedErr == "" {
+				require.NoError(t, err)
+				require.Equal(t, time.Minute, args.PollInterval)
+				return
+			}
+			require.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func requireEntry(t *testing.T, receiver loki.LogsReceiver, line string) {
+	t.Helper()
+	select {
+	case entry := <-receiver.Chan():
+		require.Equal(t, line, entry.Line)
+		require.Equal(t, model.LabelSet{
+			"job":    "loki.source.http_pull.test",
+			"source": "endpoint",
+		}, entry.Labels)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "failed waiting for log line")
+	}
+}
+
+// runComponent runs the component until check returns, then waits for it to
+// exit so that the positions file is written.
+func runComponent(t *testing.T, dataPath string, args Arguments, check func(c *Component)) {
+	t.Helper()
+
+	c, err := New(testOptions(t, dataPath), args)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		require.NoError(t, c.Run(ctx))
+	}()
+
+	check(c)
+	cancel()
+	<-done
+}
+
+func testOptions(t *testing.T, dataPath string) component.Options {
+	return component.Options{
+		ID:         "loki.source.http_pull.test",
+		Logger:     util.TestFlowLogger(t),
+		Registerer: prometheus.NewRegistry(),
+		DataPath:   dataPath,
+	}
+}
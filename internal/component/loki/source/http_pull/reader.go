@@ -0,0 +1,313 @@
+package http_pull //nolint:golint
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/agent/internal/component"
+	"github.com/grafana/agent/internal/component/common/loki"
+	"github.com/grafana/agent/internal/component/common/loki/positions"
+	"github.com/grafana/agent/internal/flow/logging/level"
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+)
+
+const (
+	requestError         = "request"
+	invalidResponseError = "invalid_response"
+	noLineError          = "no_line"
+)
+
+type metrics struct {
+	requests prometheus.Counter
+	errors   *prometheus.CounterVec
+	lines    prometheus.Counter
+}
+
+func newMetrics(reg prometheus.Registerer) *metrics {
+	var m metrics
+
+	m.requests = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "loki_source_http_pull_requests_total",
+		Help: "Total number of requests sent to the endpoint",
+	})
+	m.errors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "loki_source_http_pull_errors_total",
+		Help: "Total number of failed requests and entries which couldn't be read",
+	}, []string{"error"})
+	m.lines = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "loki_source_http_pull_lines_total",
+		Help: "Total number of successful lines read from the endpoint",
+	})
+
+	if reg != nil {
+		reg.MustRegister(m.requests, m.errors, m.lines)
+	}
+	return &m
+}
+
+// readerConfig configures a reader.
+type readerConfig struct {
+	Logger log.Logger
+	Client *http.Client
+	// URL is the template of the URL to request, executed with the cursor.
+	URL *template.Template
+	// PositionKey is the key the cursor is saved under in the positions file.
+	PositionKey  string
+	PollInterval time.Duration
+	EntriesField fieldPath
+	LineField    fieldPath
+	CursorField  fieldPath
+	Labels       model.LabelSet
+}
+
+// reader polls an HTTP endpoint for entries and sends them to a handler,
+// paging through the responses with a cursor.
+type reader struct {
+	cfg       readerConfig
+	positions positions.Positions
+	metrics   *metrics
+	handler   chan<- loki.Entry
+
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	healthMut sync.RWMutex
+	health    component.Health
+}
+
+// newReader creates a reader and starts polling in the background.
+func newReader(cfg readerConfig, positions positions.Positions, metrics *metrics, handler chan<- loki.Entry) *reader {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &reader{
+		cfg:       cfg,
+		positions: positions,
+		metrics:   metrics,
+		handler:   handler,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+	go r.run(ctx)
+	return r
+}
+
+func (r *reader) run(ctx context.Context) {
+	defer close(r.done)
+
+	t := time.NewTimer(0)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+
+		err := r.poll(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			level.Error(r.cfg.Logger).Log("msg", "failed to poll endpoint", "err", err)
+			r.setHealth(component.HealthTypeUnhealthy, fmt.Sprintf("failed to poll endpoint: %s", err))
+		} else {
+			r.setHealth(component.HealthTypeHealthy, "polled endpoint")
+		}
+		t.Reset(r.cfg.PollInterval)
+	}
+}
+
+// poll requests pages of entries until the endpoint returns no new cursor.
+func (r *reader) poll(ctx context.Context) error {
+	for {
+		cursor, _ := r.positions.GetCursor(r.cfg.PositionKey)
+		next, err := r.readPage(ctx, cursor)
+		if err != nil {
+			return err
+		}
+		if next == "" || next == cursor {
+			return nil
+		}
+
+		// The cursor is only saved once every entry of the page was sent, so
+		// that a page which wasn't fully read is requested again.
+		r.positions.PutCursor(r.cfg.PositionKey, next)
+	}
+}
+
+// readPage requests the page of entries for cursor and sends its entries to
+// the handler. It returns the cursor of the next page, which is empty if
+// there's none.
+func (r *reader) readPage(ctx context.Context, cursor string) (string, error) {
+	u, err := executeURL(r.cfg.URL, cursor)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	r.metrics.requests.Inc()
+	resp, err := r.cfg.Client.Do(req)
+	if err != nil {
+		r.metrics.errors.WithLabelValues(requestError).Inc()
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		r.metrics.errors.WithLabelValues(requestError).Inc()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var body any
+	dec := json.NewDecoder(resp.Body)
+	dec.UseNumber()
+	if err := dec.Decode(&body); err != nil {
+		r.metrics.errors.WithLabelValues(invalidResponseError).Inc()
+		return "", fmt.Errorf("invalid response: %w", err)
+	}
+
+	value, ok := r.cfg.EntriesField.lookup(body)
+	entries, isArray := value.([]any)
+	if !ok || !isArray {
+		r.metrics.errors.WithLabelValues(invalidResponseError).Inc()
+		return "", fmt.Errorf("invalid response: %s isn't an array", r.cfg.EntriesField)
+	}
+
+	for _, entry := range entries {
+		value, ok := r.cfg.LineField.lookup(entry)
+		if !ok {
+			level.Debug(r.cfg.Logger).Log("msg", "received entry without a line", "field", r.cfg.LineField)
+			r.metrics.errors.WithLabelValues(noLineError).Inc()
+			continue
+		}
+		line, err := fieldString(value)
+		if err != nil {
+			level.Debug(r.cfg.Logger).Log("msg", "could not read line of entry", "err", err)
+			r.metrics.errors.WithLabelValues(noLineError).Inc()
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case r.handler <- loki.Entry{
+			Labels: r.cfg.Labels.Clone(),
+			Entry: logproto.Entry{
+				Line:      line,
+				Timestamp: time.Now(),
+			},
+		}:
+		}
+		r.metrics.lines.Inc()
+	}
+
+	if r.cfg.CursorField == nil {
+		return "", nil
+	}
+	value, ok = r.cfg.CursorField.lookup(body)
+	if !ok || value == nil {
+		return "", nil
+	}
+	next, err := fieldString(value)
+	if err != nil {
+		r.metrics.errors.WithLabelValues(invalidResponseError).Inc()
+		return "", fmt.Errorf("invalid cursor: %w", err)
+	}
+	return next, nil
+}
+
+func (r *reader) setHealth(ty component.HealthType, msg string) {
+	r.healthMut.Lock()
+	defer r.healthMut.Unlock()
+	r.health = component.Health{
+		Health:     ty,
+		Message:    msg,
+		UpdateTime: time.Now(),
+	}
+}
+
+// CurrentHealth returns the health of the reader.
+func (r *reader) CurrentHealth() component.Health {
+	r.healthMut.RLock()
+	defer r.healthMut.RUnlock()
+	return r.health
+}
+
+// Stop stops the reader and waits for it to exit.
+func (r *reader) Stop() {
+	r.cancel()
+	<-r.done
+}
+
+// fieldPath is a path to a field of a JSON document, written as field names
+// separated by dots, such as "$.data.items". An empty path refers to the
+// whole document.
+type fieldPath []string
+
+// parseFieldPath parses a field path. The leading "$" and "$." are optional.
+func parseFieldPath(s string) (fieldPath, error) {
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "$"), ".")
+	if s == "" {
+		return nil, nil
+	}
+
+	path := strings.Split(s, ".")
+	for _, name := range path {
+		if name == "" {
+			return nil, fmt.Errorf("empty field name in %q", s)
+		}
+	}
+	return path, nil
+}
+
+// lookup returns the value of the field at p in v, and whether it exists.
+func (p fieldPath) lookup(v any) (any, bool) {
+	for _, name := range p {
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if v, ok = obj[name]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+func (p fieldPath) String() string {
+	if len(p) == 0 {
+		return "$"
+	}
+	return "$." + strings.Join(p, ".")
+}
+
+// fieldString returns the string value of a JSON field. Strings and numbers
+// are returned as is, and objects and arrays as JSON.
+func fieldString(v any) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case nil:
+		return "", errors.New("field is null")
+	default:
+		bb, err := json.Marshal(v)
+		return string(bb), err
+	}
+}
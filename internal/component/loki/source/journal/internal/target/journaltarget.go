@@ -7,6 +7,7 @@ package target
 // to other loki components.
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"strings"
@@ -19,11 +20,13 @@ import (
 	"github.com/coreos/go-systemd/sdjournal"
 	"github.com/go-kit/log"
 	"github.com/grafana/agent/internal/flow/logging/level"
+	"github.com/grafana/dskit/backoff"
 	jsoniter "github.com/json-iterator/go"
 	"github.com/pkg/errors"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/model/relabel"
+	"go.uber.org/atomic"
 
 	"github.com/grafana/loki/clients/pkg/promtail/scrapeconfig"
 	"github.com/grafana/loki/clients/pkg/promtail/targets/target"
@@ -44,6 +47,13 @@ const (
 	journalDefaultMaxAgeTime = time.Hour * 7
 )
 
+// reconnectBackoff is the backoff used when reopening the journal after it
+// became unavailable.
+var reconnectBackoff = backoff.Config{
+	MinBackoff: time.Second,
+	MaxBackoff: time.Minute,
+}
+
 const (
 	noMessageError   = "no_message"
 	emptyLabelsError = "empty_labels"
@@ -112,8 +122,15 @@ type JournalTarget struct {
 	config        *scrapeconfig.JournalTargetConfig
 	labels        model.LabelSet
 
-	r     journalReader
-	until chan time.Time
+	readerFunc journalReaderFunc
+	cb         journalConfigBuilder
+
+	r      journalReader
+	ready  atomic.Bool
+	until  chan time.Time
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
 }
 
 // NewJournalTarget configures a new JournalTarget.
@@ -162,7 +179,7 @@ func journalTargetWithReader(
 		entryFunc = defaultJournalEntryFunc
 	}
 
-	until := make(chan time.Time)
+	ctx, cancel := context.WithCancel(context.Background())
 	t := &JournalTarget{
 		metrics:       metrics,
 		logger:        logger,
@@ -172,8 +189,12 @@ func journalTargetWithReader(
 		relabelConfig: relabelConfig,
 		labels:        targetConfig.Labels,
 		config:        targetConfig,
+		readerFunc:    readerFunc,
 
-		until: until,
+		until:  make(chan time.Time),
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
 	}
 
 	var maxAge time.Duration
@@ -184,6 +205,7 @@ func journalTargetWithReader(
 		maxAge, err = time.ParseDuration(targetConfig.MaxAge)
 	}
 	if err != nil {
+		cancel()
 		return nil, errors.Wrap(err, "parsing journal reader 'max_age' config value")
 	}
 
@@ -198,6 +220,7 @@ func journalTargetWithReader(
 	for _, m := range matches {
 		fv := strings.Split(m, "=")
 		if len(fv) != 2 {
+			cancel()
 			return nil, errors.New("Error parsing journal reader 'matches' config value")
 		}
 		cb.Matches = append(cb.Matches, sdjournal.Match{
@@ -206,30 +229,80 @@ func journalTargetWithReader(
 		})
 	}
 
+	t.cb = cb
 	cfg := t.generateJournalConfig(cb)
 	t.r, err = readerFunc(cfg)
 	if err != nil {
+		cancel()
 		return nil, errors.Wrap(err, "creating journal reader")
 	}
+	t.ready.Store(true)
+
+	go t.run()
+
+	return t, nil
+}
+
+// run follows the journal until the target is stopped. If the journal can't
+// be followed anymore, it is reopened.
+func (t *JournalTarget) run() {
+	defer close(t.done)
+
+	for {
+		err := t.r.Follow(t.until, io.Discard)
+		if t.ctx.Err() != nil {
+			// The target was stopped.
+			return
+		}
 
-	go func() {
-		for {
-			err := t.r.Follow(until, io.Discard)
-			if err != nil {
-				if err == sdjournal.ErrExpired || err == syscall.EBADMSG || err == io.EOF || strings.HasPrefix(err.Error(), "failed to iterate journal:") {
-					level.Error(t.logger).Log("msg", "unable to follow journal", "err", err.Error())
+		if err != nil {
+			if err == sdjournal.ErrExpired || err == syscall.EBADMSG || err == io.EOF || strings.HasPrefix(err.Error(), "failed to iterate journal:") {
+				level.Error(t.logger).Log("msg", "unable to follow journal, reopening it", "err", err.Error())
+				if !t.reconnect() {
 					return
 				}
-
-				level.Error(t.logger).Log("msg", "received unexpected error while following the journal", "err", err.Error())
+				continue
 			}
 
-			// prevent tight loop
-			time.Sleep(100 * time.Millisecond)
+			level.Error(t.logger).Log("msg", "received unexpected error while following the journal", "err", err.Error())
 		}
-	}()
 
-	return t, nil
+		// prevent tight loop
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// reconnect closes the current journal reader and opens a new one, retrying
+// with a backoff until it succeeds. Reading resumes from the last saved
+// position. reconnect returns false if the target was stopped before the
+// journal could be reopened, in which case t.r is left nil.
+func (t *JournalTarget) reconnect() bool {
+	t.ready.Store(false)
+	if err := t.r.Close(); err != nil {
+		level.Warn(t.logger).Log("msg", "failed to close journal reader", "err", err)
+	}
+	// The reader must not be closed twice, which would free the underlying
+	// journal handle twice.
+	t.r = nil
+
+	bo := backoff.New(t.ctx, reconnectBackoff)
+	for bo.Ongoing() {
+		t.metrics.journalReconnects.Inc()
+
+		cb := t.cb
+		cb.Position = t.positions.GetString(t.positionPath, "")
+		r, err := t.readerFunc(t.generateJournalConfig(cb))
+		if err == nil {
+			t.r = r
+			t.ready.Store(true)
+			level.Info(t.logger).Log("msg", "reopened journal")
+			return true
+		}
+
+		level.Error(t.logger).Log("msg", "failed to reopen journal", "err", err, "retries", bo.NumRetries())
+		bo.Wait()
+	}
+	return false
 }
 
 type journalConfigBuilder struct {
@@ -354,9 +427,9 @@ func (t *JournalTarget) Type() target.TargetType {
 }
 
 // Ready indicates whether or not the journal is ready to be
-// read from.
+// read from. It returns false while the journal is being reopened.
 func (t *JournalTarget) Ready() bool {
-	return true
+	return t.ready.Load()
 }
 
 // DiscoveredLabels returns the set of labels discovered by
@@ -381,8 +454,15 @@ func (t *JournalTarget) Details() interface{} {
 
 // Stop shuts down the JournalTarget.
 func (t *JournalTarget) Stop() error {
-	t.until <- time.Now()
-	err := t.r.Close()
+	t.cancel()
+	close(t.until)
+	<-t.done
+
+	t.ready.Store(false)
+	var err error
+	if t.r != nil {
+		err = t.r.Close()
+	}
 	t.handler.Stop()
 	return err
 }
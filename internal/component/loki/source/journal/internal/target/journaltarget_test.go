@@ -7,6 +7,7 @@ package target
 // to other loki components.
 
 import (
+	"errors"
 	"io"
 	"os"
 	"path/filepath"
@@ -19,11 +20,13 @@ import (
 	"github.com/coreos/go-systemd/sdjournal"
 	"github.com/go-kit/log"
 	"github.com/grafana/agent/internal/component/common/loki/positions"
+	"github.com/grafana/dskit/backoff"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/prometheus/prometheus/model/relabel"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
 	"gopkg.in/yaml.v2"
 
 	"github.com/grafana/loki/clients/pkg/promtail/scrapeconfig"
@@ -126,7 +129,7 @@ func TestJournalTarget(t *testing.T) {
 	`
 
 	if err := testutil.GatherAndCompare(registry,
-		strings.NewReader(expectedMetrics)); err != nil {
+		strings.NewReader(expectedMetrics), "loki_source_journal_target_lines_total"); err != nil {
 		t.Fatalf("mismatch metrics: %v", err)
 	}
 	assert.Len(t, client.Received(), 10)
@@ -193,7 +196,7 @@ func TestJournalTargetParsingErrors(t *testing.T) {
 	`
 
 	if err := testutil.GatherAndCompare(registry,
-		strings.NewReader(expectedMetrics)); err != nil {
+		strings.NewReader(expectedMetrics), "loki_source_journal_target_lines_total", "loki_source_journal_target_parsing_errors_total"); err != nil {
 		t.Fatalf("mismatch metrics: %v", err)
 	}
 
@@ -425,3 +428,130 @@ func TestJournalTarget_Matches(t *testing.T) {
 	require.Equal(t, r.config.Matches, matches)
 	client.Stop()
 }
+
+// unavailableJournalReader fails to follow the journal, as if it became
+// unavailable.
+type unavailableJournalReader struct {
+	closes atomic.Int32
+}
+
+func (r *unavailableJournalReader) Close() error {
+	if r.closes.Inc() > 1 {
+		return errors.New("journal reader closed twice")
+	}
+	return nil
+}
+
+func (r *unavailableJournalReader) Follow(until <-chan time.Time, writer io.Writer) error {
+	return io.EOF
+}
+
+func TestJournalTargetReconnect(t *testing.T) {
+	defer func(cfg backoff.Config) { reconnectBackoff = cfg }(reconnectBackoff)
+	reconnectBackoff = backoff.Config{
+		MinBackoff: 10 * time.Millisecond,
+		MaxBackoff: 10 * time.Millisecond,
+	}
+
+	logger := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	ps, err := positions.New(logger, positions.Config{
+		SyncPeriod:    10 * time.Second,
+		PositionsFile: filepath.Join(t.TempDir(), "positions.yml"),
+	})
+	require.NoError(t, err)
+	defer ps.Stop()
+	ps.PutString(positions.CursorKey("test"), "", "saved-cursor")
+
+	// The journal becomes unavailable right after it is opened, and can only
+	// be reopened on the third attempt.
+	var (
+		attempts int
+		reopened = make(chan sdjournal.JournalReaderConfig, 1)
+	)
+	readerFunc := func(c sdjournal.JournalReaderConfig) (journalReader, error) {
+		attempts++
+		switch {
+		case attempts == 1:
+			return &unavailableJournalReader{}, nil
+		case attempts < 4:
+			return nil, errors.New("journal unavailable")
+		default:
+			reopened <- c
+			return &mockJournalReader{config: c}, nil
+		}
+	}
+
+	client := fake.NewClient(func() {})
+	defer client.Stop()
+
+	registry := prometheus.NewRegistry()
+	entry := &sdjournal.JournalEntry{RealtimeTimestamp: uint64(time.Now().UnixMicro())}
+	jt, err := journalTargetWithReader(NewMetrics(registry), logger, client, ps, "test", nil,
+		&scrapeconfig.JournalTargetConfig{}, readerFunc, newMockJournalEntry(entry))
+	require.NoError(t, err)
+
+	select {
+	case c := <-reopened:
+		// Reading resumes from the saved position.
+		require.Equal(t, "saved-cursor", c.Cursor)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "journal was never reopened")
+	}
+
+	require.Eventually(t, jt.Ready, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, jt.Stop())
+	require.False(t, jt.Ready())
+
+	expectedMetrics := `# HELP loki_source_journal_target_reconnects_total Total number of attempts to reopen the journal after it became unavailable
+	# TYPE loki_source_journal_target_reconnects_total counter
+	loki_source_journal_target_reconnects_total 3
+	`
+	require.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expectedMetrics), "loki_source_journal_target_reconnects_total"))
+}
+
+func TestJournalTargetStopDuringReconnect(t *testing.T) {
+	defer func(cfg backoff.Config) { reconnectBackoff = cfg }(reconnectBackoff)
+	reconnectBackoff = backoff.Config{
+		MinBackoff: 10 * time.Millisecond,
+		MaxBackoff: 10 * time.Millisecond,
+	}
+
+	logger := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	ps, err := positions.New(logger, positions.Config{
+		SyncPeriod:    10 * time.Second,
+		PositionsFile: filepath.Join(t.TempDir(), "positions.yml"),
+	})
+	require.NoError(t, err)
+	defer ps.Stop()
+
+	// The journal becomes unavailable right after it is opened, and can't be
+	// reopened.
+	var (
+		unavailable = &unavailableJournalReader{}
+		attempts    atomic.Int32
+	)
+	readerFunc := func(c sdjournal.JournalReaderConfig) (journalReader, error) {
+		if attempts.Inc() == 1 {
+			return unavailable, nil
+		}
+		return nil, errors.New("journal unavailable")
+	}
+
+	client := fake.NewClient(func() {})
+	defer client.Stop()
+
+	entry := &sdjournal.JournalEntry{RealtimeTimestamp: uint64(time.Now().UnixMicro())}
+	jt, err := journalTargetWithReader(NewMetrics(prometheus.NewRegistry()), logger, client, ps, "test", nil,
+		&scrapeconfig.JournalTargetConfig{}, readerFunc, newMockJournalEntry(entry))
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		return attempts.Load() > 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// The reader closed before reconnecting isn't closed again.
+	require.NoError(t, jt.Stop())
+	require.Equal(t, int32(1), unavailable.closes.Load())
+}
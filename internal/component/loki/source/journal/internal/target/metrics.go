@@ -12,6 +12,8 @@ type Metrics struct {
 
 	journalErrors *prometheus.CounterVec
 	journalLines  prometheus.Counter
+
+	journalReconnects prometheus.Counter
 }
 
 // NewMetrics creates a new set of journal target metrics. If reg is non-nil, the
@@ -29,10 +31,16 @@ func NewMetrics(reg prometheus.Registerer) *Metrics {
 		Help: "Total number of successful journal lines read",
 	})
 
+	m.journalReconnects = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "loki_source_journal_target_reconnects_total",
+		Help: "Total number of attempts to reopen the journal after it became unavailable",
+	})
+
 	if reg != nil {
 		reg.MustRegister(
 			m.journalErrors,
 			m.journalLines,
+			m.journalReconnects,
 		)
 	}
 
@@ -137,6 +137,6 @@ func convertArgs(job string, a Arguments) *scrapeconfig.JournalTargetConfig {
 		JSON:    a.FormatAsJson,
 		Labels:  labels,
 		Path:    a.Path,
-		Matches: a.Matches,
+		Matches: a.journalMatches(),
 	}
 }
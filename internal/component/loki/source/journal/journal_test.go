@@ -55,3 +55,75 @@ func TestJournal(t *testing.T) {
 	}
 	require.True(t, found)
 }
+
+func TestJournalFilters(t *testing.T) {
+	tmp := t.TempDir()
+	lr := loki.NewLogsReceiver()
+	c, err := New(component.Options{
+		ID:         "loki.source.journal.test",
+		Logger:     util.TestFlowLogger(t),
+		DataPath:   tmp,
+		Registerer: prometheus.NewRegistry(),
+	}, Arguments{
+		MaxAge:      7 * time.Hour,
+		MaxPriority: "err",
+		Receivers:   []loki.LogsReceiver{lr},
+	})
+	require.NoError(t, err)
+	ctx, cnc := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cnc()
+	go c.Run(ctx)
+
+	ts := time.Now().String()
+	require.NoError(t, journal.Send("info "+ts, journal.PriInfo, nil))
+	require.NoError(t, journal.Send("err "+ts, journal.PriErr, nil))
+
+	// Only the entry with a priority up to max_priority is forwarded.
+	for {
+		select {
+		case <-ctx.Done():
+			require.FailNow(t, "timed out waiting for entry")
+		case msg := <-lr.Chan():
+			require.NotContains(t, msg.Line, "info "+ts)
+			if strings.Contains(msg.Line, "err "+ts) {
+				return
+			}
+		}
+	}
+}
+
+func TestJournalMatches(t *testing.T) {
+	tt := []struct {
+		name   string
+		args   Arguments
+		expect string
+	}{
+		{name: "matches only", args: Arguments{Matches: "FOO=bar"}, expect: "FOO=bar"},
+		{
+			name:   "units",
+			args:   Arguments{Matches: "FOO=bar", Units: []string{"a.service", "b.service"}},
+			expect: "FOO=bar _SYSTEMD_UNIT=a.service _SYSTEMD_UNIT=b.service",
+		},
+		{
+			name:   "priority keyword",
+			args:   Arguments{MaxPriority: "crit"},
+			expect: "PRIORITY=0 PRIORITY=1 PRIORITY=2",
+		},
+		{
+			name:   "priority number",
+			args:   Arguments{Units: []string{"a.service"}, MaxPriority: "1"},
+			expect: "_SYSTEMD_UNIT=a.service PRIORITY=0 PRIORITY=1",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			require.NoError(t, tc.args.Validate())
+			require.Equal(t, tc.expect, convertArgs("job", tc.args).Matches)
+		})
+	}
+
+	require.EqualError(t, (&Arguments{MaxPriority: "loud"}).Validate(), `invalid max_priority "loud", expected a number from 0 to 7 or one of emerg,alert,crit,err,warning,notice,info,debug`)
+	require.EqualError(t, (&Arguments{MaxPriority: "8"}).Validate(), `invalid max_priority "8", expected a number from 0 to 7 or one of emerg,alert,crit,err,warning,notice,info,debug`)
+	require.EqualError(t, (&Arguments{Units: []string{"a b.service"}}).Validate(), `invalid unit "a b.service"`)
+}
@@ -1,6 +1,9 @@
 package journal
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/grafana/agent/internal/component/common/loki"
@@ -14,6 +17,8 @@ type Arguments struct {
 	Path         string              `river:"path,attr,optional"`
 	RelabelRules flow_relabel.Rules  `river:"relabel_rules,attr,optional"`
 	Matches      string              `river:"matches,attr,optional"`
+	Units        []string            `river:"units,attr,optional"`
+	MaxPriority  string              `river:"max_priority,attr,optional"`
 	Receivers    []loki.LogsReceiver `river:"forward_to,attr"`
 	Labels       map[string]string   `river:"labels,attr,optional"`
 }
@@ -30,3 +35,54 @@ func defaultArgs() Arguments {
 func (r *Arguments) SetToDefault() {
 	*r = defaultArgs()
 }
+
+// Validate implements river.Validator.
+func (r *Arguments) Validate() error {
+	for _, unit := range r.Units {
+		if unit == "" || strings.ContainsAny(unit, "= \t\n") {
+			return fmt.Errorf("invalid unit %q", unit)
+		}
+	}
+	if _, err := parsePriority(r.MaxPriority); err != nil {
+		return err
+	}
+	return nil
+}
+
+// priorityKeywords are the syslog priority keywords, indexed by priority.
+var priorityKeywords = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+// parsePriority parses a syslog priority given either as a keyword or as a
+// number from 0 to 7. -1 is returned if priority is empty.
+func parsePriority(priority string) (int, error) {
+	if priority == "" {
+		return -1, nil
+	}
+	for i, keyword := range priorityKeywords {
+		if priority == keyword {
+			return i, nil
+		}
+	}
+	if i, err := strconv.Atoi(priority); err == nil && i >= 0 && i < len(priorityKeywords) {
+		return i, nil
+	}
+	return 0, fmt.Errorf("invalid max_priority %q, expected a number from 0 to 7 or one of %s", priority, strings.Join(priorityKeywords, ","))
+}
+
+// journalMatches returns the journal matches for the arguments, combining
+// matches with the units and max_priority filters. The journal ORs matches of
+// the same field and ANDs matches of different fields, so entries from any
+// of the units with any priority up to max_priority are read.
+func (r *Arguments) journalMatches() string {
+	matches := strings.Fields(r.Matches)
+	for _, unit := range r.Units {
+		matches = append(matches, "_SYSTEMD_UNIT="+unit)
+	}
+
+	// Validate ensures that the priority is valid.
+	maxPriority, _ := parsePriority(r.MaxPriority)
+	for i := 0; i <= maxPriority; i++ {
+		matches = append(matches, "PRIORITY="+strconv.Itoa(i))
+	}
+	return strings.Join(matches, " ")
+}
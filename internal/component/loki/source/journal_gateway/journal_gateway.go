@@ -0,0 +1,223 @@
+// Package journal_gateway implements the loki.source.journal_gateway
+// component.
+package journal_gateway //nolint:golint
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/agent/internal/component"
+	component_config "github.com/grafana/agent/internal/component/common/config"
+	"github.com/grafana/agent/internal/component/common/loki"
+	"github.com/grafana/agent/internal/component/common/loki/positions"
+	flow_relabel "github.com/grafana/agent/internal/component/common/relabel"
+	"github.com/grafana/agent/internal/featuregate"
+	prom_config "github.com/prometheus/common/config"
+	"github.com/prometheus/common/model"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:      "loki.source.journal_gateway",
+		Stability: featuregate.StabilityBeta,
+		Args:      Arguments{},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return New(opts, args.(Arguments))
+		},
+	})
+}
+
+// Arguments holds values which are used to configure the
+// loki.source.journal_gateway component.
+type Arguments struct {
+	URL          string              `river:"url,attr"`
+	FormatAsJson bool                `river:"format_as_json,attr,optional"`
+	MaxAge       time.Duration       `river:"max_age,attr,optional"`
+	Matches      string              `river:"matches,attr,optional"`
+	RelabelRules flow_relabel.Rules  `river:"relabel_rules,attr,optional"`
+	Receivers    []loki.LogsReceiver `river:"forward_to,attr"`
+	Labels       map[string]string   `river:"labels,attr,optional"`
+
+	HTTPClientConfig component_config.HTTPClientConfig `river:",squash"`
+}
+
+// SetToDefault implements river.Defaulter.
+func (args *Arguments) SetToDefault() {
+	*args = Arguments{
+		MaxAge:           7 * time.Hour,
+		HTTPClientConfig: component_config.DefaultHTTPClientConfig,
+	}
+}
+
+// Validate implements river.Validator.
+func (args *Arguments) Validate() error {
+	u, err := url.Parse(args.URL)
+	if err != nil {
+		return fmt.Errorf("invalid url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid url %q: scheme must be http or https", args.URL)
+	}
+	for _, match := range strings.Fields(args.Matches) {
+		if field, _, ok := strings.Cut(match, "="); !ok || field == "" {
+			return fmt.Errorf("invalid match %q, expected FIELD=value", match)
+		}
+	}
+
+	// We must explicitly Validate because HTTPClientConfig is squashed and it won't run otherwise
+	return args.HTTPClientConfig.Validate()
+}
+
+// entriesURL returns the URL of the entries endpoint of the journal gateway,
+// following the journal and filtered by the matches of the arguments.
+func (args *Arguments) entriesURL() (string, error) {
+	u, err := url.Parse(args.URL)
+	if err != nil {
+		return "", err
+	}
+	u = u.JoinPath("entries")
+
+	// The journal gateway expects the follow argument without a value, which
+	// url.Values can't express.
+	query := []string{"follow"}
+	for _, match := range strings.Fields(args.Matches) {
+		field, value, _ := strings.Cut(match, "=")
+		query = append(query, url.QueryEscape(field)+"="+url.QueryEscape(value))
+	}
+	u.RawQuery = strings.Join(query, "&")
+	return u.String(), nil
+}
+
+var (
+	_ component.Component       = (*Component)(nil)
+	_ component.HealthComponent = (*Component)(nil)
+)
+
+// Component implements the loki.source.journal_gateway component.
+type Component struct {
+	opts      component.Options
+	metrics   *metrics
+	handler   chan loki.Entry
+	positions positions.Positions
+
+	mut       sync.RWMutex
+	r         *reader
+	receivers []loki.LogsReceiver
+}
+
+// New creates a new loki.source.journal_gateway component.
+func New(o component.Options, args Arguments) (*Component, error) {
+	err := os.MkdirAll(o.DataPath, 0750)
+	if err != nil {
+		return nil, err
+	}
+
+	positionsFile, err := positions.New(o.Logger, positions.Config{
+		SyncPeriod:        10 * time.Second,
+		PositionsFile:     filepath.Join(o.DataPath, "positions.yml"),
+		IgnoreInvalidYaml: false,
+		ReadOnly:          false,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Component{
+		opts:      o,
+		metrics:   newMetrics(o.Registerer),
+		handler:   make(chan loki.Entry),
+		positions: positionsFile,
+	}
+	if err := c.Update(args); err != nil {
+		positionsFile.Stop()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Run implements component.Component.
+func (c *Component) Run(ctx context.Context) error {
+	defer func() {
+		c.mut.Lock()
+		if c.r != nil {
+			c.r.Stop()
+		}
+		c.mut.Unlock()
+		c.positions.Stop()
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case entry := <-c.handler:
+			c.mut.RLock()
+			for _, receiver := range c.receivers {
+				receiver.Chan() <- entry
+			}
+			c.mut.RUnlock()
+		}
+	}
+}
+
+// Update implements component.Component.
+func (c *Component) Update(args component.Arguments) error {
+	newArgs := args.(Arguments)
+
+	entriesURL, err := newArgs.entriesURL()
+	if err != nil {
+		return err
+	}
+	client, err := prom_config.NewClientFromConfig(*newArgs.HTTPClientConfig.Convert(), c.opts.ID)
+	if err != nil {
+		return err
+	}
+
+	labels := model.LabelSet{
+		model.LabelName("job"): model.LabelValue(c.opts.ID),
+	}
+	for k, v := range newArgs.Labels {
+		labels[model.LabelName(k)] = model.LabelValue(v)
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	// Stop the previous reader before starting a new one so that both don't
+	// write the cursor at the same time.
+	if c.r != nil {
+		c.r.Stop()
+	}
+
+	c.receivers = newArgs.Receivers
+	c.r = newReader(readerConfig{
+		Logger:        c.opts.Logger,
+		Client:        client,
+		URL:           entriesURL,
+		PositionKey:   newArgs.URL,
+		MaxAge:        newArgs.MaxAge,
+		JSON:          newArgs.FormatAsJson,
+		Labels:        labels,
+		RelabelConfig: flow_relabel.ComponentToPromRelabelConfigs(newArgs.RelabelRules),
+	}, c.positions, c.metrics, c.handler)
+	return nil
+}
+
+// CurrentHealth implements component.HealthComponent. The component is
+// reported as unhealthy while it can't stream entries from the journal
+// gateway.
+func (c *Component) CurrentHealth() component.Health {
+	c.mut.RLock()
+	defer c.mut.RUnlock()
+	if c.r == nil {
+		return component.Health{}
+	}
+	return c.r.CurrentHealth()
+}
@@ -0,0 +1,244 @@
+package journal_gateway //nolint:golint
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/internal/component"
+	"github.com/grafana/agent/internal/component/common/loki"
+	flow_relabel "github.com/grafana/agent/internal/component/common/relabel"
+	"github.com/grafana/agent/internal/util"
+	"github.com/grafana/river"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+)
+
+// stubGateway serves journal entries like systemd-journal-gatewayd, keeping
+// the connection open after sending them to simulate following the journal.
+type stubGateway struct {
+	entries []map[string]any
+
+	mut      sync.Mutex
+	requests []*http.Request
+}
+
+func (g *stubGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.mut.Lock()
+	g.requests = append(g.requests, r)
+	g.mut.Unlock()
+
+	if r.URL.Path != "/entries" || r.Header.Get("Accept") != "application/json" {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	// Skip the entries up to and including the cursor from the Range header.
+	entries := g.entries
+	if rng := r.Header.Get("Range"); rng != "" {
+		for i, entry := range entries {
+			if rng == fmt.Sprintf("entries=%s:1:", entry["__CURSOR"]) {
+				entries = entries[i+1:]
+				break
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	for _, entry := range entries {
+		bb, _ := json.Marshal(entry)
+		_, _ = w.Write(append(bb, '\n'))
+	}
+	w.(http.Flusher).Flush()
+	<-r.Context().Done()
+}
+
+func (g *stubGateway) Requests() []*http.Request {
+	g.mut.Lock()
+	defer g.mut.Unlock()
+	return append([]*http.Request(nil), g.requests...)
+}
+
+func gatewayEntry(cursor, message string, ts time.Time) map[string]any {
+	return map[string]any{
+		"__CURSOR":             cursor,
+		"__REALTIME_TIMESTAMP": fmt.Sprint(ts.UnixMicro()),
+		"_SYSTEMD_UNIT":        "test.service",
+		"PRIORITY":             "6",
+		"MESSAGE":              message,
+	}
+}
+
+func TestJournalGateway(t *testing.T) {
+	now := time.Now().Truncate(time.Microsecond)
+	gateway := &stubGateway{
+		entries: []map[string]any{
+			gatewayEntry("c0", "too old", now.Add(-8*time.Hour)),
+			gatewayEntry("c1", "first", now.Add(-time.Minute)),
+			gatewayEntry("c2", "second", now),
+		},
+	}
+	srv := httptest.NewServer(gateway)
+	defer srv.Close()
+
+	dataPath := t.TempDir()
+	receiver := loki.NewLogsReceiver()
+
+	var args Arguments
+	args.SetToDefault()
+	args.URL = srv.URL
+	args.Matches = "_SYSTEMD_UNIT=test.service"
+	args.Receivers = []loki.LogsReceiver{receiver}
+	args.Labels = map[string]string{"source": "gateway"}
+	rule := flow_relabel.DefaultRelabelConfig
+	rule.SourceLabels = []string{"__journal__systemd_unit"}
+	rule.TargetLabel = "unit"
+	args.RelabelRules = flow_relabel.Rules{&rule}
+
+	// The first run starts without a cursor, so entries older than max_age are
+	// skipped.
+	runComponent(t, dataPath, args, func() {
+		for _, msg := range []string{"first", "second"} {
+			select {
+			case entry := <-receiver.Chan():
+				require.Equal(t, msg, entry.Line)
+				require.Equal(t, model.LabelSet{
+					"job":    "loki.source.journal_gateway.test",
+					"source": "gateway",
+					"unit":   "test.service",
+				}, entry.Labels)
+			case <-time.After(5 * time.Second):
+				require.FailNow(t, "failed waiting for log line")
+			}
+		}
+	})
+
+	requests := gateway.Requests()
+	require.Len(t, requests, 1)
+	require.Equal(t, "follow&_SYSTEMD_UNIT=test.service", requests[0].URL.RawQuery)
+	require.Empty(t, requests[0].Header.Get("Range"))
+
+	// The second run resumes after the saved cursor.
+	gateway.entries = append(gateway.entries, gatewayEntry("c3", "third", now))
+	runComponent(t, dataPath, args, func() {
+		select {
+		case entry := <-receiver.Chan():
+			require.Equal(t, "third", entry.Line)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "failed waiting for log line")
+		}
+	})
+
+	requests = gateway.Requests()
+	require.Len(t, requests, 2)
+	require.Equal(t, "entries=c2:1:", requests[1].Header.Get("Range"))
+}
+
+func TestJournalGatewayUnhealthy(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	var args Arguments
+	args.SetToDefault()
+	args.URL = srv.URL
+	args.Receivers = []loki.LogsReceiver{loki.NewLogsReceiver()}
+
+	opts := testOptions(t, t.TempDir())
+	c, err := New(opts, args)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Run(ctx)
+
+	require.Eventually(t, func() bool {
+		return c.CurrentHealth().Health == component.HealthTypeUnhealthy
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Contains(t, c.CurrentHealth().Message, "unexpected status code 404")
+}
+
+func TestArguments(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         string
+		expectedErr string
+	}{
+		{
+			name: "valid",
+			cfg: `
+				url        = "https://gateway:19531"
+				matches    = "_SYSTEMD_UNIT=test.service PRIORITY=3"
+				forward_to = []
+				tls_config {
+					cert_file = "/etc/agent/client.crt"
+					key_file  = "/etc/agent/client.key"
+				}
+			`,
+		},
+		{
+			name: "invalid scheme",
+			cfg: `
+				url        = "tcp://gateway:19531"
+				forward_to = []
+			`,
+			expectedErr: `invalid url "tcp://gateway:19531": scheme must be http or https`,
+		},
+		{
+			name: "invalid match",
+			cfg: `
+				url        = "http://gateway:19531"
+				matches    = "_SYSTEMD_UNIT"
+				forward_to = []
+			`,
+			expectedErr: `invalid match "_SYSTEMD_UNIT", expected FIELD=value`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var args Arguments
+			err := river.Unmarshal([]byte(tc.cfg), &args)
+			if tc.expectedErr == "" {
+				require.NoError(t, err)
+				require.Equal(t, 7*time.Hour, args.MaxAge)
+				return
+			}
+			require.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
+
+// runComponent runs the component until check returns, then waits for it to
+// exit so that the positions file is written.
+func runComponent(t *testing.T, dataPath string, args Arguments, check func()) {
+	t.Helper()
+
+	c, err := New(testOptions(t, dataPath), args)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		require.NoError(t, c.Run(ctx))
+	}()
+
+	check()
+	cancel()
+	<-done
+}
+
+func testOptions(t *testing.T, dataPath string) component.Options {
+	return component.Options{
+		ID:         "loki.source.journal_gateway.test",
+		Logger:     util.TestFlowLogger(t),
+		Registerer: prometheus.NewRegistry(),
+		DataPath:   dataPath,
+	}
+}
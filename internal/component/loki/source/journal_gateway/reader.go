@@ -0,0 +1,356 @@
+package journal_gateway //nolint:golint
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/agent/internal/component"
+	"github.com/grafana/agent/internal/component/common/loki"
+	"github.com/grafana/agent/internal/component/common/loki/positions"
+	"github.com/grafana/agent/internal/flow/logging/level"
+	"github.com/grafana/dskit/backoff"
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/relabel"
+)
+
+// reconnectBackoff is the backoff used when reconnecting to the journal
+// gateway after the connection failed.
+var reconnectBackoff = backoff.Config{
+	MinBackoff: time.Second,
+	MaxBackoff: time.Minute,
+}
+
+const (
+	noMessageError   = "no_message"
+	emptyLabelsError = "empty_labels"
+	invalidJSONError = "invalid_json"
+)
+
+type metrics struct {
+	errors     *prometheus.CounterVec
+	lines      prometheus.Counter
+	reconnects prometheus.Counter
+}
+
+func newMetrics(reg prometheus.Registerer) *metrics {
+	var m metrics
+
+	m.errors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "loki_source_journal_gateway_parsing_errors_total",
+		Help: "Total number of parsing errors while reading journal gateway entries",
+	}, []string{"error"})
+	m.lines = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "loki_source_journal_gateway_lines_total",
+		Help: "Total number of successful journal gateway lines read",
+	})
+	m.reconnects = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "loki_source_journal_gateway_reconnects_total",
+		Help: "Total number of attempts to reconnect to the journal gateway",
+	})
+
+	if reg != nil {
+		reg.MustRegister(m.errors, m.lines, m.reconnects)
+	}
+	return &m
+}
+
+// readerConfig configures a reader.
+type readerConfig struct {
+	Logger log.Logger
+	Client *http.Client
+	// URL is the entries URL of the journal gateway, including the query.
+	URL string
+	// PositionKey is the key the cursor is saved under in the positions file.
+	PositionKey   string
+	MaxAge        time.Duration
+	JSON          bool
+	Labels        model.LabelSet
+	RelabelConfig []*relabel.Config
+}
+
+// reader streams entries from a journal gateway and sends them to a handler,
+// reconnecting whenever the stream fails.
+type reader struct {
+	cfg       readerConfig
+	positions positions.Positions
+	metrics   *metrics
+	handler   chan<- loki.Entry
+
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	healthMut sync.RWMutex
+	health    component.Health
+}
+
+// newReader creates a reader and starts streaming entries in the background.
+func newReader(cfg readerConfig, positions positions.Positions, metrics *metrics, handler chan<- loki.Entry) *reader {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &reader{
+		cfg:       cfg,
+		positions: positions,
+		metrics:   metrics,
+		handler:   handler,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+	go r.run(ctx)
+	return r
+}
+
+func (r *reader) run(ctx context.Context) {
+	defer close(r.done)
+
+	bo := backoff.New(ctx, reconnectBackoff)
+	for bo.Ongoing() {
+		if bo.NumRetries() > 0 {
+			r.metrics.reconnects.Inc()
+		}
+
+		read, err := r.follow(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if read {
+			bo.Reset()
+		}
+		level.Error(r.cfg.Logger).Log("msg", "failed to read from journal gateway", "url", r.cfg.URL, "err", err, "retries", bo.NumRetries())
+		r.setHealth(component.HealthTypeUnhealthy, fmt.Sprintf("failed to read from journal gateway: %s", err))
+		bo.Wait()
+	}
+}
+
+// follow streams entries until the connection fails or ctx is canceled. It
+// reports whether any entry was read.
+func (r *reader) follow(ctx context.Context) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.cfg.URL, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	// Resume after the saved cursor, skipping the entry it points to. Without
+	// a cursor the gateway sends the whole journal, and entries older than
+	// MaxAge are skipped while reading.
+	cursor, resuming := r.positions.GetCursor(r.cfg.PositionKey)
+	if resuming {
+		req.Header.Set("Range", "entries="+cursor+":1:")
+	}
+
+	resp, err := r.cfg.Client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return false, fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	r.setHealth(component.HealthTypeHealthy, "reading from journal gateway")
+
+	var (
+		minTime = time.Now().Add(-r.cfg.MaxAge)
+		dec     = json.NewDecoder(resp.Body)
+		read    bool
+	)
+	for {
+		var fields map[string]any
+		if err := dec.Decode(&fields); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = errors.New("journal gateway closed the connection")
+			}
+			var syntaxErr *json.SyntaxError
+			if errors.As(err, &syntaxErr) {
+				r.metrics.errors.WithLabelValues(invalidJSONError).Inc()
+			}
+			return read, err
+		}
+		read = true
+
+		entry := makeEntryFields(fields)
+		ts := parseTimestamp(entry["__REALTIME_TIMESTAMP"])
+		if !resuming && ts.Before(minTime) {
+			continue
+		}
+		if !r.handleEntry(ctx, entry, ts) {
+			return read, ctx.Err()
+		}
+	}
+}
+
+// handleEntry sends entry to the handler and saves its cursor. It returns
+// false if ctx was canceled before the entry could be sent.
+func (r *reader) handleEntry(ctx context.Context, fields map[string]string, ts time.Time) bool {
+	var msg string
+	if r.cfg.JSON {
+		bb, err := json.Marshal(fields)
+		if err != nil {
+			level.Error(r.cfg.Logger).Log("msg", "could not marshal journal fields to JSON", "err", err, "unit", fields["_SYSTEMD_UNIT"])
+			return true
+		}
+		msg = string(bb)
+	} else {
+		var ok bool
+		msg, ok = fields["MESSAGE"]
+		if !ok {
+			level.Debug(r.cfg.Logger).Log("msg", "received journal entry with no MESSAGE field", "unit", fields["_SYSTEMD_UNIT"])
+			r.metrics.errors.WithLabelValues(noMessageError).Inc()
+			return true
+		}
+	}
+
+	entryLabels := makeJournalLabels(fields)
+	for k, v := range r.cfg.Labels {
+		entryLabels[string(k)] = string(v)
+	}
+
+	processedLabels, _ := relabel.Process(labels.FromMap(entryLabels), r.cfg.RelabelConfig...)
+
+	lbls := make(model.LabelSet, processedLabels.Len())
+	processedLabels.Range(func(l labels.Label) {
+		if strings.HasPrefix(l.Name, "__") {
+			return
+		}
+		lbls[model.LabelName(l.Name)] = model.LabelValue(l.Value)
+	})
+	if len(lbls) == 0 {
+		// No labels, drop journal entry
+		level.Debug(r.cfg.Logger).Log("msg", "received journal entry with no labels", "unit", fields["_SYSTEMD_UNIT"])
+		r.metrics.errors.WithLabelValues(emptyLabelsError).Inc()
+		return true
+	}
+
+	select {
+	case <-ctx.Done():
+		return false
+	case r.handler <- loki.Entry{
+		Labels: lbls,
+		Entry: logproto.Entry{
+			Line:      msg,
+			Timestamp: ts,
+		},
+	}:
+	}
+
+	r.metrics.lines.Inc()
+	if cursor, ok := fields["__CURSOR"]; ok {
+		r.positions.PutCursor(r.cfg.PositionKey, cursor)
+	}
+	return true
+}
+
+func (r *reader) setHealth(ty component.HealthType, msg string) {
+	r.healthMut.Lock()
+	defer r.healthMut.Unlock()
+	r.health = component.Health{
+		Health:     ty,
+		Message:    msg,
+		UpdateTime: time.Now(),
+	}
+}
+
+// CurrentHealth returns the health of the reader.
+func (r *reader) CurrentHealth() component.Health {
+	r.healthMut.RLock()
+	defer r.healthMut.RUnlock()
+	return r.health
+}
+
+// Stop stops the reader and waits for it to exit.
+func (r *reader) Stop() {
+	r.cancel()
+	<-r.done
+}
+
+// makeEntryFields converts the fields of a journal gateway JSON entry to
+// strings. The gateway encodes fields which aren't printable as an array of
+// bytes, and fields with multiple values as an array of values, of which the
+// last one is kept.
+func makeEntryFields(fields map[string]any) map[string]string {
+	result := make(map[string]string, len(fields))
+	for k, v := range fields {
+		if s, ok := fieldString(v); ok {
+			result[k] = s
+		}
+	}
+	return result
+}
+
+func fieldString(v any) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case []any:
+		if len(v) == 0 {
+			return "", false
+		}
+		if _, isBytes := v[0].(float64); isBytes {
+			bb := make([]byte, 0, len(v))
+			for _, b := range v {
+				n, ok := b.(float64)
+				if !ok {
+					return "", false
+				}
+				bb = append(bb, byte(n))
+			}
+			return string(bb), true
+		}
+		return fieldString(v[len(v)-1])
+	}
+	return "", false
+}
+
+// parseTimestamp parses a journal timestamp in microseconds since the epoch.
+// The zero time is returned if the timestamp is invalid.
+func parseTimestamp(usec string) time.Time {
+	n, err := strconv.ParseInt(usec, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.UnixMicro(n)
+}
+
+func makeJournalLabels(fields map[string]string) map[string]string {
+	result := make(map[string]string, len(fields))
+	for k, v := range fields {
+		if k == "PRIORITY" {
+			result[fmt.Sprintf("__journal_%s_%s", strings.ToLower(k), "keyword")] = makeJournalPriority(v)
+		}
+		result[fmt.Sprintf("__journal_%s", strings.ToLower(k))] = v
+	}
+	return result
+}
+
+func makeJournalPriority(priority string) string {
+	switch priority {
+	case "0":
+		return "emerg"
+	case "1":
+		return "alert"
+	case "2":
+		return "crit"
+	case "3":
+		return "error"
+	case "4":
+		return "warning"
+	case "5":
+		return "notice"
+	case "6":
+		return "info"
+	case "7":
+		return "debug"
+	}
+	return priority
+}
@@ -0,0 +1,242 @@
+// Package stdin implements the loki.source.stdin component.
+package stdin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/agent/internal/component"
+	"github.com/grafana/agent/internal/component/common/loki"
+	"github.com/grafana/agent/internal/featuregate"
+	"github.com/grafana/agent/internal/flow/logging/level"
+	"github.com/grafana/loki/pkg/logproto"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:      "loki.source.stdin",
+		Stability: featuregate.StabilityBeta,
+		Args:      Arguments{},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return New(opts, args.(Arguments))
+		},
+	})
+}
+
+// Arguments holds values which are used to configure the loki.source.stdin
+// component.
+type Arguments struct {
+	Receivers  []loki.LogsReceiver `river:"forward_to,attr"`
+	Labels     map[string]string   `river:"labels,attr,optional"`
+	JSONLabels map[string]string   `river:"json_labels,attr,optional"`
+}
+
+// Validate implements river.Validator.
+func (args *Arguments) Validate() error {
+	for name := range args.JSONLabels {
+		if !model.LabelName(name).IsValid() {
+			return fmt.Errorf("invalid json_labels label name %q", name)
+		}
+	}
+	return nil
+}
+
+type metrics struct {
+	lines          prometheus.Counter
+	jsonLines      prometheus.Counter
+	blockedSeconds prometheus.Counter
+}
+
+func newMetrics(reg prometheus.Registerer) *metrics {
+	var m metrics
+
+	m.lines = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "loki_source_stdin_lines_total",
+		Help: "Total number of lines read from stdin",
+	})
+	m.jsonLines = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "loki_source_stdin_json_lines_total",
+		Help: "Total number of lines read from stdin which were JSON objects",
+	})
+	m.blockedSeconds = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "loki_source_stdin_blocked_seconds_total",
+		Help: "Total time spent waiting for receivers to accept lines before reading more from stdin",
+	})
+
+	if reg != nil {
+		reg.MustRegister(m.lines, m.jsonLines, m.blockedSeconds)
+	}
+	return &m
+}
+
+var _ component.Component = (*Component)(nil)
+
+// Component implements the loki.source.stdin component.
+type Component struct {
+	opts    component.Options
+	metrics *metrics
+	handler chan loki.Entry
+	input   io.Reader
+
+	mut        sync.RWMutex
+	receivers  []loki.LogsReceiver
+	labels     model.LabelSet
+	jsonLabels map[string]string // Label names to the JSON fields they're read from.
+}
+
+// New creates a new loki.source.stdin component.
+func New(o component.Options, args Arguments) (*Component, error) {
+	return newComponent(o, args, os.Stdin)
+}
+
+// newComponent creates a new loki.source.stdin component reading from input.
+func newComponent(o component.Options, args Arguments, input io.Reader) (*Component, error) {
+	c := &Component{
+		opts:    o,
+		metrics: newMetrics(o.Registerer),
+		handler: make(chan loki.Entry),
+		input:   input,
+	}
+	if err := c.Update(args); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Run implements component.Component.
+func (c *Component) Run(ctx context.Context) error {
+	// Reading from stdin can't be interrupted, so the reader may only exit
+	// after Run returned, once it reads another line.
+	go c.read(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case entry := <-c.handler:
+			c.mut.RLock()
+			for _, receiver := range c.receivers {
+				receiver.Chan() <- entry
+			}
+			c.mut.RUnlock()
+		}
+	}
+}
+
+// Update implements component.Component.
+func (c *Component) Update(args component.Arguments) error {
+	newArgs := args.(Arguments)
+
+	labels := model.LabelSet{
+		model.LabelName("job"): model.LabelValue(c.opts.ID),
+	}
+	for k, v := range newArgs.Labels {
+		labels[model.LabelName(k)] = model.LabelValue(v)
+	}
+
+	jsonLabels := make(map[string]string, len(newArgs.JSONLabels))
+	for name, field := range newArgs.JSONLabels {
+		// If no field was specified, use the label name.
+		if field == "" {
+			field = name
+		}
+		jsonLabels[name] = field
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.receivers = newArgs.Receivers
+	c.labels = labels
+	c.jsonLabels = jsonLabels
+	return nil
+}
+
+// read reads lines from the input and sends them to the handler until the end
+// of the input is reached or ctx is canceled. No more is read from the input
+// until the receivers accept the last line, so that a large input isn't read
+// faster than it's sent on.
+func (c *Component) read(ctx context.Context) {
+	r := bufio.NewReader(c.input)
+	for {
+		line, err := r.ReadString('\n')
+		if line != "" {
+			entry := c.newEntry(strings.TrimRight(line, "\r\n"))
+			start := time.Now()
+			select {
+			case <-ctx.Done():
+				return
+			case c.handler <- entry:
+			}
+			c.metrics.blockedSeconds.Add(time.Since(start).Seconds())
+			c.metrics.lines.Inc()
+		}
+
+		if errors.Is(err, io.EOF) {
+			level.Info(c.opts.Logger).Log("msg", "reached the end of stdin")
+			return
+		} else if err != nil {
+			level.Error(c.opts.Logger).Log("msg", "failed to read from stdin", "err", err)
+			return
+		}
+	}
+}
+
+// newEntry returns the entry of a line. If the line is a JSON object, the
+// fields selected by json_labels are added to its labels.
+func (c *Component) newEntry(line string) loki.Entry {
+	c.mut.RLock()
+	labels := c.labels.Clone()
+	jsonLabels := c.jsonLabels
+	c.mut.RUnlock()
+
+	if len(jsonLabels) > 0 {
+		var fields map[string]any
+		if err := json.Unmarshal([]byte(line), &fields); err == nil && fields != nil {
+			c.metrics.jsonLines.Inc()
+			for name, field := range jsonLabels {
+				if value, ok := labelValue(fields[field]); ok {
+					labels[model.LabelName(name)] = value
+				}
+			}
+		}
+	}
+
+	return loki.Entry{
+		Labels: labels,
+		Entry: logproto.Entry{
+			Line:      line,
+			Timestamp: time.Now(),
+		},
+	}
+}
+
+// labelValue returns the label value of a JSON field. Only strings, numbers
+// and booleans can be used as label values.
+func labelValue(v any) (model.LabelValue, bool) {
+	var s string
+	switch v := v.(type) {
+	case string:
+		s = v
+	case float64:
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		s = strconv.FormatBool(v)
+	default:
+		return "", false
+	}
+
+	value := model.LabelValue(s)
+	return value, s != "" && value.IsValid()
+}
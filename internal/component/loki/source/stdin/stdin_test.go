@@ -0,0 +1,153 @@
+package stdin
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/internal/component"
+	"github.com/grafana/agent/internal/component/common/loki"
+	"github.com/grafana/agent/internal/util"
+	"github.com/grafana/river"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStdin(t *testing.T) {
+	input := strings.Join([]string{
+		`{"level":"error","app":"api","code":500,"msg":"request failed"}`,
+		`plain line`,
+		`{"level":"info","nested":{"app":"api"}}`,
+		`[1, 2, 3]`,
+		"crlf line\r",
+		`last line without newline`,
+	}, "\n")
+
+	receiver := loki.NewLogsReceiver()
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(`
+		forward_to  = []
+		labels      = { "source" = "stdin" }
+		json_labels = { "level" = "", "application" = "app", "status" = "code" }
+	`), &args))
+	args.Receivers = []loki.LogsReceiver{receiver}
+
+	c, err := newComponent(testOptions(t), args, strings.NewReader(input))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Run(ctx)
+
+	expected := []struct {
+		line   string
+		labels model.LabelSet
+	}{
+		{
+			line:   `{"level":"error","app":"api","code":500,"msg":"request failed"}`,
+			labels: model.LabelSet{"level": "error", "application": "api", "status": "500"},
+		},
+		{line: `plain line`},
+		{
+			line:   `{"level":"info","nested":{"app":"api"}}`,
+			labels: model.LabelSet{"level": "info"},
+		},
+		{line: `[1, 2, 3]`},
+		{line: `crlf line`},
+		{line: `last line without newline`},
+	}
+	for _, e := range expected {
+		select {
+		case entry := <-receiver.Chan():
+			require.Equal(t, e.line, entry.Line)
+			require.Equal(t, model.LabelSet{
+				"job":    "loki.source.stdin.test",
+				"source": "stdin",
+			}.Merge(e.labels), entry.Labels)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "failed waiting for log line")
+		}
+	}
+
+	require.Eventually(t, func() bool {
+		return testutil.ToFloat64(c.metrics.lines) == 6
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, 2.0, testutil.ToFloat64(c.metrics.jsonLines))
+}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r    io.Reader
+	read atomic.Int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.read.Add(int64(n))
+	return n, err
+}
+
+func TestStdinBackpressure(t *testing.T) {
+	const (
+		lineSize = 100
+		lines    = 100_000
+	)
+	input := &countingReader{
+		r: strings.NewReader(strings.Repeat(strings.Repeat("x", lineSize-1)+"\n", lines)),
+	}
+
+	receiver := loki.NewLogsReceiver()
+	c, err := newComponent(testOptions(t), Arguments{
+		Receivers: []loki.LogsReceiver{receiver},
+	}, input)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Run(ctx)
+
+	// While the receiver is slow, only a few lines are read ahead of it.
+	const maxReadAhead = 8 << 10
+	for i := 0; i < 10; i++ {
+		time.Sleep(10 * time.Millisecond)
+		select {
+		case <-receiver.Chan():
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "failed waiting for log line")
+		}
+		require.LessOrEqual(t, input.read.Load(), int64(maxReadAhead))
+	}
+	require.Greater(t, testutil.ToFloat64(c.metrics.blockedSeconds), 0.05)
+
+	// The whole input is read once the receiver keeps up.
+	for i := 10; i < lines; i++ {
+		select {
+		case <-receiver.Chan():
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "failed waiting for log line")
+		}
+	}
+	require.Equal(t, int64(lineSize*lines), input.read.Load())
+}
+
+func TestArguments(t *testing.T) {
+	var args Arguments
+	err := river.Unmarshal([]byte(`
+		forward_to  = []
+		json_labels = { "service.name" = "" }
+	`), &args)
+	require.EqualError(t, err, `invalid json_labels label name "service.name"`)
+}
+
+func testOptions(t *testing.T) component.Options {
+	return component.Options{
+		ID:         "loki.source.stdin.test",
+		Logger:     util.TestFlowLogger(t),
+		Registerer: prometheus.NewRegistry(),
+	}
+}
@@ -13,6 +13,8 @@ type Metrics struct {
 	syslogEntries       prometheus.Counter
 	syslogParsingErrors prometheus.Counter
 	syslogEmptyMessages prometheus.Counter
+
+	syslogStructuredDataErrors prometheus.Counter
 }
 
 // NewMetrics creates a new set of syslog metrics. If reg is non-nil, the
@@ -33,12 +35,17 @@ func NewMetrics(reg prometheus.Registerer) *Metrics {
 		Name: "loki_source_syslog_empty_messages_total",
 		Help: "Total number of empty messages received from syslog",
 	})
+	m.syslogStructuredDataErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "loki_source_syslog_structured_data_errors_total",
+		Help: "Total number of malformed structured data elements or parameters received from syslog",
+	})
 
 	if reg != nil {
 		reg.MustRegister(
 			m.syslogEntries,
 			m.syslogParsingErrors,
 			m.syslogEmptyMessages,
+			m.syslogStructuredDataErrors,
 		)
 	}
 
@@ -8,8 +8,10 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"regexp"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/go-kit/log"
 	"github.com/grafana/agent/internal/flow/logging/level"
@@ -41,6 +43,10 @@ type SyslogTarget struct {
 	config        *scrapeconfig.SyslogTargetConfig
 	relabelConfig []*relabel.Config
 
+	// structuredDataPrefix is the prefix of the labels structured data
+	// parameters are promoted to. Structured data isn't promoted if empty.
+	structuredDataPrefix string
+
 	transport Transport
 
 	messages     chan message
@@ -53,22 +59,25 @@ type message struct {
 	timestamp time.Time
 }
 
-// NewSyslogTarget configures a new SyslogTarget.
+// NewSyslogTarget configures a new SyslogTarget. If structuredDataPrefix is
+// set, structured data parameters are added as labels with that prefix.
 func NewSyslogTarget(
 	metrics *Metrics,
 	logger log.Logger,
 	handler loki.EntryHandler,
 	relabel []*relabel.Config,
 	config *scrapeconfig.SyslogTargetConfig,
+	structuredDataPrefix string,
 ) (*SyslogTarget, error) {
 
 	t := &SyslogTarget{
-		metrics:       metrics,
-		logger:        logger,
-		handler:       handler,
-		config:        config,
-		relabelConfig: relabel,
-		messagesDone:  make(chan struct{}),
+		metrics:              metrics,
+		logger:               logger,
+		handler:              handler,
+		config:               config,
+		relabelConfig:        relabel,
+		structuredDataPrefix: structuredDataPrefix,
+		messagesDone:         make(chan struct{}),
 	}
 
 	switch t.transportProtocol() {
@@ -108,6 +117,49 @@ func (t *SyslogTarget) handleMessageError(err error) {
 	}
 	level.Warn(t.logger).Log("msg", "error parsing syslog stream", "err", err)
 	t.metrics.syslogParsingErrors.Inc()
+	if isStructuredDataError(err) {
+		t.metrics.syslogStructuredDataErrors.Inc()
+	}
+}
+
+// structuredDataErrors are the errors reported by the RFC5424 parser for
+// malformed structured data.
+var structuredDataErrors = []string{
+	rfc5424.ErrStructuredData,
+	rfc5424.ErrSdID,
+	rfc5424.ErrSdIDDuplicated,
+	rfc5424.ErrSdParam,
+	rfc5424.ErrEscape,
+}
+
+func isStructuredDataError(err error) bool {
+	for _, sdErr := range structuredDataErrors {
+		if strings.HasPrefix(err.Error(), sdErr) {
+			return true
+		}
+	}
+	return false
+}
+
+// invalidLabelChars matches characters which can't be used in label names.
+var invalidLabelChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// promoteStructuredData adds the structured data parameters of msg as labels
+// named <prefix><ID>_<KEY>, where characters which can't be used in label
+// names are replaced with underscores. Parameters with values which aren't
+// valid UTF-8 are skipped.
+func (t *SyslogTarget) promoteStructuredData(lb *labels.Builder, msg *rfc5424.SyslogMessage) {
+	for id, params := range *msg.StructuredData {
+		for name, value := range params {
+			if !utf8.ValidString(value) {
+				level.Debug(t.logger).Log("msg", "skipping structured data parameter with invalid UTF-8 value", "id", id, "param", name)
+				t.metrics.syslogStructuredDataErrors.Inc()
+				continue
+			}
+			key := t.structuredDataPrefix + invalidLabelChars.ReplaceAllString(id+"_"+name, "_")
+			lb.Set(key, value)
+		}
+	}
 }
 
 func (t *SyslogTarget) handleMessage(connLabels labels.Labels, msg syslog.Message) {
@@ -147,6 +199,9 @@ func (t *SyslogTarget) handleMessage(connLabels labels.Labels, msg syslog.Messag
 			}
 		}
 	}
+	if t.structuredDataPrefix != "" && rfc5424Msg.StructuredData != nil {
+		t.promoteStructuredData(lb, rfc5424Msg)
+	}
 
 	processed, _ := relabel.Process(lb.Labels(), t.relabelConfig...)
 
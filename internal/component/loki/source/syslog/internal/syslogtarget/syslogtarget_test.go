@@ -22,6 +22,7 @@ import (
 	"github.com/grafana/loki/clients/pkg/promtail/targets/syslog/syslogparser"
 	"github.com/influxdata/go-syslog/v3"
 	promconfig "github.com/prometheus/common/config"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/relabel"
 	"github.com/stretchr/testify/require"
@@ -321,7 +322,7 @@ func Benchmark_SyslogTarget(b *testing.B) {
 				Labels: model.LabelSet{
 					"test": "syslog_target",
 				},
-			})
+			}, "")
 			b.Cleanup(func() {
 				require.NoError(b, tgt.Stop())
 			})
@@ -384,7 +385,7 @@ func TestSyslogTarget(t *testing.T) {
 				Labels: model.LabelSet{
 					"test": "syslog_target",
 				},
-			})
+			}, "")
 			require.NoError(t, err)
 
 			require.Eventually(t, tgt.Ready, time.Second, 10*time.Millisecond)
@@ -499,7 +500,7 @@ func TestSyslogTarget_RFC5424Messages(t *testing.T) {
 					"test": "syslog_target",
 				},
 				UseRFC5424Message: true,
-			})
+			}, "")
 			require.NoError(t, err)
 			require.Eventually(t, tgt.Ready, time.Second, 10*time.Millisecond)
 			defer func() {
@@ -546,7 +547,7 @@ func TestSyslogTarget_TLSConfigWithoutServerCertificate(t *testing.T) {
 		TLSConfig: promconfig.TLSConfig{
 			KeyFile: "foo",
 		},
-	})
+	}, "")
 	require.Error(t, err, "error setting up syslog target: certificate and key files are required")
 }
 
@@ -561,7 +562,7 @@ func TestSyslogTarget_TLSConfigWithoutServerKey(t *testing.T) {
 		TLSConfig: promconfig.TLSConfig{
 			CertFile: "foo",
 		},
-	})
+	}, "")
 	require.Error(t, err, "error setting up syslog target: certificate and key files are required")
 }
 
@@ -605,7 +606,7 @@ func testSyslogTargetWithTLS(t *testing.T, fmtFunc formatFunc) {
 			CertFile: serverCertFile.Name(),
 			KeyFile:  serverKeyFile.Name(),
 		},
-	})
+	}, "")
 	require.NoError(t, err)
 	defer func() {
 		require.NoError(t, tgt.Stop())
@@ -657,6 +658,49 @@ func testSyslogTargetWithTLS(t *testing.T, fmtFunc formatFunc) {
 	require.NotZero(t, client.Received()[0].Timestamp)
 }
 
+func TestSyslogTarget_StructuredDataPrefix(t *testing.T) {
+	client := fake.NewClient(func() {})
+
+	metrics := NewMetrics(nil)
+	tgt, err := NewSyslogTarget(metrics, log.NewNopLogger(), client, []*relabel.Config{}, &scrapeconfig.SyslogTargetConfig{
+		ListenAddress:  "127.0.0.1:0",
+		ListenProtocol: protocolTCP,
+		Labels: model.LabelSet{
+			"test": "syslog_target",
+		},
+	}, "sd_")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, tgt.Stop())
+	}()
+	require.Eventually(t, tgt.Ready, time.Second, 10*time.Millisecond)
+
+	c, err := net.Dial(protocolTCP, tgt.ListenAddress().String())
+	require.NoError(t, err)
+
+	messages := []string{
+		// Malformed structured data is counted and the message is skipped.
+		`<165>1 2018-10-11T22:14:15.003Z host5 e - id1 [custom@32473 exkey=1] An application event log entry...`,
+		`<165>1 2018-10-11T22:14:15.005Z host5 e - id2 [custom@32473 exkey="2" ex-key.2="x"][origin ip="10.0.0.1"] An application event log entry...`,
+	}
+	require.NoError(t, writeMessagesToStream(c, messages, fmtNewline))
+	require.NoError(t, c.Close())
+
+	require.Eventually(t, func() bool {
+		return len(client.Received()) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	require.Equal(t, model.LabelSet{
+		"test": "syslog_target",
+
+		"sd_custom_32473_exkey":    "2",
+		"sd_custom_32473_ex_key_2": "x",
+		"sd_origin_ip":             "10.0.0.1",
+	}, client.Received()[0].Labels)
+	require.Equal(t, "An application event log entry...", client.Received()[0].Line)
+	require.Equal(t, 1.0, testutil.ToFloat64(metrics.syslogStructuredDataErrors))
+}
+
 func createTempFile(data []byte) (*os.File, error) {
 	tmpFile, err := os.CreateTemp("", "")
 	if err != nil {
@@ -738,7 +782,7 @@ func testSyslogTargetWithTLSVerifyClientCertificate(t *testing.T, fmtFunc format
 			CertFile: serverCertFile.Name(),
 			KeyFile:  serverKeyFile.Name(),
 		},
-	})
+	}, "")
 	require.NoError(t, err)
 	defer func() {
 		require.NoError(t, tgt.Stop())
@@ -807,7 +851,7 @@ func TestSyslogTarget_InvalidData(t *testing.T) {
 
 	tgt, err := NewSyslogTarget(metrics, logger, client, relabelConfig(t), &scrapeconfig.SyslogTargetConfig{
 		ListenAddress: "127.0.0.1:0",
-	})
+	}, "")
 	require.NoError(t, err)
 	defer func() {
 		require.NoError(t, tgt.Stop())
@@ -838,7 +882,7 @@ func TestSyslogTarget_NonUTF8Message(t *testing.T) {
 
 	tgt, err := NewSyslogTarget(metrics, logger, client, relabelConfig(t), &scrapeconfig.SyslogTargetConfig{
 		ListenAddress: "127.0.0.1:0",
-	})
+	}, "")
 	require.NoError(t, err)
 	defer func() {
 		require.NoError(t, tgt.Stop())
@@ -877,7 +921,7 @@ func TestSyslogTarget_IdleTimeout(t *testing.T) {
 	tgt, err := NewSyslogTarget(metrics, logger, client, relabelConfig(t), &scrapeconfig.SyslogTargetConfig{
 		ListenAddress: "127.0.0.1:0",
 		IdleTimeout:   time.Millisecond,
-	})
+	}, "")
 	require.NoError(t, err)
 	defer func() {
 		require.NoError(t, tgt.Stop())
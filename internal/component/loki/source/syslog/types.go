@@ -2,6 +2,7 @@ package syslog
 
 import (
 	"fmt"
+	"regexp"
 	"time"
 
 	"github.com/grafana/agent/internal/component/common/config"
@@ -16,6 +17,7 @@ type ListenerConfig struct {
 	ListenProtocol       string            `river:"protocol,attr,optional"`
 	IdleTimeout          time.Duration     `river:"idle_timeout,attr,optional"`
 	LabelStructuredData  bool              `river:"label_structured_data,attr,optional"`
+	StructuredDataPrefix string            `river:"structured_data_prefix,attr,optional"`
 	Labels               map[string]string `river:"labels,attr,optional"`
 	UseIncomingTimestamp bool              `river:"use_incoming_timestamp,attr,optional"`
 	UseRFC5424Message    bool              `river:"use_rfc5424_message,attr,optional"`
@@ -41,9 +43,16 @@ func (sc *ListenerConfig) Validate() error {
 		return fmt.Errorf("syslog listener protocol should be either 'tcp' or 'udp', got %s", sc.ListenProtocol)
 	}
 
+	if sc.StructuredDataPrefix != "" && !structuredDataPrefixRegexp.MatchString(sc.StructuredDataPrefix) {
+		return fmt.Errorf("syslog listener structured_data_prefix must be a valid label name prefix, got %q", sc.StructuredDataPrefix)
+	}
+
 	return nil
 }
 
+// structuredDataPrefixRegexp matches valid prefixes of label names.
+var structuredDataPrefixRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
 // Convert is used to bridge between the River and Promtail types.
 func (sc ListenerConfig) Convert() *scrapeconfig.SyslogTargetConfig {
 	lbls := make(model.LabelSet, len(sc.Labels))
@@ -0,0 +1,356 @@
+// Package awssecrets implements the remote.awssecrets component.
+package awssecrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	aws_config "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/go-kit/log"
+	"github.com/grafana/agent/internal/component"
+	"github.com/grafana/agent/internal/featuregate"
+	"github.com/grafana/agent/internal/flow/logging/level"
+	"github.com/grafana/river/rivertypes"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:      "remote.awssecrets",
+		Stability: featuregate.StabilityBeta,
+		Args:      Arguments{},
+		Exports:   Exports{},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return New(opts, args.(Arguments))
+		},
+	})
+}
+
+// Arguments configures remote.awssecrets.
+type Arguments struct {
+	SecretID     string `river:"secret_id,attr"`
+	VersionStage string `river:"version_stage,attr,optional"`
+
+	RereadFrequency time.Duration `river:"reread_frequency,attr,optional"`
+	RequestTimeout  time.Duration `river:"request_timeout,attr,optional"`
+
+	Client Client `river:"client,block,optional"`
+}
+
+// Client configures how to connect and authenticate to AWS Secrets Manager.
+type Client struct {
+	AccessKey string            `river:"key,attr,optional"`
+	Secret    rivertypes.Secret `river:"secret,attr,optional"`
+	Region    string            `river:"region,attr,optional"`
+	RoleARN   string            `river:"role_arn,attr,optional"`
+	Endpoint  string            `river:"endpoint,attr,optional"`
+}
+
+// DefaultArguments holds default settings for Arguments.
+var DefaultArguments = Arguments{
+	RequestTimeout: 30 * time.Second,
+}
+
+// SetToDefault implements river.Defaulter.
+func (a *Arguments) SetToDefault() {
+	*a = DefaultArguments
+}
+
+// Validate implements river.Validator.
+func (a *Arguments) Validate() error {
+	if a.SecretID == "" {
+		return fmt.Errorf("secret_id must not be empty")
+	}
+	if a.RereadFrequency < 0 {
+		return fmt.Errorf("reread_frequency must not be negative")
+	}
+	if a.RequestTimeout <= 0 {
+		return fmt.Errorf("request_timeout must be greater than 0")
+	}
+	if (a.Client.AccessKey == "") != (a.Client.Secret == "") {
+		return fmt.Errorf("if key or secret are specified then the other must also be specified")
+	}
+	return nil
+}
+
+// secretsManagerAPI is the subset of the Secrets Manager client used by the
+// component.
+type secretsManagerAPI interface {
+	GetSecretValue(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error)
+}
+
+// client creates a Secrets Manager client from the arguments. Credentials
+// which aren't set in the client block are taken from the default AWS
+// credential chain.
+func (a *Arguments) client(ctx context.Context) (secretsManagerAPI, error) {
+	var configOptions []func(*aws_config.LoadOptions) error
+	if a.Client.AccessKey != "" {
+		credFunc := aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+			return aws.Credentials{
+				AccessKeyID:     a.Client.AccessKey,
+				SecretAccessKey: string(a.Client.Secret),
+			}, nil
+		})
+		configOptions = append(configOptions, aws_config.WithCredentialsProvider(credFunc))
+	}
+	if a.Client.Region != "" {
+		configOptions = append(configOptions, aws_config.WithRegion(a.Client.Region))
+	}
+
+	cfg, err := aws_config.LoadDefaultConfig(ctx, configOptions...)
+	if err != nil {
+		return nil, err
+	}
+
+	if a.Client.RoleARN != "" {
+		provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cfg), a.Client.RoleARN)
+		cfg.Credentials = aws.NewCredentialsCache(provider)
+	}
+
+	return secretsmanager.NewFromConfig(cfg, func(o *secretsmanager.Options) {
+		if a.Client.Endpoint != "" {
+			o.BaseEndpoint = aws.String(a.Client.Endpoint)
+		}
+	}), nil
+}
+
+// Exports is the values exported by remote.awssecrets.
+type Exports struct {
+	// Data holds the keys of the secret. Secrets holding a JSON object have
+	// each of their keys exported; other secrets are exported under the
+	// "value" key.
+	Data map[string]rivertypes.Secret `river:"data,attr"`
+}
+
+// valueKey is the key plain text and binary secrets are exported under.
+const valueKey = "value"
+
+// Component implements the remote.awssecrets component.
+type Component struct {
+	opts    component.Options
+	log     log.Logger
+	metrics *metrics
+
+	mut         sync.Mutex
+	args        Arguments
+	cli         secretsManagerAPI
+	lastRead    time.Time
+	lastExports Exports // Used for determining whether exports should be updated
+
+	// updated is written to whenever args updates.
+	updated chan struct{}
+
+	healthMut sync.RWMutex
+	health    component.Health
+}
+
+var (
+	_ component.Component       = (*Component)(nil)
+	_ component.HealthComponent = (*Component)(nil)
+)
+
+// New creates a new remote.awssecrets component. It will try to immediately
+// read the secret and return an error if the secret can't be read.
+func New(opts component.Options, args Arguments) (*Component, error) {
+	c := &Component{
+		opts:    opts,
+		log:     opts.Logger,
+		metrics: newMetrics(opts.Registerer),
+
+		updated: make(chan struct{}, 1),
+	}
+
+	if err := c.Update(args); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Run runs the remote.awssecrets component, rereading the secret every
+// reread_frequency.
+func (c *Component) Run(ctx context.Context) error {
+	for {
+		wait, ok := c.nextRead()
+
+		var (
+			timer  *time.Timer
+			readCh <-chan time.Time
+		)
+		if ok {
+			timer = time.NewTimer(wait)
+			readCh = timer.C
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-readCh:
+			c.updateHealth(c.read(ctx))
+		case <-c.updated:
+			// no-op; force the next wait to be reread.
+		}
+
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// nextRead returns how long to wait before rereading the secret. It returns
+// false if the secret shouldn't be reread.
+func (c *Component) nextRead() (time.Duration, bool) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if c.args.RereadFrequency == 0 {
+		return 0, false
+	}
+	return max(time.Until(c.lastRead.Add(c.args.RereadFrequency)), 0), true
+}
+
+// read reads the secret and exports its data if it changed. c.mut must not be
+// held when calling read.
+func (c *Component) read(ctx context.Context) error {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	c.lastRead = time.Now()
+
+	ctx, cancel := context.WithTimeout(ctx, c.args.RequestTimeout)
+	defer cancel()
+
+	input := &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(c.args.SecretID),
+	}
+	if c.args.VersionStage != "" {
+		input.VersionStage = aws.String(c.args.VersionStage)
+	}
+
+	out, err := c.cli.GetSecretValue(ctx, input)
+	if err != nil {
+		c.metrics.secretReadTotal.WithLabelValues("false").Inc()
+		level.Error(c.log).Log("msg", "failed to read secret", "secret_id", c.args.SecretID, "err", err)
+		return fmt.Errorf("reading secret: %w", err)
+	}
+	c.metrics.secretReadTotal.WithLabelValues("true").Inc()
+
+	newExports := Exports{
+		Data: convertData(out),
+	}
+
+	// Only send a state change event if the exports have changed from the
+	// previous read.
+	if !reflect.DeepEqual(c.lastExports, newExports) {
+		c.opts.OnStateChange(newExports)
+	}
+	c.lastExports = newExports
+	return nil
+}
+
+// convertData converts the value of a secret into values which can be
+// exported. Keys of JSON objects which don't hold strings are exported as
+// their JSON encoding.
+func convertData(out *secretsmanager.GetSecretValueOutput) map[string]rivertypes.Secret {
+	if out.SecretString == nil {
+		return map[string]rivertypes.Secret{
+			valueKey: rivertypes.Secret(out.SecretBinary),
+		}
+	}
+
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(*out.SecretString), &object); err != nil || object == nil {
+		return map[string]rivertypes.Secret{
+			valueKey: rivertypes.Secret(*out.SecretString),
+		}
+	}
+
+	converted := make(map[string]rivertypes.Secret, len(object))
+	for key, raw := range object {
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			value = string(raw)
+		}
+		converted[key] = rivertypes.Secret(value)
+	}
+	return converted
+}
+
+func (c *Component) updateHealth(err error) {
+	c.healthMut.Lock()
+	defer c.healthMut.Unlock()
+
+	if err == nil {
+		c.health = component.Health{
+			Health:     component.HealthTypeHealthy,
+			Message:    "read secret",
+			UpdateTime: time.Now(),
+		}
+	} else {
+		c.health = component.Health{
+			Health:     component.HealthTypeUnhealthy,
+			Message:    fmt.Sprintf("reading secret failed: %s", err),
+			UpdateTime: time.Now(),
+		}
+	}
+}
+
+// Update updates the remote.awssecrets component. It will try to immediately
+// read the secret and return an error if the secret can't be read.
+func (c *Component) Update(args component.Arguments) error {
+	newArgs := args.(Arguments)
+
+	cli, err := newArgs.client(context.Background())
+	if err != nil {
+		return err
+	}
+
+	c.mut.Lock()
+	c.args = newArgs
+	c.cli = cli
+	c.mut.Unlock()
+
+	// Send an updated event if one wasn't already read.
+	select {
+	case c.updated <- struct{}{}:
+	default:
+	}
+
+	err = c.read(context.Background())
+	c.updateHealth(err)
+	return err
+}
+
+// CurrentHealth returns the current health of the remote.awssecrets
+// component. It will be healthy as long as the latest read was successful.
+func (c *Component) CurrentHealth() component.Health {
+	c.healthMut.RLock()
+	defer c.healthMut.RUnlock()
+	return c.health
+}
+
+type metrics struct {
+	secretReadTotal *prometheus.CounterVec
+}
+
+func newMetrics(r prometheus.Registerer) *metrics {
+	var m metrics
+
+	m.secretReadTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "remote_awssecrets_secret_reads_total",
+		Help: "Total number of times this component attempted to read the secret from AWS Secrets Manager",
+	}, []string{"success"})
+
+	if r != nil {
+		r.MustRegister(m.secretReadTotal)
+	}
+	return &m
+}
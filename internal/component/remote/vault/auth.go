@@ -3,6 +3,8 @@ package vault
 import (
 	"context"
 	"fmt"
+	"os"
+	"strings"
 
 	"github.com/grafana/river/rivertypes"
 	vault "github.com/hashicorp/vault/api"
@@ -33,7 +35,9 @@ type AuthArguments struct {
 	AuthAppRole    *AuthAppRole    `river:"approle,block,optional"`
 	AuthAWS        *AuthAWS        `river:"aws,block,optional"`
 	AuthAzure      *AuthAzure      `river:"azure,block,optional"`
+	AuthCert       *AuthCert       `river:"cert,block,optional"`
 	AuthGCP        *AuthGCP        `river:"gcp,block,optional"`
+	AuthJWT        *AuthJWT        `river:"jwt,block,optional"`
 	AuthKubernetes *AuthKubernetes `river:"kubernetes,block,optional"`
 	AuthLDAP       *AuthLDAP       `river:"ldap,block,optional"`
 	AuthUserPass   *AuthUserPass   `river:"userpass,block,optional"`
@@ -50,8 +54,12 @@ func (a *AuthArguments) authMethod() authMethod {
 		return a.AuthAWS
 	case a.AuthAzure != nil:
 		return a.AuthAzure
+	case a.AuthCert != nil:
+		return a.AuthCert
 	case a.AuthGCP != nil:
 		return a.AuthGCP
+	case a.AuthJWT != nil:
+		return a.AuthJWT
 	case a.AuthKubernetes != nil:
 		return a.AuthKubernetes
 	case a.AuthLDAP != nil:
@@ -68,6 +76,35 @@ func (a *AuthArguments) authMethod() authMethod {
 	return invalidAuth{}
 }
 
+// name returns the name of the configured auth block.
+func (a *AuthArguments) name() string {
+	switch {
+	case a.AuthToken != nil:
+		return "token"
+	case a.AuthAppRole != nil:
+		return "approle"
+	case a.AuthAWS != nil:
+		return "aws"
+	case a.AuthAzure != nil:
+		return "azure"
+	case a.AuthCert != nil:
+		return "cert"
+	case a.AuthGCP != nil:
+		return "gcp"
+	case a.AuthJWT != nil:
+		return "jwt"
+	case a.AuthKubernetes != nil:
+		return "kubernetes"
+	case a.AuthLDAP != nil:
+		return "ldap"
+	case a.AuthUserPass != nil:
+		return "userpass"
+	case a.AuthCustom != nil:
+		return "custom"
+	}
+	return ""
+}
+
 // AuthToken authenticates against Vault with a token.
 type AuthToken struct {
 	Token rivertypes.Secret `river:"token,attr"`
@@ -256,6 +293,42 @@ func (a *AuthAzure) vaultAuthenticate(ctx context.Context, cli *vault.Client) (*
 	return s, nil
 }
 
+// AuthCert authenticates against Vault with the TLS client certificate
+// configured in the tls_config block.
+type AuthCert struct {
+	// Name of the certificate role to authenticate against. If empty, Vault
+	// tries all certificate roles which match the client certificate.
+	Name      string `river:"name,attr,optional"`
+	MountPath string `river:"mount_path,attr,optional"`
+}
+
+// DefaultAuthCert provides default settings for AuthCert.
+var DefaultAuthCert = AuthCert{
+	MountPath: "cert",
+}
+
+// SetToDefault implements river.Defaulter.
+func (a *AuthCert) SetToDefault() {
+	*a = DefaultAuthCert
+}
+
+// Login implements vault.AuthMethod.
+func (a *AuthCert) Login(ctx context.Context, client *vault.Client) (*vault.Secret, error) {
+	data := map[string]interface{}{}
+	if a.Name != "" {
+		data["name"] = a.Name
+	}
+	return client.Logical().WriteWithContext(ctx, fmt.Sprintf("auth/%s/login", a.MountPath), data)
+}
+
+func (a *AuthCert) vaultAuthenticate(ctx context.Context, cli *vault.Client) (*vault.Secret, error) {
+	s, err := cli.Auth().Login(ctx, a)
+	if err != nil {
+		return nil, fmt.Errorf("auth.cert: %w", err)
+	}
+	return s, nil
+}
+
 // AuthGCP authenticates against Vault with GCP.
 type AuthGCP struct {
 	Role string `river:"role,attr"`
@@ -328,7 +401,65 @@ func (a *AuthGCP) vaultAuthenticate(ctx context.Context, cli *vault.Client) (*va
 	return s, nil
 }
 
-// AuthKubernetes authenticates against Vault with Kubernetes.
+// AuthJWT authenticates against Vault with a JWT or OIDC token. When JWTFile
+// is set, the file is read on every login so that rotated tokens are picked
+// up.
+type AuthJWT struct {
+	Role      string            `river:"role,attr"`
+	JWT       rivertypes.Secret `river:"jwt,attr,optional"`
+	JWTFile   string            `river:"jwt_file,attr,optional"`
+	MountPath string            `river:"mount_path,attr,optional"`
+}
+
+// DefaultAuthJWT provides default settings for AuthJWT.
+var DefaultAuthJWT = AuthJWT{
+	MountPath: "jwt",
+}
+
+// SetToDefault implements river.Defaulter.
+func (a *AuthJWT) SetToDefault() {
+	*a = DefaultAuthJWT
+}
+
+// Validate implements river.Validator.
+func (a *AuthJWT) Validate() error {
+	if a.JWT != "" && a.JWTFile != "" {
+		return fmt.Errorf("at most one of jwt and jwt_file may be provided")
+	} else if a.JWT == "" && a.JWTFile == "" {
+		return fmt.Errorf("one of jwt or jwt_file must be provided")
+	}
+	return nil
+}
+
+// Login implements vault.AuthMethod.
+func (a *AuthJWT) Login(ctx context.Context, client *vault.Client) (*vault.Secret, error) {
+	jwt := string(a.JWT)
+	if a.JWTFile != "" {
+		bb, err := os.ReadFile(a.JWTFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read jwt_file: %w", err)
+		}
+		jwt = strings.TrimSpace(string(bb))
+	}
+
+	data := map[string]interface{}{
+		"role": a.Role,
+		"jwt":  jwt,
+	}
+	return client.Logical().WriteWithContext(ctx, fmt.Sprintf("auth/%s/login", a.MountPath), data)
+}
+
+func (a *AuthJWT) vaultAuthenticate(ctx context.Context, cli *vault.Client) (*vault.Secret, error) {
+	s, err := cli.Auth().Login(ctx, a)
+	if err != nil {
+		return nil, fmt.Errorf("auth.jwt: %w", err)
+	}
+	return s, nil
+}
+
+// AuthKubernetes authenticates against Vault with Kubernetes. The service
+// account token file is read on every login so that rotated tokens (such as
+// projected service account tokens) are picked up.
 type AuthKubernetes struct {
 	Role                    string `river:"role,attr"`
 	ServiceAccountTokenFile string `river:"service_account_file,attr,optional"`
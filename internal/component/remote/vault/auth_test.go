@@ -0,0 +1,349 @@
+package vault
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/internal/component"
+	"github.com/grafana/agent/internal/flow/componenttest"
+	"github.com/grafana/agent/internal/util"
+	"github.com/grafana/river"
+	"github.com/grafana/river/rivertypes"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+func Test_AuthKubernetes(t *testing.T) {
+	var (
+		ctx = componenttest.TestContext(t)
+		l   = util.TestLogger(t)
+	)
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("jwt-1"), 0600))
+
+	var (
+		loginsMut sync.Mutex
+		logins    []string
+	)
+
+	stub := newStubVault(t)
+	stub.Handle("auth/kubernetes/login", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			JWT  string `json:"jwt"`
+			Role string `json:"role"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Role != "agent" {
+			http.Error(w, "bad login request", http.StatusBadRequest)
+			return
+		}
+
+		loginsMut.Lock()
+		logins = append(logins, req.JWT)
+		loginsMut.Unlock()
+
+		writeStubResponse(w, map[string]any{
+			"auth": map[string]any{
+				"client_token":   "k8s-token",
+				"lease_duration": 3600,
+				"renewable":      true,
+			},
+		})
+	})
+	stub.HandleKVv2("secret", "test", map[string]any{"key": "value"})
+
+	cfg := fmt.Sprintf(`
+		server = "%s"
+		path   = "secret/test"
+
+		auth.kubernetes {
+			role                 = "agent"
+			service_account_file = "%s"
+		}
+	`, stub.Address(), tokenFile)
+
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(cfg), &args))
+
+	ctrl, err := componenttest.NewControllerFromID(l, "remote.vault")
+	require.NoError(t, err)
+
+	go func() {
+		require.NoError(t, ctrl.Run(ctx, args))
+	}()
+
+	require.NoError(t, ctrl.WaitRunning(time.Minute))
+	require.NoError(t, ctrl.WaitExports(time.Minute))
+
+	require.Equal(t, Exports{
+		Data: map[string]rivertypes.Secret{
+			"key": rivertypes.Secret("value"),
+		},
+	}, ctrl.Exports().(Exports))
+
+	// Rotate the service account token. The next login must send the new JWT
+	// rather than a copy cached from the previous login.
+	require.NoError(t, os.WriteFile(tokenFile, []byte("jwt-2"), 0600))
+	require.NoError(t, ctrl.Update(args))
+
+	loginsMut.Lock()
+	defer loginsMut.Unlock()
+	require.Equal(t, []string{"jwt-1", "jwt-2"}, logins)
+}
+
+func Test_AuthJWT(t *testing.T) {
+	jwtFile := filepath.Join(t.TempDir(), "jwt")
+	require.NoError(t, os.WriteFile(jwtFile, []byte("jwt-1\n"), 0600))
+
+	var (
+		loginsMut sync.Mutex
+		logins    []string
+	)
+
+	stub := newStubVault(t)
+	stub.Handle("auth/jwt/login", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			JWT  string `json:"jwt"`
+			Role string `json:"role"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Role != "ci" {
+			http.Error(w, "bad login request", http.StatusBadRequest)
+			return
+		}
+
+		loginsMut.Lock()
+		logins = append(logins, req.JWT)
+		loginsMut.Unlock()
+
+		writeStubResponse(w, map[string]any{
+			"auth": map[string]any{"client_token": "jwt-token"},
+		})
+	})
+	stub.HandleKVv2("secret", "test", map[string]any{"key": "value"})
+
+	newArgs := func(auth string) Arguments {
+		cfg := fmt.Sprintf(`
+			server = "%s"
+			path   = "secret/test"
+
+			auth.jwt {
+				role = "ci"
+				%s
+			}
+		`, stub.Address(), auth)
+
+		var args Arguments
+		require.NoError(t, river.Unmarshal([]byte(cfg), &args))
+		return args
+	}
+	opts := component.Options{
+		ID:            "remote.vault.test",
+		Logger:        util.TestLogger(t),
+		OnStateChange: func(e component.Exports) {},
+	}
+
+	// Log in with an inline JWT.
+	_, err := New(opts, newArgs(`jwt = "inline-jwt"`))
+	require.NoError(t, err)
+
+	// Log in with a JWT file, which must be reread after being rotated.
+	fileArgs := newArgs(fmt.Sprintf(`jwt_file = "%s"`, jwtFile))
+	c, err := New(opts, fileArgs)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(jwtFile, []byte("jwt-2\n"), 0600))
+	require.NoError(t, c.Update(fileArgs))
+
+	loginsMut.Lock()
+	defer loginsMut.Unlock()
+	require.Equal(t, []string{"inline-jwt", "jwt-1", "jwt-2"}, logins)
+}
+
+func Test_AuthJWT_Invalid(t *testing.T) {
+	tt := []struct {
+		name      string
+		auth      string
+		expectErr string
+	}{
+		{name: "none", auth: ``, expectErr: "one of jwt or jwt_file must be provided"},
+		{name: "both", auth: "jwt = \"token\"\njwt_file = \"/tmp/jwt\"", expectErr: "at most one of jwt and jwt_file may be provided"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := fmt.Sprintf(`
+				server = "http://localhost:8200"
+				path   = "secret/test"
+
+				auth.jwt {
+					role = "ci"
+					%s
+				}
+			`, tc.auth)
+
+			var args Arguments
+			require.EqualError(t, river.Unmarshal([]byte(cfg), &args), tc.expectErr)
+		})
+	}
+}
+
+func Test_AuthCert(t *testing.T) {
+	certs := newTestCertificates(t)
+
+	serverCert, err := tls.LoadX509KeyPair(certs.ServerCertFile, certs.ServerKeyFile)
+	require.NoError(t, err)
+
+	stub := newStubVaultTLS(t, &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientCAs:    certs.Pool,
+		ClientAuth:   tls.VerifyClientCertIfGiven,
+	})
+	stub.Handle("auth/cert/login", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name != "agent" {
+			http.Error(w, "bad login request", http.StatusBadRequest)
+			return
+		} else if len(r.TLS.PeerCertificates) == 0 {
+			http.Error(w, "missing client certificate", http.StatusBadRequest)
+			return
+		}
+
+		writeStubResponse(w, map[string]any{
+			"auth": map[string]any{"client_token": "cert-token"},
+		})
+	})
+	stub.Handle("secret/data/test", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "cert-token" {
+			http.Error(w, "permission denied", http.StatusForbidden)
+			return
+		}
+		writeStubResponse(w, map[string]any{
+			"data": map[string]any{
+				"data":     map[string]any{"key": "value"},
+				"metadata": map[string]any{"version": 1},
+			},
+		})
+	})
+
+	cfg := fmt.Sprintf(`
+		server = "%s"
+		path   = "secret/test"
+
+		tls_config {
+			ca_file   = "%s"
+			cert_file = "%s"
+			key_file  = "%s"
+		}
+
+		auth.cert {
+			name = "agent"
+		}
+	`, stub.Address(), certs.CAFile, certs.ClientCertFile, certs.ClientKeyFile)
+
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(cfg), &args))
+
+	var exports Exports
+	_, err = New(component.Options{
+		ID:            "remote.vault.test",
+		Logger:        util.TestLogger(t),
+		OnStateChange: func(e component.Exports) { exports = e.(Exports) },
+	}, args)
+	require.NoError(t, err)
+	require.Equal(t, Exports{
+		Data: map[string]rivertypes.Secret{"key": rivertypes.Secret("value")},
+	}, exports)
+}
+
+func Test_AuthCert_MissingCertificate(t *testing.T) {
+	cfg := `
+		server = "https://localhost:8200"
+		path   = "secret/test"
+
+		auth.cert {}
+	`
+
+	var args Arguments
+	require.EqualError(t, river.Unmarshal([]byte(cfg), &args), "auth.cert requires a client certificate to be configured in tls_config")
+}
+
+func Test_AuthUserPass_LDAP(t *testing.T) {
+	tt := []struct {
+		block, mount string
+	}{
+		{block: "userpass", mount: "userpass"},
+		{block: "ldap", mount: "ldap"},
+		{block: "userpass", mount: "custom-userpass"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.mount, func(t *testing.T) {
+			var rejectLogins atomic.Bool
+
+			stub := newStubVault(t)
+			stub.Handle("auth/"+tc.mount+"/login/agent", func(w http.ResponseWriter, r *http.Request) {
+				var req struct {
+					Password string `json:"password"`
+				}
+				if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Password != "hunter2" || rejectLogins.Load() {
+					w.WriteHeader(http.StatusBadRequest)
+					writeStubResponse(w, map[string]any{"errors": []string{"invalid username or password"}})
+					return
+				}
+				writeStubResponse(w, map[string]any{
+					"auth": map[string]any{"client_token": "login-token"},
+				})
+			})
+			stub.HandleKVv2("secret", "test", map[string]any{"key": "value"})
+
+			newArgs := func(password string) Arguments {
+				cfg := fmt.Sprintf(`
+					server = "%s"
+					path   = "secret/test"
+
+					auth.%s {
+						username   = "agent"
+						password   = "%s"
+						mount_path = "%s"
+					}
+				`, stub.Address(), tc.block, password, tc.mount)
+
+				var args Arguments
+				require.NoError(t, river.Unmarshal([]byte(cfg), &args))
+				return args
+			}
+			opts := component.Options{
+				ID:            "remote.vault.test",
+				Logger:        util.TestLogger(t),
+				OnStateChange: func(e component.Exports) {},
+			}
+
+			// Invalid credentials are reported as an authentication failure.
+			_, err := New(opts, newArgs("wrong"))
+			require.ErrorContains(t, err, "authentication failed")
+
+			// Valid credentials log in successfully.
+			c, err := New(opts, newArgs("hunter2"))
+			require.NoError(t, err)
+			require.Equal(t, component.HealthTypeHealthy, c.CurrentHealth().Health)
+
+			// A rejected login after the component started marks it as
+			// unhealthy.
+			rejectLogins.Store(true)
+			require.NoError(t, c.Update(newArgs("hunter2")))
+
+			health := c.CurrentHealth()
+			require.Equal(t, component.HealthTypeUnhealthy, health.Health)
+			require.Contains(t, health.Message, "authentication failed")
+		})
+	}
+}
@@ -2,7 +2,10 @@ package vault
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 
 	vault "github.com/hashicorp/vault/api"
@@ -11,28 +14,199 @@ import (
 // secretStore abstracts away the details for how a secret is retrieved from a
 // vault.Client.
 type secretStore interface {
-	Read(ctx context.Context, args *Arguments) (*vault.Secret, error)
+	Read(ctx context.Context, path string) (*vault.Secret, error)
 }
 
-// TODO(rfratto): support logical stores.
+// versionedStore is implemented by secret stores which can look up the
+// current version of a secret without reading its data.
+type versionedStore interface {
+	// CurrentVersion returns the current version of the secret at path. ok is
+	// false if the version can't be used to detect changes to the secret.
+	CurrentVersion(ctx context.Context, path string) (version int, ok bool, err error)
+}
+
+const (
+	engineKVv1     = "kv_v1"
+	engineKVv2     = "kv_v2"
+	engineTransit  = "transit"
+	engineDatabase = "database"
+)
 
-type kvStore struct{ c *vault.Client }
+// logicalStore reads secrets verbatim from their path. It is used for secrets
+// engines such as KV v1 which do not rewrite paths.
+type logicalStore struct{ c *vault.Client }
 
-func (ks *kvStore) Read(ctx context.Context, args *Arguments) (*vault.Secret, error) {
+func (ls *logicalStore) Read(ctx context.Context, path string) (*vault.Secret, error) {
+	secret, err := ls.c.Logical().ReadWithContext(ctx, path)
+	if err != nil {
+		return nil, err
+	} else if secret == nil {
+		return nil, fmt.Errorf("%w: at %s", vault.ErrSecretNotFound, path)
+	}
+	return secret, nil
+}
+
+// kvStore reads secrets from a KV v2 secrets engine, where the mount path is
+// the first element of the path. The latest version of secrets is read unless
+// version is set.
+type kvStore struct {
+	c       *vault.Client
+	version int
+}
+
+func (ks *kvStore) Read(ctx context.Context, path string) (*vault.Secret, error) {
 	// Split the path so we know which kv mount we want to use.
-	pathParts := strings.SplitN(args.Path, "/", 2)
+	pathParts := strings.SplitN(path, "/", 2)
 	if len(pathParts) != 2 {
-		return nil, fmt.Errorf("missing mount path in %q", args.Path)
+		return nil, fmt.Errorf("missing mount path in %q", path)
 	}
 
-	kv := ks.c.KVv2(pathParts[0])
-	kvSecret, err := kv.Get(ctx, pathParts[1])
+	var (
+		kv       = ks.c.KVv2(pathParts[0])
+		kvSecret *vault.KVSecret
+		err      error
+	)
+	if ks.version > 0 {
+		kvSecret, err = kv.GetVersion(ctx, pathParts[1], ks.version)
+	} else {
+		kvSecret, err = kv.Get(ctx, pathParts[1])
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	// Vault still returns the metadata of deleted or destroyed versions, but
+	// without any data.
+	if md := kvSecret.VersionMetadata; ks.version > 0 && md != nil {
+		switch {
+		case md.Destroyed:
+			return nil, fmt.Errorf("version %d of secret at %s has been destroyed", ks.version, path)
+		case !md.DeletionTime.IsZero():
+			return nil, fmt.Errorf("version %d of secret at %s has been deleted", ks.version, path)
+		}
+	}
+
 	// kvSecret.Data contains unwrapped data. Let's assign that to the raw secret
 	// and return it. This is a bit of a hack, but should work just fine.
 	kvSecret.Raw.Data = kvSecret.Data
 	return kvSecret.Raw, nil
 }
+
+// CurrentVersion reads the metadata of the secret at path. Pinned versions
+// and deleted or destroyed current versions always go through a full read,
+// so that the deletion is detected.
+func (ks *kvStore) CurrentVersion(ctx context.Context, path string) (int, bool, error) {
+	if ks.version > 0 {
+		return 0, false, nil
+	}
+
+	pathParts := strings.SplitN(path, "/", 2)
+	if len(pathParts) != 2 {
+		return 0, false, fmt.Errorf("missing mount path in %q", path)
+	}
+
+	md, err := ks.c.KVv2(pathParts[0]).GetMetadata(ctx, pathParts[1])
+	if err != nil {
+		return 0, false, err
+	}
+
+	// Deleting the current version doesn't change current_version.
+	current, ok := md.Versions[strconv.Itoa(md.CurrentVersion)]
+	if !ok || current.Destroyed || !current.DeletionTime.IsZero() {
+		return 0, false, nil
+	}
+	return md.CurrentVersion, true, nil
+}
+
+// List returns the paths of every secret under the folder at path, relative
+// to path. Subfolders are listed recursively from the metadata of the KV v2
+// secrets engine. An error is returned if more than max secrets are found.
+func (ks *kvStore) List(ctx context.Context, path string, max int) ([]string, error) {
+	pathParts := strings.SplitN(strings.Trim(path, "/"), "/", 2)
+	metadataPath := pathParts[0] + "/metadata"
+	if len(pathParts) == 2 {
+		metadataPath += "/" + pathParts[1]
+	}
+
+	var (
+		secrets []string
+		folders = []string{""} // Folders left to list, relative to path.
+	)
+	for len(folders) > 0 {
+		folder := folders[0]
+		folders = folders[1:]
+
+		list, err := ks.c.Logical().ListWithContext(ctx, metadataPath+"/"+folder)
+		if err != nil {
+			return nil, err
+		} else if list == nil {
+			// Empty folders aren't found.
+			continue
+		}
+
+		keys, _ := list.Data["keys"].([]interface{})
+		for _, key := range keys {
+			name, ok := key.(string)
+			if !ok {
+				continue
+			}
+			if strings.HasSuffix(name, "/") {
+				folders = append(folders, folder+name)
+				continue
+			}
+
+			secrets = append(secrets, folder+name)
+			if len(secrets) > max {
+				return nil, fmt.Errorf("found more than %d secrets under %s", max, path)
+			}
+		}
+	}
+
+	sort.Strings(secrets)
+	return secrets, nil
+}
+
+// transitStore decrypts a ciphertext with a key of a transit secrets engine,
+// where path is the mount path of the engine. The decrypted plaintext is
+// returned in the plaintext key of the secret.
+type transitStore struct {
+	c          *vault.Client
+	key        string
+	ciphertext string
+}
+
+func (ts *transitStore) Read(ctx context.Context, path string) (*vault.Secret, error) {
+	decryptPath := strings.TrimSuffix(path, "/") + "/decrypt/" + ts.key
+	secret, err := ts.c.Logical().WriteWithContext(ctx, decryptPath, map[string]interface{}{
+		"ciphertext": ts.ciphertext,
+	})
+	if err != nil {
+		return nil, err
+	} else if secret == nil {
+		return nil, fmt.Errorf("no data returned when decrypting with %s", decryptPath)
+	}
+
+	encoded, ok := secret.Data["plaintext"].(string)
+	if !ok {
+		return nil, fmt.Errorf("no plaintext returned when decrypting with %s", decryptPath)
+	}
+	plaintext, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("decoding plaintext returned by %s: %w", decryptPath, err)
+	}
+
+	secret.Data = map[string]interface{}{"plaintext": string(plaintext)}
+	return secret, nil
+}
+
+// databaseStore requests dynamic credentials for a role of a database secrets
+// engine, where path is the mount path of the engine. Every read returns new
+// credentials with their own lease.
+type databaseStore struct {
+	c    *vault.Client
+	role string
+}
+
+func (ds *databaseStore) Read(ctx context.Context, path string) (*vault.Secret, error) {
+	return (&logicalStore{c: ds.c}).Read(ctx, strings.TrimSuffix(path, "/")+"/creds/"+ds.role)
+}
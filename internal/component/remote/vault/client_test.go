@@ -0,0 +1,873 @@
+package vault
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/internal/component"
+	"github.com/grafana/agent/internal/flow/componenttest"
+	"github.com/grafana/agent/internal/util"
+	"github.com/grafana/river"
+	"github.com/grafana/river/rivertypes"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_SecretStores(t *testing.T) {
+	stub := newStubVault(t)
+	stub.HandleKVv2("secret", "test", map[string]any{"key": "v2-value"})
+	stub.Handle("kv/test", func(w http.ResponseWriter, r *http.Request) {
+		writeStubResponse(w, map[string]any{
+			"data": map[string]any{"key": "v1-value"},
+		})
+	})
+
+	tt := []struct {
+		name   string
+		engine string
+		path   string
+		expect string
+	}{
+		{name: "default", engine: "", path: "secret/test", expect: "v2-value"},
+		{name: "kv_v2", engine: `engine = "kv_v2"`, path: "secret/test", expect: "v2-value"},
+		{name: "kv_v1", engine: `engine = "kv_v1"`, path: "kv/test", expect: "v1-value"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := fmt.Sprintf(`
+				server = "%s"
+				path   = "%s"
+				%s
+
+				auth.token {
+					token = "token"
+				}
+			`, stub.Address(), tc.path, tc.engine)
+
+			var args Arguments
+			require.NoError(t, river.Unmarshal([]byte(cfg), &args))
+
+			cli, err := args.client()
+			require.NoError(t, err)
+			cli.SetToken("token")
+
+			secret, err := args.secretStore(cli).Read(context.Background(), args.Path)
+			require.NoError(t, err)
+			require.Equal(t, map[string]any{"key": tc.expect}, secret.Data)
+		})
+	}
+}
+
+func Test_PinnedVersion(t *testing.T) {
+	var (
+		versionsMut sync.Mutex
+		versions    = map[string]map[string]any{
+			"1": {"data": map[string]any{"key": "v1"}, "metadata": map[string]any{"version": 1}},
+			"2": {"data": map[string]any{"key": "v2"}, "metadata": map[string]any{"version": 2}},
+		}
+	)
+
+	stub := newStubVault(t)
+	stub.Handle("secret/data/test", func(w http.ResponseWriter, r *http.Request) {
+		versionsMut.Lock()
+		defer versionsMut.Unlock()
+
+		version := r.URL.Query().Get("version")
+		if version == "" {
+			version = "2"
+		}
+
+		resp := versions[version]
+		if resp["data"] == nil {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		writeStubResponse(w, map[string]any{"data": resp})
+	})
+
+	newArgs := func(version string) Arguments {
+		cfg := fmt.Sprintf(`
+			server = "%s"
+			path   = "secret/test"
+			%s
+
+			reread_frequency = "50ms"
+
+			auth.token {
+				token = "token"
+			}
+		`, stub.Address(), version)
+
+		var args Arguments
+		require.NoError(t, river.Unmarshal([]byte(cfg), &args))
+		return args
+	}
+
+	var (
+		exportsMut sync.Mutex
+		exports    Exports
+	)
+	opts := component.Options{
+		ID:     "remote.vault.test",
+		Logger: util.TestLogger(t),
+		OnStateChange: func(e component.Exports) {
+			exportsMut.Lock()
+			defer exportsMut.Unlock()
+			exports = e.(Exports)
+		},
+	}
+	getExports := func() Exports {
+		exportsMut.Lock()
+		defer exportsMut.Unlock()
+		return exports
+	}
+
+	// Without a version, the latest version is read.
+	_, err := New(opts, newArgs(""))
+	require.NoError(t, err)
+	require.Equal(t, rivertypes.Secret("v2"), getExports().Data["key"])
+
+	// A pinned version is read even if it's not the latest one.
+	c, err := New(opts, newArgs("version = 1"))
+	require.NoError(t, err)
+	require.Equal(t, rivertypes.Secret("v1"), getExports().Data["key"])
+
+	go func() {
+		require.NoError(t, c.Run(componenttest.TestContext(t)))
+	}()
+
+	// Destroying the pinned version is reported on the next reread.
+	versionsMut.Lock()
+	versions["1"] = map[string]any{"data": nil, "metadata": map[string]any{"version": 1, "destroyed": true}}
+	versionsMut.Unlock()
+
+	require.Eventually(t, func() bool {
+		h := c.CurrentHealth()
+		return h.Health == component.HealthTypeUnhealthy &&
+			h.Message == "failed to retrieve token: version 1 of secret at secret/test has been destroyed"
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func Test_UnchangedVersion(t *testing.T) {
+	stub := newStubVault(t)
+	secret := stub.HandleKVv2("secret", "test", map[string]any{"key": "v1"})
+
+	cfg := fmt.Sprintf(`
+		server = "%s"
+		path   = "secret/test"
+
+		reread_frequency = "20ms"
+
+		auth.token {
+			token = "token"
+		}
+	`, stub.Address())
+
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(cfg), &args))
+
+	var (
+		exportsMut sync.Mutex
+		exports    []Exports
+	)
+	getExports := func() []Exports {
+		exportsMut.Lock()
+		defer exportsMut.Unlock()
+		return append([]Exports(nil), exports...)
+	}
+
+	c, err := New(component.Options{
+		ID:     "remote.vault.test",
+		Logger: util.TestLogger(t),
+		OnStateChange: func(e component.Exports) {
+			exportsMut.Lock()
+			defer exportsMut.Unlock()
+			exports = append(exports, e.(Exports))
+		},
+	}, args)
+	require.NoError(t, err)
+	require.Len(t, getExports(), 1)
+
+	go func() {
+		require.NoError(t, c.Run(componenttest.TestContext(t)))
+	}()
+
+	// Rereads of an unchanged version neither read the secret again nor update
+	// the exports.
+	time.Sleep(200 * time.Millisecond)
+	require.Equal(t, 1, secret.Reads())
+	require.Len(t, getExports(), 1)
+
+	// A new version is read and exported on the next reread.
+	secret.Set(map[string]any{"key": "v2"})
+	require.Eventually(t, func() bool {
+		exports := getExports()
+		return len(exports) == 2 && exports[1].Data["key"] == rivertypes.Secret("v2")
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, 2, secret.Reads())
+}
+
+func Test_DeletedCurrentVersion(t *testing.T) {
+	stub := newStubVault(t)
+	secret := stub.HandleKVv2("secret", "test", map[string]any{"key": "v1"})
+
+	cfg := fmt.Sprintf(`
+		server = "%s"
+		path   = "secret/test"
+
+		reread_frequency = "20ms"
+
+		auth.token {
+			token = "token"
+		}
+	`, stub.Address())
+
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(cfg), &args))
+
+	var (
+		exportsMut sync.Mutex
+		exports    Exports
+	)
+	c, err := New(component.Options{
+		ID:     "remote.vault.test",
+		Logger: util.TestLogger(t),
+		OnStateChange: func(e component.Exports) {
+			exportsMut.Lock()
+			defer exportsMut.Unlock()
+			exports = e.(Exports)
+		},
+	}, args)
+	require.NoError(t, err)
+
+	go func() {
+		require.NoError(t, c.Run(componenttest.TestContext(t)))
+	}()
+
+	// Deleting the current version doesn't change the current version in the
+	// metadata of the secret, but the secret is read again and its data is
+	// no longer exported.
+	secret.Delete()
+	require.Eventually(t, func() bool {
+		exportsMut.Lock()
+		defer exportsMut.Unlock()
+		return len(exports.Data) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func Test_InvalidVersion(t *testing.T) {
+	tt := []struct {
+		name      string
+		cfg       string
+		expectErr string
+	}{
+		{name: "negative", cfg: `path = "secret/test"
+			version = -1`, expectErr: "version must not be negative"},
+		{name: "kv_v1", cfg: `path = "kv/test"
+			engine = "kv_v1"
+			version = 1`, expectErr: "version can only be used with the kv_v2 engine"},
+		{name: "paths", cfg: `paths = ["secret/a", "secret/b"]
+			version = 1`, expectErr: "version can't be used with paths"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := fmt.Sprintf(`
+				server = "http://localhost:8200"
+				%s
+
+				auth.token {
+					token = "token"
+				}
+			`, tc.cfg)
+
+			var args Arguments
+			require.EqualError(t, river.Unmarshal([]byte(cfg), &args), tc.expectErr)
+		})
+	}
+}
+
+func Test_InvalidEngine(t *testing.T) {
+	cfg := `
+		server = "http://localhost:8200"
+		path   = "secret/test"
+		engine = "kv_v3"
+
+		auth.token {
+			token = "token"
+		}
+	`
+
+	var args Arguments
+	require.EqualError(t, river.Unmarshal([]byte(cfg), &args), `unrecognized engine "kv_v3", expected one of kv_v1,kv_v2,transit,database`)
+}
+
+func Test_Transit(t *testing.T) {
+	var (
+		plaintextMut sync.Mutex
+		plaintext    = "hunter2"
+	)
+	stub := newStubVault(t)
+	stub.Handle("transit/decrypt/my-key", func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		if r.Method != http.MethodPut && r.Method != http.MethodPost || req["ciphertext"] != "vault:v1:abcd" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		plaintextMut.Lock()
+		defer plaintextMut.Unlock()
+		writeStubResponse(w, map[string]any{
+			"data": map[string]any{"plaintext": base64.StdEncoding.EncodeToString([]byte(plaintext))},
+		})
+	})
+
+	cfg := fmt.Sprintf(`
+		server     = "%s"
+		path       = "transit"
+		engine     = "transit"
+		key        = "my-key"
+		ciphertext = "vault:v1:abcd"
+
+		reread_frequency = "50ms"
+
+		auth.token {
+			token = "token"
+		}
+	`, stub.Address())
+
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(cfg), &args))
+
+	var (
+		exportsMut sync.Mutex
+		exports    Exports
+	)
+	getExports := func() Exports {
+		exportsMut.Lock()
+		defer exportsMut.Unlock()
+		return exports
+	}
+
+	c, err := New(component.Options{
+		ID:     "remote.vault.test",
+		Logger: util.TestLogger(t),
+		OnStateChange: func(e component.Exports) {
+			exportsMut.Lock()
+			defer exportsMut.Unlock()
+			exports = e.(Exports)
+		},
+	}, args)
+	require.NoError(t, err)
+	require.Equal(t, map[string]rivertypes.Secret{"plaintext": rivertypes.Secret("hunter2")}, getExports().Data)
+
+	go func() {
+		require.NoError(t, c.Run(componenttest.TestContext(t)))
+	}()
+
+	// The ciphertext is decrypted again on every reread.
+	plaintextMut.Lock()
+	plaintext = "hunter3"
+	plaintextMut.Unlock()
+
+	require.Eventually(t, func() bool {
+		return getExports().Data["plaintext"] == rivertypes.Secret("hunter3")
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func Test_InvalidTransit(t *testing.T) {
+	tt := []struct {
+		name      string
+		cfg       string
+		expectErr string
+	}{
+		{name: "missing key", cfg: `path = "transit"
+			engine = "transit"
+			ciphertext = "vault:v1:abcd"`, expectErr: "the transit engine requires key and ciphertext to be set"},
+		{name: "missing ciphertext", cfg: `path = "transit"
+			engine = "transit"
+			key = "my-key"`, expectErr: "the transit engine requires key and ciphertext to be set"},
+		{name: "paths", cfg: `paths = ["transit"]
+			engine = "transit"
+			key = "my-key"
+			ciphertext = "vault:v1:abcd"`, expectErr: "the transit engine can't be used with paths"},
+		{name: "kv_v2", cfg: `path = "secret/test"
+			key = "my-key"`, expectErr: "key and ciphertext can only be used with the transit engine"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := fmt.Sprintf(`
+				server = "http://localhost:8200"
+				%s
+
+				auth.token {
+					token = "token"
+				}
+			`, tc.cfg)
+
+			var args Arguments
+			require.EqualError(t, river.Unmarshal([]byte(cfg), &args), tc.expectErr)
+		})
+	}
+}
+
+func Test_Database(t *testing.T) {
+	var requests atomic.Int64
+	stub := newStubVault(t)
+	stub.Handle("database/creds/readonly", func(w http.ResponseWriter, r *http.Request) {
+		n := requests.Add(1)
+		writeStubResponse(w, map[string]any{
+			"lease_id":       fmt.Sprintf("database/creds/readonly/%d", n),
+			"lease_duration": 1,
+			"renewable":      false,
+			"data": map[string]any{
+				"username": fmt.Sprintf("user-%d", n),
+				"password": fmt.Sprintf("password-%d", n),
+			},
+		})
+	})
+
+	cfg := fmt.Sprintf(`
+		server = "%s"
+		path   = "database"
+		engine = "database"
+		role   = "readonly"
+
+		auth.token {
+			token = "token"
+		}
+	`, stub.Address())
+
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(cfg), &args))
+
+	var (
+		exportsMut sync.Mutex
+		exports    Exports
+	)
+	getExports := func() Exports {
+		exportsMut.Lock()
+		defer exportsMut.Unlock()
+		return exports
+	}
+
+	c, err := New(component.Options{
+		ID:     "remote.vault.test",
+		Logger: util.TestLogger(t),
+		OnStateChange: func(e component.Exports) {
+			exportsMut.Lock()
+			defer exportsMut.Unlock()
+			exports = e.(Exports)
+		},
+	}, args)
+	require.NoError(t, err)
+	require.Equal(t, map[string]rivertypes.Secret{
+		"username": rivertypes.Secret("user-1"),
+		"password": rivertypes.Secret("password-1"),
+	}, getExports().Data)
+
+	go func() {
+		require.NoError(t, c.Run(componenttest.TestContext(t)))
+	}()
+
+	// New credentials are requested before the lease of the current ones ends.
+	require.Eventually(t, func() bool {
+		return getExports().Data["username"] == rivertypes.Secret("user-2")
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, rivertypes.Secret("password-2"), getExports().Data["password"])
+}
+
+func Test_InvalidDatabase(t *testing.T) {
+	tt := []struct {
+		name      string
+		cfg       string
+		expectErr string
+	}{
+		{name: "missing role", cfg: `path = "database"
+			engine = "database"`, expectErr: "the database engine requires role to be set"},
+		{name: "paths", cfg: `paths = ["database"]
+			engine = "database"
+			role = "readonly"`, expectErr: "the database engine can't be used with paths"},
+		{name: "kv_v2", cfg: `path = "secret/test"
+			role = "readonly"`, expectErr: "role can only be used with the database engine"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := fmt.Sprintf(`
+				server = "http://localhost:8200"
+				%s
+
+				auth.token {
+					token = "token"
+				}
+			`, tc.cfg)
+
+			var args Arguments
+			require.EqualError(t, river.Unmarshal([]byte(cfg), &args), tc.expectErr)
+		})
+	}
+}
+
+func Test_InvalidRereadJitter(t *testing.T) {
+	for _, jitter := range []string{"-0.1", "1", "1.5"} {
+		cfg := fmt.Sprintf(`
+			server        = "http://localhost:8200"
+			path          = "secret/test"
+			reread_jitter = %s
+
+			auth.token {
+				token = "token"
+			}
+		`, jitter)
+
+		var args Arguments
+		require.EqualError(t, river.Unmarshal([]byte(cfg), &args), "reread_jitter must be at least 0 and less than 1", "jitter %s", jitter)
+	}
+}
+
+func Test_InvalidRereadFrequency(t *testing.T) {
+	cfg := `
+		server           = "http://localhost:8200"
+		path             = "secret/test"
+		reread_frequency = "-1m"
+
+		auth.token {
+			token = "token"
+		}
+	`
+
+	var args Arguments
+	require.EqualError(t, river.Unmarshal([]byte(cfg), &args), "reread_frequency must not be negative")
+}
+
+func Test_Namespace(t *testing.T) {
+	var (
+		ctx = componenttest.TestContext(t)
+		l   = util.TestLogger(t)
+	)
+
+	var (
+		namespacesMut sync.Mutex
+		namespaces    = map[string]string{}
+	)
+	recordNamespace := func(r *http.Request) {
+		namespacesMut.Lock()
+		defer namespacesMut.Unlock()
+		namespaces[r.URL.Path] = r.Header.Get("X-Vault-Namespace")
+	}
+
+	stub := newStubVault(t)
+	stub.Handle("auth/userpass/login/agent", func(w http.ResponseWriter, r *http.Request) {
+		recordNamespace(r)
+		writeStubResponse(w, map[string]any{
+			"auth": map[string]any{"client_token": "userpass-token"},
+		})
+	})
+	stub.Handle("secret/data/test", func(w http.ResponseWriter, r *http.Request) {
+		recordNamespace(r)
+		writeStubResponse(w, map[string]any{
+			"data": map[string]any{
+				"data":     map[string]any{"key": "value"},
+				"metadata": map[string]any{"version": 1},
+			},
+		})
+	})
+
+	cfg := fmt.Sprintf(`
+		server    = "%s"
+		namespace = "team-a/"
+		path      = "secret/test"
+
+		auth.userpass {
+			username = "agent"
+			password = "password"
+		}
+	`, stub.Address())
+
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(cfg), &args))
+
+	ctrl, err := componenttest.NewControllerFromID(l, "remote.vault")
+	require.NoError(t, err)
+
+	go func() {
+		require.NoError(t, ctrl.Run(ctx, args))
+	}()
+	require.NoError(t, ctrl.WaitRunning(time.Minute))
+	require.NoError(t, ctrl.WaitExports(time.Minute))
+
+	namespacesMut.Lock()
+	defer namespacesMut.Unlock()
+	require.Equal(t, map[string]string{
+		"/v1/auth/userpass/login/agent": "team-a/",
+		"/v1/secret/data/test":          "team-a/",
+	}, namespaces)
+}
+
+func Test_UserAgent(t *testing.T) {
+	var (
+		userAgentsMut sync.Mutex
+		userAgents    = map[string]string{}
+	)
+	recordUserAgent := func(r *http.Request) {
+		userAgentsMut.Lock()
+		defer userAgentsMut.Unlock()
+		userAgents[r.URL.Path] = r.Header.Get("User-Agent")
+	}
+
+	stub := newStubVault(t)
+	stub.Handle("auth/userpass/login/agent", func(w http.ResponseWriter, r *http.Request) {
+		recordUserAgent(r)
+		writeStubResponse(w, map[string]any{
+			"auth": map[string]any{"client_token": "userpass-token"},
+		})
+	})
+	secret := stub.HandleKVv2("secret", "test", map[string]any{"key": "value"})
+	stub.Handle("secret/data/other", func(w http.ResponseWriter, r *http.Request) {
+		recordUserAgent(r)
+		secret.ServeHTTP(w, r)
+	})
+
+	for _, tc := range []struct {
+		name      string
+		userAgent string
+		expect    string
+	}{
+		{name: "default", expect: defaultUserAgent},
+		{name: "custom", userAgent: `user_agent = "agent-1"`, expect: "agent-1"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := fmt.Sprintf(`
+				server = "%s"
+				path   = "secret/other"
+				%s
+
+				auth.userpass {
+					username = "agent"
+					password = "password"
+				}
+			`, stub.Address(), tc.userAgent)
+
+			var args Arguments
+			require.NoError(t, river.Unmarshal([]byte(cfg), &args))
+
+			_, err := New(component.Options{
+				ID:            "remote.vault.test",
+				Logger:        util.TestLogger(t),
+				OnStateChange: func(e component.Exports) {},
+			}, args)
+			require.NoError(t, err)
+
+			userAgentsMut.Lock()
+			defer userAgentsMut.Unlock()
+			require.Equal(t, map[string]string{
+				"/v1/auth/userpass/login/agent": tc.expect,
+				"/v1/secret/data/other":         tc.expect,
+			}, userAgents)
+		})
+	}
+}
+
+func Test_RequestTimeout(t *testing.T) {
+	stub := newStubVault(t)
+	stub.Handle("secret/data/test", func(w http.ResponseWriter, r *http.Request) {
+		// Hang until the client gives up.
+		<-r.Context().Done()
+	})
+
+	cfg := fmt.Sprintf(`
+		server          = "%s"
+		path            = "secret/test"
+		request_timeout = "100ms"
+
+		auth.token {
+			token = "token"
+		}
+	`, stub.Address())
+
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(cfg), &args))
+
+	start := time.Now()
+	_, err := New(component.Options{
+		ID:            "remote.vault.test",
+		Logger:        util.TestLogger(t),
+		OnStateChange: func(e component.Exports) {},
+	}, args)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Less(t, time.Since(start), 5*time.Second)
+}
+
+func Test_TLSConfig(t *testing.T) {
+	certs := newTestCertificates(t)
+
+	serverCert, err := tls.LoadX509KeyPair(certs.ServerCertFile, certs.ServerKeyFile)
+	require.NoError(t, err)
+
+	stub := newStubVaultTLS(t, &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientCAs:    certs.Pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	})
+	stub.HandleKVv2("secret", "test", map[string]any{"key": "value"})
+
+	t.Run("client certificate", func(t *testing.T) {
+		cfg := fmt.Sprintf(`
+			server = "%s"
+			path   = "secret/test"
+
+			tls_config {
+				ca_file     = "%s"
+				cert_file   = "%s"
+				key_file    = "%s"
+				server_name = "localhost"
+			}
+
+			auth.token {
+				token = "token"
+			}
+		`, stub.Address(), certs.CAFile, certs.ClientCertFile, certs.ClientKeyFile)
+
+		var args Arguments
+		require.NoError(t, river.Unmarshal([]byte(cfg), &args))
+
+		var exports Exports
+		_, err := New(component.Options{
+			ID:            "remote.vault.test",
+			Logger:        util.TestLogger(t),
+			OnStateChange: func(e component.Exports) { exports = e.(Exports) },
+		}, args)
+		require.NoError(t, err)
+		require.Equal(t, Exports{
+			Data: map[string]rivertypes.Secret{"key": rivertypes.Secret("value")},
+		}, exports)
+	})
+
+	t.Run("no client certificate", func(t *testing.T) {
+		cfg := fmt.Sprintf(`
+			server = "%s"
+			path   = "secret/test"
+
+			tls_config {
+				ca_file     = "%s"
+				server_name = "localhost"
+			}
+
+			client_options {
+				max_retries = 0
+			}
+
+			auth.token {
+				token = "token"
+			}
+		`, stub.Address(), certs.CAFile)
+
+		var args Arguments
+		require.NoError(t, river.Unmarshal([]byte(cfg), &args))
+
+		_, err := New(component.Options{
+			ID:            "remote.vault.test",
+			Logger:        util.TestLogger(t),
+			OnStateChange: func(e component.Exports) {},
+		}, args)
+		require.Error(t, err)
+	})
+}
+
+// testCertificates holds paths to a CA and certificates signed by it.
+type testCertificates struct {
+	Pool *x509.CertPool
+
+	CAFile                        string
+	ServerCertFile, ServerKeyFile string
+	ClientCertFile, ClientKeyFile string
+}
+
+// newTestCertificates generates a CA along with a server certificate for
+// localhost and a client certificate, writing them into a temporary
+// directory.
+func newTestCertificates(t *testing.T) testCertificates {
+	t.Helper()
+
+	var (
+		dir   = t.TempDir()
+		certs = testCertificates{Pool: x509.NewCertPool()}
+	)
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	caCert, err := x509.ParseCertificate(caDER)
+	require.NoError(t, err)
+	certs.Pool.AddCert(caCert)
+
+	certs.CAFile = filepath.Join(dir, "ca.pem")
+	writePEM(t, certs.CAFile, "CERTIFICATE", caDER)
+
+	issue := func(name string, serial int64, usage x509.ExtKeyUsage) (certFile, keyFile string) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: name},
+			DNSNames:     []string{"localhost"},
+			IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+		require.NoError(t, err)
+		keyDER, err := x509.MarshalECPrivateKey(key)
+		require.NoError(t, err)
+
+		certFile = filepath.Join(dir, name+".pem")
+		keyFile = filepath.Join(dir, name+"-key.pem")
+		writePEM(t, certFile, "CERTIFICATE", der)
+		writePEM(t, keyFile, "EC PRIVATE KEY", keyDER)
+		return certFile, keyFile
+	}
+
+	certs.ServerCertFile, certs.ServerKeyFile = issue("server", 2, x509.ExtKeyUsageServerAuth)
+	certs.ClientCertFile, certs.ClientKeyFile = issue("client", 3, x509.ExtKeyUsageClientAuth)
+	return certs
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	require.NoError(t, os.WriteFile(path, data, 0600))
+}
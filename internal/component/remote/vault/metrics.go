@@ -3,24 +3,29 @@ package vault
 import "github.com/prometheus/client_golang/prometheus"
 
 type metrics struct {
-	authTotal       prometheus.Counter
-	secretReadTotal prometheus.Counter
+	authTotal       *prometheus.CounterVec
+	secretReadTotal *prometheus.CounterVec
 
 	authLeaseRenewalTotal   prometheus.Counter
 	secretLeaseRenewalTotal prometheus.Counter
+
+	secretLeaseTTL prometheus.Gauge
+
+	tokenTTL           prometheus.Gauge
+	tokenRenewalsTotal *prometheus.CounterVec
 }
 
 func newMetrics(r prometheus.Registerer) *metrics {
 	var m metrics
 
-	m.authTotal = prometheus.NewCounter(prometheus.CounterOpts{
+	m.authTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Name: "remote_vault_auth_total",
-		Help: "Total number of times this component authenticated to Vault",
-	})
-	m.secretReadTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Help: "Total number of times this component attempted to authenticate to Vault",
+	}, []string{"success"})
+	m.secretReadTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Name: "remote_vault_secret_reads_total",
-		Help: "Total number of times the secret was read from Vault",
-	})
+		Help: "Total number of times this component attempted to read the secret from Vault",
+	}, []string{"success"})
 
 	m.authLeaseRenewalTotal = prometheus.NewCounter(prometheus.CounterOpts{
 		Name: "remote_vault_auth_lease_renewal_total",
@@ -31,6 +36,20 @@ func newMetrics(r prometheus.Registerer) *metrics {
 		Help: "Total number of times this component renewed its secret lease",
 	})
 
+	m.secretLeaseTTL = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "remote_vault_secret_lease_ttl_seconds",
+		Help: "Remaining lease duration of the secret in seconds, as of the latest read or renewal",
+	})
+
+	m.tokenTTL = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "remote_vault_token_ttl_seconds",
+		Help: "Remaining TTL of the auth token in seconds, as of the latest login or renewal",
+	})
+	m.tokenRenewalsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "remote_vault_token_renewals_total",
+		Help: "Total number of times this component attempted to renew its auth token",
+	}, []string{"result"})
+
 	if r != nil {
 		r.MustRegister(
 			m.authTotal,
@@ -38,6 +57,11 @@ func newMetrics(r prometheus.Registerer) *metrics {
 
 			m.authLeaseRenewalTotal,
 			m.secretLeaseRenewalTotal,
+
+			m.secretLeaseTTL,
+
+			m.tokenTTL,
+			m.tokenRenewalsTotal,
 		)
 	}
 	return &m
@@ -0,0 +1,198 @@
+package vault
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/internal/component"
+	"github.com/grafana/agent/internal/util"
+	"github.com/grafana/river/rivertypes"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+func Test_Metrics(t *testing.T) {
+	var failReads atomic.Bool
+
+	stub := newStubVault(t)
+	stub.Handle("secret/data/test", func(w http.ResponseWriter, r *http.Request) {
+		if failReads.Load() {
+			http.Error(w, `{"errors":["permission denied"]}`, http.StatusForbidden)
+			return
+		}
+		writeStubResponse(w, map[string]any{
+			"lease_duration": 3600,
+			"data": map[string]any{
+				"data":     map[string]any{"key": "value"},
+				"metadata": map[string]any{"version": 1},
+			},
+		})
+	})
+
+	args := DefaultArguments
+	args.Server = stub.Address()
+	args.Path = "secret/test"
+	args.RereadFrequency = 50 * time.Millisecond
+	args.Auth = []AuthArguments{{AuthToken: &AuthToken{Token: rivertypes.Secret("token")}}}
+
+	reg := prometheus.NewRegistry()
+	c, err := New(component.Options{
+		ID:            "remote.vault.test",
+		Logger:        util.TestLogger(t),
+		Registerer:    reg,
+		OnStateChange: func(e component.Exports) {},
+	}, args)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runDone := make(chan struct{})
+	go func() {
+		defer close(runDone)
+		require.NoError(t, c.Run(ctx))
+	}()
+
+	require.Eventually(t, func() bool {
+		return gatheredValue(t, reg, "remote_vault_secret_reads_total", "true") >= 2
+	}, 5*time.Second, 10*time.Millisecond, "secret was never reread")
+	require.Equal(t, float64(1), gatheredValue(t, reg, "remote_vault_auth_total", "true"))
+	require.Equal(t, float64(3600), gatheredValue(t, reg, "remote_vault_secret_lease_ttl_seconds", ""))
+
+	failReads.Store(true)
+	require.Eventually(t, func() bool {
+		return gatheredValue(t, reg, "remote_vault_secret_reads_total", "false") >= 1
+	}, 5*time.Second, 10*time.Millisecond, "failed reads were never counted")
+
+	// The lease TTL should be cleared once the component stops.
+	cancel()
+	<-runDone
+	require.Equal(t, float64(0), gatheredValue(t, reg, "remote_vault_secret_lease_ttl_seconds", ""))
+}
+
+func Test_TokenMetrics(t *testing.T) {
+	// Every login and renewal returns a shorter TTL, like a token approaching
+	// its max TTL.
+	var ttl atomic.Int64
+	ttl.Store(3)
+	nextTTL := func() int64 {
+		return max(ttl.Dec()+1, 1)
+	}
+
+	stub := newStubVault(t)
+	stub.Handle("auth/userpass/login/agent", func(w http.ResponseWriter, r *http.Request) {
+		writeStubResponse(w, map[string]any{
+			"auth": map[string]any{
+				"client_token":   "login-token",
+				"renewable":      true,
+				"lease_duration": nextTTL(),
+			},
+		})
+	})
+	stub.Handle("auth/token/renew-self", func(w http.ResponseWriter, r *http.Request) {
+		writeStubResponse(w, map[string]any{
+			"auth": map[string]any{
+				"client_token":   "login-token",
+				"renewable":      true,
+				"lease_duration": nextTTL(),
+			},
+		})
+	})
+	stub.HandleKVv2("secret", "test", map[string]any{"key": "value"})
+
+	args := DefaultArguments
+	args.Server = stub.Address()
+	args.Path = "secret/test"
+	args.Auth = []AuthArguments{{AuthUserPass: &AuthUserPass{
+		Username:  "agent",
+		Password:  rivertypes.Secret("hunter2"),
+		MountPath: "userpass",
+	}}}
+
+	reg := prometheus.NewRegistry()
+	c, err := New(component.Options{
+		ID:            "remote.vault.test",
+		Logger:        util.TestLogger(t),
+		Registerer:    reg,
+		OnStateChange: func(e component.Exports) {},
+	}, args)
+	require.NoError(t, err)
+	require.Equal(t, float64(3), gatheredValue(t, reg, "remote_vault_token_ttl_seconds", ""))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runDone := make(chan struct{})
+	go func() {
+		defer close(runDone)
+		require.NoError(t, c.Run(ctx))
+	}()
+
+	require.Eventually(t, func() bool {
+		return gatheredRenewals(t, reg, "success") >= 1
+	}, 10*time.Second, 10*time.Millisecond, "token was never renewed")
+	require.Less(t, gatheredValue(t, reg, "remote_vault_token_ttl_seconds", ""), float64(3))
+
+	// The token TTL should be cleared once the component stops.
+	cancel()
+	<-runDone
+	require.Equal(t, float64(0), gatheredValue(t, reg, "remote_vault_token_ttl_seconds", ""))
+}
+
+// gatheredRenewals returns the number of token renewals in reg with the given
+// result.
+func gatheredRenewals(t *testing.T, reg prometheus.Gatherer, result string) float64 {
+	t.Helper()
+
+	families, err := reg.Gather()
+	require.NoError(t, err)
+
+	for _, family := range families {
+		if family.GetName() != "remote_vault_token_renewals_total" {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			if hasLabel(m, "result", result) {
+				return m.GetCounter().GetValue()
+			}
+		}
+	}
+	return 0
+}
+
+// gatheredValue returns the value of the metric called name in reg. If
+// success is not empty, the value of the series with the matching success
+// label is returned instead.
+func gatheredValue(t *testing.T, reg prometheus.Gatherer, name, success string) float64 {
+	t.Helper()
+
+	families, err := reg.Gather()
+	require.NoError(t, err)
+
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			if success != "" && !hasLabel(m, "success", success) {
+				continue
+			}
+			switch {
+			case m.GetCounter() != nil:
+				return m.GetCounter().GetValue()
+			case m.GetGauge() != nil:
+				return m.GetGauge().GetValue()
+			}
+		}
+	}
+	return 0
+}
+
+func hasLabel(m *dto.Metric, name, value string) bool {
+	for _, l := range m.GetLabel() {
+		if l.GetName() == name && l.GetValue() == value {
+			return true
+		}
+	}
+	return false
+}
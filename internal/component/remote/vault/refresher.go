@@ -3,12 +3,15 @@ package vault
 import (
 	"context"
 	"fmt"
+	"math/rand"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/go-kit/log"
 	"github.com/grafana/agent/internal/component"
 	"github.com/grafana/agent/internal/flow/logging/level"
+	"github.com/grafana/dskit/backoff"
 	vault "github.com/hashicorp/vault/api"
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -25,49 +28,82 @@ type tokenManager struct {
 	refreshTicker *ticker
 	getter        getTokenFunc
 	onStateChange chan struct{} // Written to when cli or token changes.
+	onFailure     chan struct{} // Written to when retrieving a token fails.
+	onReset       chan struct{} // Written to when refreshTicker is reset.
 
-	readCounter    prometheus.Counter
+	readCounter    *prometheus.CounterVec
 	refreshCounter prometheus.Counter
+	renewalResults *prometheus.CounterVec // May be nil.
+	leaseTTL       prometheus.Gauge       // May be nil.
 
-	mut   sync.RWMutex
-	cli   *vault.Client
-	token *vault.Secret
+	mut         sync.RWMutex
+	cli         *vault.Client
+	token       *vault.Secret
+	lastErr     error // Error from the latest retrieval.
+	expired     bool  // True when the token expired and couldn't be retrieved again.
+	retryConfig backoff.Config
 
 	healthMut sync.RWMutex
 	health    component.Health
 
 	debugMut  sync.RWMutex
 	debugInfo secretInfo
+
+	watchers sync.WaitGroup // Goroutines handling lifetime watchers.
 }
 
 type tokenManagerOptions struct {
 	Log    log.Logger
 	Getter getTokenFunc
 
-	ReadCounter, RefreshCounter prometheus.Counter
+	ReadCounter    *prometheus.CounterVec // Partitioned by success.
+	RefreshCounter prometheus.Counter
+	RenewalResults *prometheus.CounterVec // Optional; partitioned by result.
+	LeaseTTL       prometheus.Gauge       // Optional.
 
 	Client          *vault.Client
 	RefreshInterval time.Duration
+	RefreshJitter   float64     // Fraction to randomize RefreshInterval by.
+	RandSource      rand.Source // Optional source for randomizing refreshes.
+
+	// RetryConfig configures how failed retrievals are retried. MaxRetries of
+	// 0 retries forever.
+	RetryConfig backoff.Config
+
+	// AllowInitialError creates the tokenManager even if the initial retrieval
+	// fails. The retrieval is retried once the tokenManager runs.
+	AllowInitialError bool
 }
 
 // newTokenManager creates a new, unstarted tokenManager. tokenManager will
-// retrieve the initial token from getter.
+// retrieve the initial token from getter. An error is returned if retrieving
+// the initial token fails, unless opts.AllowInitialError is set.
 func newTokenManager(opts tokenManagerOptions) (*tokenManager, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), tokenManagerInitializeTimeout)
 	defer cancel()
 
+	randSource := opts.RandSource
+	if randSource == nil {
+		randSource = rand.NewSource(time.Now().UnixNano())
+	}
+
 	tm := &tokenManager{
 		log:           opts.Log,
-		refreshTicker: newTicker(opts.RefreshInterval),
+		refreshTicker: newTicker(opts.RefreshInterval, opts.RefreshJitter, randSource),
 		getter:        opts.Getter,
 		onStateChange: make(chan struct{}, 1),
+		onFailure:     make(chan struct{}, 1),
+		onReset:       make(chan struct{}, 1),
 
 		readCounter:    opts.ReadCounter,
 		refreshCounter: opts.RefreshCounter,
+		renewalResults: opts.RenewalResults,
+		leaseTTL:       opts.LeaseTTL,
 
-		cli: opts.Client,
+		cli:         opts.Client,
+		retryConfig: opts.RetryConfig,
 	}
-	if err := tm.updateToken(ctx); err != nil {
+	if err := tm.updateToken(ctx); err != nil && !opts.AllowInitialError {
 		return nil, fmt.Errorf("failed to get token: %w", err)
 	}
 	return tm, nil
@@ -78,9 +114,14 @@ func newTokenManager(opts tokenManagerOptions) (*tokenManager, error) {
 func (tm *tokenManager) updateToken(ctx context.Context) (err error) {
 	defer func() {
 		if err != nil {
+			msg := fmt.Sprintf("failed to retrieve token: %s", err)
+			if tm.tokenExpired() {
+				msg = fmt.Sprintf("vault token expired and re-login failed: %s", err)
+			}
+
 			tm.updateHealth(component.Health{
 				Health:     component.HealthTypeUnhealthy,
-				Message:    fmt.Sprintf("failed to retrieve token: %s", err),
+				Message:    msg,
 				UpdateTime: time.Now(),
 			})
 		} else {
@@ -91,21 +132,28 @@ func (tm *tokenManager) updateToken(ctx context.Context) (err error) {
 			})
 		}
 
-		tm.updateDebugInfo(time.Now())
+		tm.updateDebugInfo(time.Now(), err)
 	}()
 
 	tm.mut.Lock()
 	defer tm.mut.Unlock()
 
 	token, err := tm.getter(ctx, tm.cli)
+	tm.readCounter.WithLabelValues(strconv.FormatBool(err == nil)).Inc()
+	tm.lastErr = err
 	if err != nil {
 		level.Error(tm.log).Log("msg", "failed to get token", "err", err)
+
+		select {
+		case tm.onFailure <- struct{}{}:
+		default:
+		}
 		return err
 	}
 
-	tm.readCounter.Inc()
-
 	tm.token = token
+	tm.expired = false
+	tm.updateLeaseTTL(token)
 
 	select {
 	case tm.onStateChange <- struct{}{}:
@@ -116,25 +164,76 @@ func (tm *tokenManager) updateToken(ctx context.Context) (err error) {
 }
 
 // Run runs the tokenManager, blocking until the provided context is canceled.
+//
+// Failed retrievals of the token are retried with jittered exponential backoff
+// until a retrieval succeeds or the configured number of retries is
+// exhausted.
 func (tm *tokenManager) Run(ctx context.Context) {
 	var cancelLifecycleWatcher context.CancelFunc
 	defer func() {
 		if cancelLifecycleWatcher != nil {
 			cancelLifecycleWatcher()
 		}
+		// Lifetime watchers may still be updating the token, which must not
+		// happen once Run returns and the token is cleared.
+		tm.watchers.Wait()
 	}()
 
+	var (
+		retryBackoff *backoff.Backoff // Non-nil while retrying.
+		retryCh      <-chan time.Time // Non-nil while a retry is scheduled.
+	)
+
 	for {
 		select {
 		case <-ctx.Done():
 			return
 
 		case <-tm.refreshTicker.Chan():
+			tm.refreshTicker.Ticked()
+
 			level.Info(tm.log).Log("msg", "refreshing token")
 			// Error is handled via setting health and debug info.
 			_ = tm.updateToken(ctx)
 
+		case <-tm.onReset:
+			// The channel of refreshTicker may have changed, and is received
+			// from again on the next iteration.
+
+		case <-tm.onFailure:
+			if !tm.failing() {
+				// A later retrieval already succeeded.
+				continue
+			}
+			if retryBackoff == nil {
+				tm.mut.RLock()
+				retryBackoff = backoff.New(ctx, tm.retryConfig)
+				tm.mut.RUnlock()
+			}
+			if !retryBackoff.Ongoing() {
+				level.Error(tm.log).Log("msg", "giving up retrieving token", "retries", retryBackoff.NumRetries())
+				continue
+			}
+
+			delay := retryBackoff.NextDelay()
+			level.Info(tm.log).Log("msg", "retrying token retrieval", "retry", retryBackoff.NumRetries(), "delay", delay)
+
+			retryCh = time.After(delay)
+
+		case <-retryCh:
+			retryCh = nil
+
+			// Error is handled via setting health and debug info, and will
+			// schedule another retry.
+			_ = tm.updateToken(ctx)
+
 		case <-tm.onStateChange:
+			// The token was retrieved successfully; stop retrying unless a later
+			// retrieval failed again.
+			if !tm.failing() {
+				retryBackoff, retryCh = nil, nil
+			}
+
 			if cancelLifecycleWatcher != nil {
 				cancelLifecycleWatcher()
 			}
@@ -147,6 +246,21 @@ func (tm *tokenManager) Run(ctx context.Context) {
 	}
 }
 
+// tokenExpired returns true if the token expired and a new one hasn't been
+// retrieved since.
+func (tm *tokenManager) tokenExpired() bool {
+	tm.mut.RLock()
+	defer tm.mut.RUnlock()
+	return tm.expired
+}
+
+// failing returns true if the latest retrieval of the token failed.
+func (tm *tokenManager) failing() bool {
+	tm.mut.RLock()
+	defer tm.mut.RUnlock()
+	return tm.lastErr != nil
+}
+
 func (tm *tokenManager) updateHealth(h component.Health) {
 	tm.healthMut.Lock()
 	defer tm.healthMut.Unlock()
@@ -154,7 +268,9 @@ func (tm *tokenManager) updateHealth(h component.Health) {
 	tm.health = h
 }
 
-func (tm *tokenManager) updateDebugInfo(updateTime time.Time) {
+// updateDebugInfo updates the debug info after the token was retrieved or
+// renewed at updateTime. err is the error from retrieving the token, if any.
+func (tm *tokenManager) updateDebugInfo(updateTime time.Time, err error) {
 	tm.mut.RLock()
 	token := tm.token
 	tm.mut.RUnlock()
@@ -162,7 +278,14 @@ func (tm *tokenManager) updateDebugInfo(updateTime time.Time) {
 	tm.debugMut.Lock()
 	defer tm.debugMut.Unlock()
 
-	tm.debugInfo = getSecretInfo(token, updateTime)
+	info := getSecretInfo(token, updateTime)
+	info.LastSuccessTime = tm.debugInfo.LastSuccessTime
+	if err != nil {
+		info.LastError = err.Error()
+	} else {
+		info.LastSuccessTime = updateTime
+	}
+	tm.debugInfo = info
 }
 
 func (tm *tokenManager) updateLifecycleWatcher(ctx context.Context) {
@@ -184,41 +307,117 @@ func (tm *tokenManager) updateLifecycleWatcher(ctx context.Context) {
 
 	go lw.Start()
 
+	tm.watchers.Add(1)
 	go func() {
+		defer tm.watchers.Done()
 		for {
 			select {
 			case <-ctx.Done():
 				lw.Stop()
 				return
 
-			case <-lw.DoneCh():
+			case err := <-lw.DoneCh():
 				if ctx.Err() != nil {
 					return
 				}
+				if err != nil {
+					// Renewals kept failing until the lease ran out.
+					tm.countRenewal("failure")
+				}
+
+				// The token can no longer be renewed and has expired (or is about
+				// to). Flag it so a failure to get a new token is reported as
+				// such instead of continuing to serve the stale token silently.
+				tm.mut.Lock()
+				tm.expired = true
+				tm.mut.Unlock()
+
 				// Error is logged as health and debug info.
 				_ = tm.updateToken(ctx)
 
 			case output := <-lw.RenewCh():
 				tm.refreshCounter.Inc()
+				tm.countRenewal("success")
+				tm.updateLeaseTTL(output.Secret)
 				level.Debug(tm.log).Log("msg", "token has renewed")
-				tm.updateDebugInfo(output.RenewedAt)
+				tm.updateDebugInfo(output.RenewedAt, nil)
 			}
 		}
 	}()
 }
 
+// countRenewal counts a renewal with the given result (if renewal results
+// are counted).
+func (tm *tokenManager) countRenewal(result string) {
+	if tm.renewalResults == nil {
+		return
+	}
+	tm.renewalResults.WithLabelValues(result).Inc()
+}
+
+// updateLeaseTTL updates the lease TTL gauge (if set) from secret.
+func (tm *tokenManager) updateLeaseTTL(secret *vault.Secret) {
+	if tm.leaseTTL == nil {
+		return
+	}
+	tm.leaseTTL.Set(leaseDuration(secret).Seconds())
+}
+
+// ClearLeaseTTL resets the lease TTL gauge (if set). It should be called once
+// the tokenManager stops running.
+func (tm *tokenManager) ClearLeaseTTL() {
+	if tm.leaseTTL == nil {
+		return
+	}
+	tm.leaseTTL.Set(0)
+}
+
+// Clear drops the current token and removes the sensitive fields of its
+// secret, and clears the token used by the client. It should be called once
+// the tokenManager stops running, so that the token isn't kept in memory
+// longer than necessary.
+func (tm *tokenManager) Clear() {
+	tm.mut.Lock()
+	defer tm.mut.Unlock()
+
+	clearSecret(tm.token)
+	tm.token = nil
+	if tm.cli != nil {
+		tm.cli.ClearToken()
+	}
+}
+
+// clearSecret removes the data and the auth token of secret.
+func clearSecret(secret *vault.Secret) {
+	if secret == nil {
+		return
+	}
+	clear(secret.Data)
+	if secret.Auth != nil {
+		secret.Auth.ClientToken = ""
+		secret.Auth.Accessor = ""
+	}
+	if secret.WrapInfo != nil {
+		secret.WrapInfo.Token = ""
+	}
+}
+
 // needsLifecycleWatcher determines if a secret needs a lifecycle watcher.
 // Secrets only need a lifecycle watcher if they are renewable or have a lease
 // duration.
+//
+// Secrets without a lease ID (such as KV v1 secrets, which report their
+// refresh interval as a lease duration) can't be watched; the lifetime
+// watcher would exit immediately and cause the secret to be reread in a loop.
 func needsLifecycleWatcher(secret *vault.Secret) bool {
 	if secret == nil {
 		return false
 	}
 
 	if secret.Auth != nil {
-		return secret.Auth.Renewable || secret.Auth.LeaseDuration > 0
+		return secret.Auth.ClientToken != "" && (secret.Auth.Renewable || secret.Auth.LeaseDuration > 0)
 	}
-	return secret.Renewable || secret.LeaseDuration > 0
+	return secret.LeaseID != "" && (secret.Renewable || secret.LeaseDuration > 0)
 }
 
 // SetClient updates the client associated with the tokenManager. This will
@@ -235,10 +434,25 @@ func (tm *tokenManager) SetClient(cli *vault.Client) {
 	_ = tm.updateToken(ctx)
 }
 
+// SetRetryConfig updates how failed retrievals are retried. The new config is
+// used starting from the next failed retrieval after a successful one.
+func (tm *tokenManager) SetRetryConfig(cfg backoff.Config) {
+	tm.mut.Lock()
+	defer tm.mut.Unlock()
+
+	tm.retryConfig = cfg
+}
+
 // SetRefreshInterval sets a forced refresh interval, separate from automatic
-// renewal based on the token lease.
-func (tm *tokenManager) SetRefreshInterval(interval time.Duration) {
-	tm.refreshTicker.Reset(interval)
+// renewal based on the token lease. Each interval is randomized by up to the
+// jitter fraction of interval.
+func (tm *tokenManager) SetRefreshInterval(interval time.Duration, jitter float64) {
+	tm.refreshTicker.Reset(interval, jitter)
+
+	select {
+	case tm.onReset <- struct{}{}:
+	default:
+	}
 }
 
 // CurrentHealth returns the health of the tokenManager.
@@ -254,15 +468,22 @@ func (tm *tokenManager) DebugInfo() secretInfo {
 	tm.debugMut.RLock()
 	defer tm.debugMut.RUnlock()
 
-	return tm.debugInfo
+	info := tm.debugInfo
+	if !info.SecretExpireTime.IsZero() {
+		info.LeaseRemaining = max(time.Until(info.SecretExpireTime), 0).Truncate(time.Second)
+	}
+	return info
 }
 
 type secretInfo struct {
-	LatestRequestID  string    `river:"latest_request_id,attr"`
-	LastUpdateTime   time.Time `river:"last_update_time,attr"`
-	SecretExpireTime time.Time `river:"secret_expire_time,attr"`
-	Renewable        bool      `river:"renewable,attr"`
-	Warnings         []string  `river:"warnings,attr"`
+	LatestRequestID  string        `river:"latest_request_id,attr"`
+	LastUpdateTime   time.Time     `river:"last_update_time,attr"`
+	LastSuccessTime  time.Time     `river:"last_success_time,attr,optional"`
+	LastError        string        `river:"last_error,attr,optional"`
+	SecretExpireTime time.Time     `river:"secret_expire_time,attr"`
+	LeaseRemaining   time.Duration `river:"lease_remaining,attr,optional"`
+	Renewable        bool          `river:"renewable,attr"`
+	Warnings         []string      `river:"warnings,attr"`
 }
 
 func getSecretInfo(secret *vault.Secret, updateTime time.Time) secretInfo {
@@ -282,6 +503,19 @@ func getSecretInfo(secret *vault.Secret, updateTime time.Time) secretInfo {
 	}
 }
 
+// leaseDuration returns the lease duration of secret. The lease duration of
+// the auth token is used for secrets returned from authentication.
+func leaseDuration(secret *vault.Secret) time.Duration {
+	switch {
+	case secret == nil:
+		return 0
+	case secret.Auth != nil:
+		return time.Duration(secret.Auth.LeaseDuration) * time.Second
+	default:
+		return time.Duration(secret.LeaseDuration) * time.Second
+	}
+}
+
 func secretExpireTime(secret *vault.Secret) time.Time {
 	ttl, err := secret.TokenTTL()
 	if err != nil || ttl == 0 {
@@ -0,0 +1,202 @@
+package vault
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/agent/internal/component"
+	"github.com/grafana/agent/internal/flow/componenttest"
+	"github.com/grafana/dskit/backoff"
+	vault "github.com/hashicorp/vault/api"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+func Test_tokenManager_Retry(t *testing.T) {
+	const minBackoff = 50 * time.Millisecond
+
+	// Succeed on the initial read, fail the next three reads, and then
+	// succeed again.
+	getter := newFakeGetter(true, false, false, false, true)
+
+	tm := newTestTokenManager(t, getter.Get, backoff.Config{
+		MinBackoff: minBackoff,
+		MaxBackoff: 10 * minBackoff,
+	})
+	go tm.Run(componenttest.TestContext(t))
+
+	// Force a new retrieval, which fails and schedules retries.
+	_ = tm.updateToken(context.Background())
+	require.Eventually(t, func() bool {
+		return getter.Calls() == 5
+	}, 5*time.Second, 10*time.Millisecond, "token was never retrieved after failures")
+	require.Equal(t, component.HealthTypeHealthy, tm.CurrentHealth().Health)
+
+	// The delay between retries should grow exponentially, starting from
+	// minBackoff. Jitter keeps each delay below the start of the next range.
+	times := getter.Times()
+	for i, retry := range []int{2, 3, 4} {
+		var (
+			delay    = times[retry].Sub(times[retry-1])
+			minDelay = minBackoff << i
+		)
+		require.GreaterOrEqual(t, delay, minDelay, "retry %d happened too early", i+1)
+		require.Less(t, delay, 2*minDelay+minBackoff, "retry %d happened too late", i+1)
+	}
+}
+
+func Test_tokenManager_MaxRetries(t *testing.T) {
+	getter := newFakeGetter(true)
+
+	tm := newTestTokenManager(t, getter.Get, backoff.Config{
+		MinBackoff: time.Millisecond,
+		MaxBackoff: time.Millisecond,
+		MaxRetries: 2,
+	})
+	go tm.Run(componenttest.TestContext(t))
+
+	// One failed read, followed by two failed retries.
+	_ = tm.updateToken(context.Background())
+	require.Eventually(t, func() bool {
+		return getter.Calls() == 4
+	}, 5*time.Second, time.Millisecond)
+
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, 4, getter.Calls(), "token retrieval retried too many times")
+	require.Equal(t, component.HealthTypeUnhealthy, tm.CurrentHealth().Health)
+}
+
+func Test_tokenManager_Expired(t *testing.T) {
+	// Return a short-lived, non-renewable token once, and fail every following
+	// login.
+	var logins atomic.Int32
+	getter := func(_ context.Context, _ *vault.Client) (*vault.Secret, error) {
+		if logins.Inc() > 1 {
+			return nil, fmt.Errorf("permission denied")
+		}
+		return &vault.Secret{
+			Auth: &vault.SecretAuth{
+				ClientToken:   "short-lived",
+				LeaseDuration: 1,
+				Renewable:     false,
+			},
+		}, nil
+	}
+
+	tm := newTestTokenManager(t, getter, backoff.Config{
+		MinBackoff: time.Second,
+		MaxBackoff: time.Second,
+	})
+	require.Equal(t, component.HealthTypeHealthy, tm.CurrentHealth().Health)
+
+	go tm.Run(componenttest.TestContext(t))
+
+	require.Eventually(t, func() bool {
+		h := tm.CurrentHealth()
+		return h.Health == component.HealthTypeUnhealthy &&
+			h.Message == "vault token expired and re-login failed: permission denied"
+	}, 10*time.Second, 10*time.Millisecond)
+}
+
+func Test_tokenManager_SetRefreshInterval(t *testing.T) {
+	var reads atomic.Int32
+	getter := func(_ context.Context, _ *vault.Client) (*vault.Secret, error) {
+		reads.Inc()
+		return &vault.Secret{}, nil
+	}
+
+	tm := newTestTokenManager(t, getter, backoff.Config{})
+	go tm.Run(componenttest.TestContext(t))
+
+	// Let Run wait without a refresh interval before setting one.
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, int32(1), reads.Load())
+
+	tm.SetRefreshInterval(10*time.Millisecond, 0)
+	require.Eventually(t, func() bool {
+		return reads.Load() >= 3
+	}, 5*time.Second, 10*time.Millisecond, "token was never refreshed")
+}
+
+func Test_needsLifecycleWatcher(t *testing.T) {
+	tt := []struct {
+		name   string
+		secret *vault.Secret
+		expect bool
+	}{
+		{name: "nil", secret: nil, expect: false},
+		{name: "no lease", secret: &vault.Secret{}, expect: false},
+		{name: "lease", secret: &vault.Secret{LeaseID: "lease", LeaseDuration: 60}, expect: true},
+		{name: "lease without ID", secret: &vault.Secret{LeaseDuration: 60}, expect: false},
+		{name: "auth token", secret: &vault.Secret{Auth: &vault.SecretAuth{ClientToken: "token", Renewable: true}}, expect: true},
+		{name: "auth without token", secret: &vault.Secret{Auth: &vault.SecretAuth{LeaseDuration: 60}}, expect: false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expect, needsLifecycleWatcher(tc.secret))
+		})
+	}
+}
+
+func newTestTokenManager(t *testing.T, getter getTokenFunc, retryConfig backoff.Config) *tokenManager {
+	t.Helper()
+
+	cli, err := vault.NewClient(vault.DefaultConfig())
+	require.NoError(t, err)
+
+	tm, err := newTokenManager(tokenManagerOptions{
+		Log:    log.NewNopLogger(),
+		Getter: getter,
+
+		ReadCounter:    prometheus.NewCounterVec(prometheus.CounterOpts{Name: "reads"}, []string{"success"}),
+		RefreshCounter: prometheus.NewCounter(prometheus.CounterOpts{Name: "refreshes"}),
+
+		Client:      cli,
+		RetryConfig: retryConfig,
+	})
+	require.NoError(t, err)
+	return tm
+}
+
+// fakeGetter is a getTokenFunc which succeeds or fails according to a script
+// of results. Once the script is exhausted, every call fails.
+type fakeGetter struct {
+	mut     sync.Mutex
+	results []bool
+	times   []time.Time
+}
+
+func newFakeGetter(results ...bool) *fakeGetter {
+	return &fakeGetter{results: results}
+}
+
+func (g *fakeGetter) Get(_ context.Context, _ *vault.Client) (*vault.Secret, error) {
+	g.mut.Lock()
+	defer g.mut.Unlock()
+
+	call := len(g.times)
+	g.times = append(g.times, time.Now())
+
+	if call < len(g.results) && g.results[call] {
+		return &vault.Secret{RequestID: fmt.Sprintf("request-%d", call)}, nil
+	}
+	return nil, fmt.Errorf("injected failure for call %d", call)
+}
+
+func (g *fakeGetter) Calls() int {
+	g.mut.Lock()
+	defer g.mut.Unlock()
+	return len(g.times)
+}
+
+func (g *fakeGetter) Times() []time.Time {
+	g.mut.Lock()
+	defer g.mut.Unlock()
+	return append([]time.Time(nil), g.times...)
+}
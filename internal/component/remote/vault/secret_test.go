@@ -0,0 +1,1139 @@
+package vault
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/internal/component"
+	"github.com/grafana/agent/internal/flow/componenttest"
+	"github.com/grafana/agent/internal/util"
+	"github.com/grafana/river"
+	"github.com/grafana/river/rivertypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_SelectKeys(t *testing.T) {
+	stub := newStubVault(t)
+	secret := stub.HandleKVv2("secret", "test", map[string]any{
+		"username": "agent",
+		"password": "hunter2",
+		"other":    "unused",
+	})
+
+	cfg := fmt.Sprintf(`
+		server = "%s"
+		path   = "secret/test"
+		keys   = ["username", "password"]
+
+		reread_frequency = "50ms"
+
+		auth.token {
+			token = "token"
+		}
+	`, stub.Address())
+
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(cfg), &args))
+
+	var exports Exports
+	c, err := New(component.Options{
+		ID:            "remote.vault.test",
+		Logger:        util.TestLogger(t),
+		OnStateChange: func(e component.Exports) { exports = e.(Exports) },
+	}, args)
+	require.NoError(t, err)
+
+	require.Equal(t, Exports{
+		Data: map[string]rivertypes.Secret{
+			"username": rivertypes.Secret("agent"),
+			"password": rivertypes.Secret("hunter2"),
+		},
+	}, exports)
+
+	go func() {
+		require.NoError(t, c.Run(componenttest.TestContext(t)))
+	}()
+
+	// Removing a selected key from the secret must be reported on the next
+	// reread.
+	secret.Set(map[string]any{"username": "agent"})
+
+	require.Eventually(t, func() bool {
+		h := c.CurrentHealth()
+		return h.Health == component.HealthTypeUnhealthy &&
+			h.Message == `failed to retrieve token: key "password" not found in secret`
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func Test_SelectKeys_Missing(t *testing.T) {
+	stub := newStubVault(t)
+	stub.HandleKVv2("secret", "test", map[string]any{"username": "agent"})
+
+	args := DefaultArguments
+	args.Server = stub.Address()
+	args.Path = "secret/test"
+	args.Keys = []string{"password"}
+	args.Auth = []AuthArguments{{AuthToken: &AuthToken{Token: rivertypes.Secret("token")}}}
+
+	_, err := New(component.Options{
+		ID:            "remote.vault.test",
+		Logger:        util.TestLogger(t),
+		OnStateChange: func(e component.Exports) {},
+	}, args)
+	require.EqualError(t, err, `failed to get token: key "password" not found in secret`)
+}
+
+func Test_RequiredAndDefaultKeys(t *testing.T) {
+	tt := []struct {
+		name      string
+		data      map[string]any
+		cfg       string
+		expect    map[string]rivertypes.Secret
+		expectErr string
+	}{
+		{
+			name:   "required key present",
+			data:   map[string]any{"username": "agent"},
+			cfg:    `required_keys = ["username"]`,
+			expect: map[string]rivertypes.Secret{"username": rivertypes.Secret("agent")},
+		},
+		{
+			name:      "required key absent",
+			data:      map[string]any{"username": "agent"},
+			cfg:       `required_keys = ["password"]`,
+			expectErr: `failed to get token: required key "password" not found in secret`,
+		},
+		{
+			name: "default key present",
+			data: map[string]any{"username": "agent", "port": "8080"},
+			cfg:  `default_keys = { "port" = "9090" }`,
+			expect: map[string]rivertypes.Secret{
+				"username": rivertypes.Secret("agent"),
+				"port":     rivertypes.Secret("8080"),
+			},
+		},
+		{
+			name: "default key absent",
+			data: map[string]any{"username": "agent"},
+			cfg: `
+				required_keys = ["username"]
+				default_keys  = { "port" = "9090" }
+			`,
+			expect: map[string]rivertypes.Secret{
+				"username": rivertypes.Secret("agent"),
+				"port":     rivertypes.Secret("9090"),
+			},
+		},
+		{
+			name: "default key selected",
+			data: map[string]any{"username": "agent"},
+			cfg: `
+				keys         = ["port"]
+				default_keys = { "port" = "9090" }
+			`,
+			expect: map[string]rivertypes.Secret{"port": rivertypes.Secret("9090")},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			stub := newStubVault(t)
+			stub.HandleKVv2("secret", "test", tc.data)
+
+			cfg := fmt.Sprintf(`
+				server = "%s"
+				path   = "secret/test"
+				%s
+
+				auth.token {
+					token = "token"
+				}
+			`, stub.Address(), tc.cfg)
+
+			var args Arguments
+			require.NoError(t, river.Unmarshal([]byte(cfg), &args))
+
+			var exports Exports
+			_, err := New(component.Options{
+				ID:            "remote.vault.test",
+				Logger:        util.TestLogger(t),
+				OnStateChange: func(e component.Exports) { exports = e.(Exports) },
+			}, args)
+			if tc.expectErr != "" {
+				require.EqualError(t, err, tc.expectErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expect, exports.Data)
+		})
+	}
+}
+
+func Test_RequiredAndDefaultKeys_Invalid(t *testing.T) {
+	cfg := `
+		server        = "http://localhost:8200"
+		path          = "secret/test"
+		required_keys = ["password"]
+		default_keys  = { "password" = "default" }
+
+		auth.token {
+			token = "token"
+		}
+	`
+
+	var args Arguments
+	require.EqualError(t, river.Unmarshal([]byte(cfg), &args), `key "password" can't be set in both required_keys and default_keys`)
+}
+
+func Test_Paths(t *testing.T) {
+	stub := newStubVault(t)
+	secretA := stub.HandleKVv2("secret", "a", map[string]any{"key": "a-1"})
+	secretB := stub.HandleKVv2("secret", "b", map[string]any{"key": "b-1"})
+
+	cfg := fmt.Sprintf(`
+		server = "%s"
+		paths  = ["secret/a", "secret/b"]
+		keys   = ["key"]
+
+		reread_frequency = "50ms"
+
+		auth.token {
+			token = "token"
+		}
+	`, stub.Address())
+
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(cfg), &args))
+
+	var (
+		exportsMut sync.Mutex
+		exports    Exports
+	)
+	getExports := func() Exports {
+		exportsMut.Lock()
+		defer exportsMut.Unlock()
+		return exports
+	}
+
+	c, err := New(component.Options{
+		ID:     "remote.vault.test",
+		Logger: util.TestLogger(t),
+		OnStateChange: func(e component.Exports) {
+			exportsMut.Lock()
+			defer exportsMut.Unlock()
+			exports = e.(Exports)
+		},
+	}, args)
+	require.NoError(t, err)
+
+	require.Equal(t, Exports{
+		Data: map[string]rivertypes.Secret{},
+		PathsData: map[string]map[string]rivertypes.Secret{
+			"secret/a": {"key": rivertypes.Secret("a-1")},
+			"secret/b": {"key": rivertypes.Secret("b-1")},
+		},
+	}, getExports())
+	require.Equal(t, component.HealthTypeHealthy, c.CurrentHealth().Health)
+
+	go func() {
+		require.NoError(t, c.Run(componenttest.TestContext(t)))
+	}()
+
+	// Break secret/b. secret/a must keep updating while secret/b keeps
+	// exporting its last known data.
+	secretB.Set(map[string]any{"other": "b-2"})
+	secretA.Set(map[string]any{"key": "a-2"})
+
+	require.Eventually(t, func() bool {
+		return getExports().PathsData["secret/a"]["key"] == rivertypes.Secret("a-2")
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, map[string]rivertypes.Secret{"key": rivertypes.Secret("b-1")}, getExports().PathsData["secret/b"])
+
+	health := c.CurrentHealth()
+	require.Equal(t, component.HealthTypeUnhealthy, health.Health)
+	require.Equal(t, `failed to read 1 of 2 paths: secret/b: key "key" not found in secret`, health.Message)
+
+	// Fixing secret/b makes the component healthy again.
+	secretB.Set(map[string]any{"key": "b-3"})
+
+	require.Eventually(t, func() bool {
+		return getExports().PathsData["secret/b"]["key"] == rivertypes.Secret("b-3") &&
+			c.CurrentHealth().Health == component.HealthTypeHealthy
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func Test_Paths_Invalid(t *testing.T) {
+	tt := []struct {
+		name      string
+		paths     string
+		expectErr string
+	}{
+		{name: "none", paths: ``, expectErr: "exactly one of path or paths must be specified; found none"},
+		{name: "both", paths: "path = \"secret/a\"\npaths = [\"secret/b\"]", expectErr: "exactly one of path or paths must be specified; found both"},
+		{name: "duplicate", paths: `paths = ["secret/a", "secret/a"]`, expectErr: `path "secret/a" specified more than once in paths`},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := fmt.Sprintf(`
+				server = "http://localhost:8200"
+				%s
+
+				auth.token {
+					token = "token"
+				}
+			`, tc.paths)
+
+			var args Arguments
+			require.EqualError(t, river.Unmarshal([]byte(cfg), &args), tc.expectErr)
+		})
+	}
+}
+
+func Test_Recursive(t *testing.T) {
+	stub := newStubVault(t)
+	stub.HandleKVv2List("secret", "app", "prod/", "staging/")
+	prod := stub.HandleKVv2List("secret", "app/prod", "api", "db")
+	staging := stub.HandleKVv2List("secret", "app/staging", "api")
+	stub.HandleKVv2("secret", "app/prod/api", map[string]any{"token": "prod-api"})
+	stub.HandleKVv2("secret", "app/prod/db", map[string]any{"password": "prod-db"})
+	stub.HandleKVv2("secret", "app/staging/api", map[string]any{"token": "staging-api"})
+	stub.HandleKVv2("secret", "app/staging/web", map[string]any{"token": "staging-web"})
+
+	cfg := fmt.Sprintf(`
+		server    = "%s"
+		path      = "secret/app"
+		recursive = true
+
+		reread_frequency = "50ms"
+
+		auth.token {
+			token = "token"
+		}
+	`, stub.Address())
+
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(cfg), &args))
+
+	var (
+		exportsMut sync.Mutex
+		exports    Exports
+	)
+	getExports := func() Exports {
+		exportsMut.Lock()
+		defer exportsMut.Unlock()
+		return exports
+	}
+
+	c, err := New(component.Options{
+		ID:     "remote.vault.test",
+		Logger: util.TestLogger(t),
+		OnStateChange: func(e component.Exports) {
+			exportsMut.Lock()
+			defer exportsMut.Unlock()
+			exports = e.(Exports)
+		},
+	}, args)
+	require.NoError(t, err)
+
+	require.Equal(t, Exports{
+		Data: map[string]rivertypes.Secret{},
+		Tree: map[string]any{
+			"prod": map[string]any{
+				"api": map[string]rivertypes.Secret{"token": rivertypes.Secret("prod-api")},
+				"db":  map[string]rivertypes.Secret{"password": rivertypes.Secret("prod-db")},
+			},
+			"staging": map[string]any{
+				"api": map[string]rivertypes.Secret{"token": rivertypes.Secret("staging-api")},
+			},
+		},
+	}, getExports())
+
+	go func() {
+		require.NoError(t, c.Run(componenttest.TestContext(t)))
+	}()
+
+	// Added and removed secrets are picked up on the next reread.
+	prod.Set("api")
+	staging.Set("api", "web")
+
+	require.Eventually(t, func() bool {
+		return assert.ObjectsAreEqual(map[string]any{
+			"prod": map[string]any{
+				"api": map[string]rivertypes.Secret{"token": rivertypes.Secret("prod-api")},
+			},
+			"staging": map[string]any{
+				"api": map[string]rivertypes.Secret{"token": rivertypes.Secret("staging-api")},
+				"web": map[string]rivertypes.Secret{"token": rivertypes.Secret("staging-web")},
+			},
+		}, getExports().Tree)
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, component.HealthTypeHealthy, c.CurrentHealth().Health)
+}
+
+func Test_Recursive_MaxSecrets(t *testing.T) {
+	stub := newStubVault(t)
+	stub.HandleKVv2List("secret", "app", "a", "b", "c")
+
+	args := DefaultArguments
+	args.Server = stub.Address()
+	args.Path = "secret/app"
+	args.Recursive = true
+	args.MaxSecrets = 2
+	args.Auth = []AuthArguments{{AuthToken: &AuthToken{Token: rivertypes.Secret("token")}}}
+
+	_, err := New(component.Options{
+		ID:            "remote.vault.test",
+		Logger:        util.TestLogger(t),
+		OnStateChange: func(e component.Exports) {},
+	}, args)
+	require.EqualError(t, err, "failed to get token: failed to list secrets under secret/app: found more than 2 secrets under secret/app")
+}
+
+func Test_Recursive_Invalid(t *testing.T) {
+	tt := []struct {
+		name, cfg, expectErr string
+	}{
+		{
+			name:      "with paths",
+			cfg:       `paths = ["secret/a"]`,
+			expectErr: "recursive can only be used with path",
+		},
+		{
+			name: "with kv_v1",
+			cfg: `
+				path   = "secret/app"
+				engine = "kv_v1"
+			`,
+			expectErr: "recursive can only be used with the kv_v2 engine",
+		},
+		{
+			name: "with version",
+			cfg: `
+				path    = "secret/app"
+				version = 2
+			`,
+			expectErr: "version can't be used with recursive",
+		},
+		{
+			name: "with structured export",
+			cfg: `
+				path          = "secret/app"
+				export_format = "structured"
+			`,
+			expectErr: `export_format "structured" can't be used with recursive`,
+		},
+		{
+			name: "invalid max_secrets",
+			cfg: `
+				path        = "secret/app"
+				max_secrets = 0
+			`,
+			expectErr: "max_secrets must be greater than 0",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := fmt.Sprintf(`
+				server    = "http://localhost:8200"
+				recursive = true
+				%s
+
+				auth.token {
+					token = "token"
+				}
+			`, tc.cfg)
+
+			var args Arguments
+			require.EqualError(t, river.Unmarshal([]byte(cfg), &args), tc.expectErr)
+		})
+	}
+}
+
+func Test_MergePaths(t *testing.T) {
+	stub := newStubVault(t)
+	stub.HandleKVv2("secret", "common", map[string]any{"username": "agent", "password": "common"})
+	stub.HandleKVv2("secret", "prod", map[string]any{"password": "prod"})
+
+	tt := []struct {
+		name      string
+		merge     bool
+		expect    map[string]rivertypes.Secret
+		expectErr string
+	}{
+		{
+			name:  "merge",
+			merge: true,
+			expect: map[string]rivertypes.Secret{
+				"username": rivertypes.Secret("agent"),
+				"password": rivertypes.Secret("prod"),
+			},
+		},
+		{
+			name:      "conflict",
+			expectErr: `key "password" is set by both secret/common and secret/prod`,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := fmt.Sprintf(`
+				server      = "%s"
+				merge_paths = ["secret/common", "secret/prod"]
+				merge       = %t
+
+				auth.token {
+					token = "token"
+				}
+			`, stub.Address(), tc.merge)
+
+			var args Arguments
+			require.NoError(t, river.Unmarshal([]byte(cfg), &args))
+
+			var exports Exports
+			_, err := New(component.Options{
+				ID:     "remote.vault.test",
+				Logger: util.TestLogger(t),
+				OnStateChange: func(e component.Exports) {
+					exports = e.(Exports)
+				},
+			}, args)
+			if tc.expectErr != "" {
+				require.ErrorContains(t, err, tc.expectErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expect, exports.Data)
+		})
+	}
+}
+
+func Test_MergePaths_Invalid(t *testing.T) {
+	tt := []struct {
+		name, cfg, expectErr string
+	}{
+		{
+			name:      "with path",
+			cfg:       "path = \"secret/a\"\nmerge_paths = [\"secret/b\"]",
+			expectErr: "merge_paths can't be used with path or paths",
+		},
+		{
+			name:      "duplicate",
+			cfg:       `merge_paths = ["secret/a", "secret/a"]`,
+			expectErr: `path "secret/a" specified more than once in merge_paths`,
+		},
+		{
+			name:      "merge without merge_paths",
+			cfg:       "path = \"secret/a\"\nmerge = true",
+			expectErr: "merge can only be used with merge_paths",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := fmt.Sprintf(`
+				server = "http://localhost:8200"
+				%s
+
+				auth.token {
+					token = "token"
+				}
+			`, tc.cfg)
+
+			var args Arguments
+			require.EqualError(t, river.Unmarshal([]byte(cfg), &args), tc.expectErr)
+		})
+	}
+}
+
+func Test_ExportFormatStructured(t *testing.T) {
+	stub := newStubVault(t)
+	stub.HandleKVv2("secret", "test", map[string]any{
+		"config":   `{"db": {"username": "agent", "password": "hunter2"}, "hosts": ["a", "b"], "port": 5432}`,
+		"password": "hunter2",
+		"broken":   `{"not": "json"`,
+	})
+
+	cfg := fmt.Sprintf(`
+		server        = "%s"
+		path          = "secret/test"
+		export_format = "structured"
+
+		auth.token {
+			token = "token"
+		}
+	`, stub.Address())
+
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(cfg), &args))
+
+	var exports Exports
+	_, err := New(component.Options{
+		ID:            "remote.vault.test",
+		Logger:        util.TestLogger(t),
+		OnStateChange: func(e component.Exports) { exports = e.(Exports) },
+	}, args)
+	require.NoError(t, err)
+
+	// The flat map is still exported.
+	require.Equal(t, rivertypes.Secret("hunter2"), exports.Data["password"])
+
+	require.Equal(t, map[string]any{
+		"config": map[string]any{
+			"db": map[string]any{
+				"username": rivertypes.Secret("agent"),
+				"password": rivertypes.Secret("hunter2"),
+			},
+			"hosts": []any{rivertypes.Secret("a"), rivertypes.Secret("b")},
+			"port":  rivertypes.Secret("5432"),
+		},
+		"password": rivertypes.Secret("hunter2"),
+		"broken":   rivertypes.Secret(`{"not": "json"`),
+	}, exports.Structured)
+}
+
+func Test_ExportFormat_Invalid(t *testing.T) {
+	tt := []struct {
+		name      string
+		config    string
+		expectErr string
+	}{
+		{
+			name:      "unknown format",
+			config:    "export_format = \"yaml\"\npath = \"secret/test\"",
+			expectErr: `unrecognized export_format "yaml", expected one of map,structured`,
+		},
+		{
+			name:      "structured with paths",
+			config:    "export_format = \"structured\"\npaths = [\"secret/a\"]",
+			expectErr: `export_format "structured" can't be used with paths`,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := fmt.Sprintf(`
+				server = "http://localhost:8200"
+				%s
+
+				auth.token {
+					token = "token"
+				}
+			`, tc.config)
+
+			var args Arguments
+			require.EqualError(t, river.Unmarshal([]byte(cfg), &args), tc.expectErr)
+		})
+	}
+}
+
+func Test_DebugInfo(t *testing.T) {
+	stub := newStubVault(t)
+	stub.Handle("auth/userpass/login/agent", func(w http.ResponseWriter, r *http.Request) {
+		writeStubResponse(w, map[string]any{
+			"auth": map[string]any{
+				"client_token":   "userpass-token",
+				"lease_duration": 3600,
+				"renewable":      false,
+			},
+		})
+	})
+
+	var failing atomic.Bool
+	secret := stub.HandleKVv2("secret", "test", map[string]any{"password": "hunter2"})
+	stub.Handle("secret/data/failing", func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		secret.ServeHTTP(w, r)
+	})
+
+	cfg := fmt.Sprintf(`
+		server = "%s"
+		path   = "secret/failing"
+
+		reread_frequency = "50ms"
+
+		auth.userpass {
+			username = "agent"
+			password = "password"
+		}
+	`, stub.Address())
+
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(cfg), &args))
+
+	c, err := New(component.Options{
+		ID:            "remote.vault.test",
+		Logger:        util.TestLogger(t),
+		OnStateChange: func(e component.Exports) {},
+	}, args)
+	require.NoError(t, err)
+
+	info := c.DebugInfo().(debugInfo)
+	require.Equal(t, stub.Address(), info.Server)
+	require.Equal(t, "userpass", info.AuthMethod)
+	require.InDelta(t, time.Hour, info.AuthToken.LeaseRemaining, float64(time.Minute))
+	require.False(t, info.Secret.LastSuccessTime.IsZero())
+	require.Empty(t, info.Secret.LastError)
+
+	lastSuccess := info.Secret.LastSuccessTime
+
+	go func() {
+		require.NoError(t, c.Run(componenttest.TestContext(t)))
+	}()
+
+	// A failed reread is reported without changing the last successful read.
+	failing.Store(true)
+	require.Eventually(t, func() bool {
+		info := c.DebugInfo().(debugInfo)
+		return info.Secret.LastError != "" && info.Secret.LastSuccessTime.Equal(lastSuccess)
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func Test_ClearSecretsOnStop(t *testing.T) {
+	stub := newStubVault(t)
+	stub.Handle("auth/userpass/login/agent", func(w http.ResponseWriter, r *http.Request) {
+		writeStubResponse(w, map[string]any{
+			"auth": map[string]any{"client_token": "userpass-token"},
+		})
+	})
+	stub.HandleKVv2("secret", "test", map[string]any{"key": "value"})
+
+	cfg := fmt.Sprintf(`
+		server = "%s"
+		path   = "secret/test"
+
+		auth.userpass {
+			username = "agent"
+			password = "password"
+		}
+	`, stub.Address())
+
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(cfg), &args))
+
+	c, err := New(component.Options{
+		ID:            "remote.vault.test",
+		Logger:        util.TestLogger(t),
+		OnStateChange: func(e component.Exports) {},
+	}, args)
+	require.NoError(t, err)
+
+	var (
+		authToken = c.authManager.token
+		secret    = c.secretManager.token
+		cli       = c.secretManager.cli
+	)
+	require.Equal(t, "userpass-token", authToken.Auth.ClientToken)
+	require.Equal(t, "value", secret.Data["key"])
+	require.Equal(t, "userpass-token", cli.Token())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		require.NoError(t, c.Run(ctx))
+	}()
+	cancel()
+	<-done
+
+	require.Empty(t, authToken.Auth.ClientToken)
+	require.Empty(t, secret.Data)
+	require.Empty(t, cli.Token())
+	require.Nil(t, c.authManager.token)
+	require.Nil(t, c.secretManager.token)
+}
+
+func Test_RereadFrequencyZero(t *testing.T) {
+	var reads atomic.Int32
+
+	stub := newStubVault(t)
+	stub.Handle("secret/data/test", func(w http.ResponseWriter, r *http.Request) {
+		reads.Add(1)
+		writeStubResponse(w, map[string]any{
+			"data": map[string]any{
+				"data":     map[string]any{"key": "value"},
+				"metadata": map[string]any{"version": 1},
+			},
+		})
+	})
+
+	cfg := fmt.Sprintf(`
+		server = "%s"
+		path   = "secret/test"
+
+		reread_frequency = "0s"
+
+		auth.token {
+			token = "token"
+		}
+	`, stub.Address())
+
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(cfg), &args))
+
+	var (
+		exportsMut sync.Mutex
+		exports    Exports
+	)
+	c, err := New(component.Options{
+		ID:     "remote.vault.test",
+		Logger: util.TestLogger(t),
+		OnStateChange: func(e component.Exports) {
+			exportsMut.Lock()
+			defer exportsMut.Unlock()
+			exports = e.(Exports)
+		},
+	}, args)
+	require.NoError(t, err)
+
+	go func() {
+		require.NoError(t, c.Run(componenttest.TestContext(t)))
+	}()
+
+	// The secret is read once and never polled, but stays exported.
+	time.Sleep(200 * time.Millisecond)
+	require.Equal(t, int32(1), reads.Load())
+	require.Equal(t, component.HealthTypeHealthy, c.CurrentHealth().Health)
+
+	exportsMut.Lock()
+	require.Equal(t, Exports{
+		Data: map[string]rivertypes.Secret{"key": rivertypes.Secret("value")},
+	}, exports)
+	exportsMut.Unlock()
+
+	// Polling starts once reread_frequency is changed to a non-zero value.
+	args.RereadFrequency = 10 * time.Millisecond
+	require.NoError(t, c.Update(args))
+	require.Eventually(t, func() bool {
+		return reads.Load() >= 3
+	}, 5*time.Second, 10*time.Millisecond, "secret was never reread")
+
+	// Polling stops once reread_frequency is changed back to 0s.
+	args.RereadFrequency = 0
+	require.NoError(t, c.Update(args))
+
+	// Let a reread which was already scheduled before the update finish.
+	time.Sleep(50 * time.Millisecond)
+	stopped := reads.Load()
+	time.Sleep(200 * time.Millisecond)
+	require.Equal(t, stopped, reads.Load())
+	require.Equal(t, component.HealthTypeHealthy, c.CurrentHealth().Health)
+}
+
+func Test_OnInitialError(t *testing.T) {
+	tt := []struct {
+		mode          string
+		failOpenData  string
+		expectInitial *Exports // nil if nothing should be exported.
+	}{
+		{
+			mode: "fail_closed",
+		},
+		{
+			mode:         "fail_open",
+			failOpenData: `fail_open_data = { "key" = "default" }`,
+			expectInitial: &Exports{
+				Data: map[string]rivertypes.Secret{"key": rivertypes.Secret("default")},
+			},
+		},
+		{
+			mode:          "fail_open",
+			expectInitial: &Exports{Data: map[string]rivertypes.Secret{}},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.mode+tc.failOpenData, func(t *testing.T) {
+			var failReads atomic.Bool
+			failReads.Store(true)
+
+			stub := newStubVault(t)
+			secret := &stubKVv2Secret{data: map[string]any{"key": "value"}, version: 1}
+			stub.Handle("secret/data/test", func(w http.ResponseWriter, r *http.Request) {
+				if failReads.Load() {
+					http.Error(w, `{"errors":["internal error"]}`, http.StatusInternalServerError)
+					return
+				}
+				secret.ServeHTTP(w, r)
+			})
+
+			cfg := fmt.Sprintf(`
+				server = "%s"
+				path   = "secret/test"
+
+				reread_frequency = "50ms"
+				on_initial_error = "%s"
+				%s
+
+				client_options {
+					max_retries = 0
+				}
+
+				auth.token {
+					token = "token"
+				}
+			`, stub.Address(), tc.mode, tc.failOpenData)
+
+			var args Arguments
+			require.NoError(t, river.Unmarshal([]byte(cfg), &args))
+
+			var (
+				exportsMut sync.Mutex
+				exports    *Exports
+			)
+			getExports := func() *Exports {
+				exportsMut.Lock()
+				defer exportsMut.Unlock()
+				return exports
+			}
+
+			c, err := New(component.Options{
+				ID:     "remote.vault.test",
+				Logger: util.TestLogger(t),
+				OnStateChange: func(e component.Exports) {
+					exportsMut.Lock()
+					defer exportsMut.Unlock()
+					ex := e.(Exports)
+					exports = &ex
+				},
+			}, args)
+			require.NoError(t, err)
+			require.Equal(t, tc.expectInitial, getExports())
+			require.Equal(t, component.HealthTypeUnhealthy, c.CurrentHealth().Health)
+
+			go func() {
+				require.NoError(t, c.Run(componenttest.TestContext(t)))
+			}()
+
+			// The read is retried once the component runs, and its result replaces
+			// the initial exports.
+			failReads.Store(false)
+			require.Eventually(t, func() bool {
+				ex := getExports()
+				return ex != nil && ex.Data["key"] == rivertypes.Secret("value")
+			}, 5*time.Second, 10*time.Millisecond, "secret was never read")
+			require.Eventually(t, func() bool {
+				return c.CurrentHealth().Health == component.HealthTypeHealthy
+			}, 5*time.Second, 10*time.Millisecond)
+		})
+	}
+}
+
+func Test_OnInitialError_Invalid(t *testing.T) {
+	tt := []struct {
+		name, cfg, expectErr string
+	}{
+		{
+			name:      "unknown mode",
+			cfg:       `on_initial_error = "ignore"`,
+			expectErr: `unrecognized on_initial_error "ignore", expected one of error,fail_closed,fail_open`,
+		},
+		{
+			name:      "fail_open_data without fail_open",
+			cfg:       `fail_open_data = { "key" = "default" }`,
+			expectErr: `fail_open_data can only be used when on_initial_error is "fail_open"`,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := fmt.Sprintf(`
+				server = "http://127.0.0.1:8200"
+				path   = "secret/test"
+				%s
+
+				auth.token {
+					token = "token"
+				}
+			`, tc.cfg)
+
+			var args Arguments
+			require.EqualError(t, river.Unmarshal([]byte(cfg), &args), tc.expectErr)
+		})
+	}
+}
+
+func Test_Unwrap(t *testing.T) {
+	var unwraps atomic.Int32
+
+	stub := newStubVault(t)
+	stub.Handle("sys/wrapping/unwrap", func(w http.ResponseWriter, r *http.Request) {
+		// Wrapping tokens can only be unwrapped once.
+		if r.Header.Get("X-Vault-Token") != "wrapping-token" || unwraps.Add(1) > 1 {
+			http.Error(w, `{"errors":["wrapping token is not valid or does not exist"]}`, http.StatusBadRequest)
+			return
+		}
+		writeStubResponse(w, map[string]any{
+			"data": map[string]any{"secret_id": "unwrapped"},
+		})
+	})
+
+	cfg := fmt.Sprintf(`
+		server = "%s"
+		unwrap = true
+
+		auth.token {
+			token = "wrapping-token"
+		}
+	`, stub.Address())
+
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(cfg), &args))
+
+	var (
+		exportsMut sync.Mutex
+		exports    Exports
+	)
+	getExports := func() Exports {
+		exportsMut.Lock()
+		defer exportsMut.Unlock()
+		return exports
+	}
+
+	c, err := New(component.Options{
+		ID:     "remote.vault.test",
+		Logger: util.TestLogger(t),
+		OnStateChange: func(e component.Exports) {
+			exportsMut.Lock()
+			defer exportsMut.Unlock()
+			exports = e.(Exports)
+		},
+	}, args)
+	require.NoError(t, err)
+
+	expect := map[string]rivertypes.Secret{"secret_id": rivertypes.Secret("unwrapped")}
+	require.Equal(t, expect, getExports().Data)
+
+	// Updating the component exports the unwrapped secret again without
+	// unwrapping it a second time.
+	require.NoError(t, c.Update(args))
+	require.Equal(t, expect, getExports().Data)
+	require.Equal(t, int32(1), unwraps.Load())
+	require.Equal(t, component.HealthTypeHealthy, c.CurrentHealth().Health)
+}
+
+func Test_Unwrap_TokenChanged(t *testing.T) {
+	var (
+		unwrappedMut sync.Mutex
+		unwrapped    = map[string]bool{}
+	)
+
+	stub := newStubVault(t)
+	stub.Handle("sys/wrapping/unwrap", func(w http.ResponseWriter, r *http.Request) {
+		unwrappedMut.Lock()
+		defer unwrappedMut.Unlock()
+
+		// Wrapping tokens can only be unwrapped once.
+		token := r.Header.Get("X-Vault-Token")
+		if unwrapped[token] {
+			http.Error(w, `{"errors":["wrapping token is not valid or does not exist"]}`, http.StatusBadRequest)
+			return
+		}
+		unwrapped[token] = true
+		writeStubResponse(w, map[string]any{
+			"data": map[string]any{"secret_id": "unwrapped with " + token},
+		})
+	})
+
+	argsForToken := func(token string) Arguments {
+		cfg := fmt.Sprintf(`
+			server = "%s"
+			unwrap = true
+
+			auth.token {
+				token = "%s"
+			}
+		`, stub.Address(), token)
+
+		var args Arguments
+		require.NoError(t, river.Unmarshal([]byte(cfg), &args))
+		return args
+	}
+
+	var (
+		exportsMut sync.Mutex
+		exports    Exports
+	)
+	getExports := func() Exports {
+		exportsMut.Lock()
+		defer exportsMut.Unlock()
+		return exports
+	}
+
+	c, err := New(component.Options{
+		ID:     "remote.vault.test",
+		Logger: util.TestLogger(t),
+		OnStateChange: func(e component.Exports) {
+			exportsMut.Lock()
+			defer exportsMut.Unlock()
+			exports = e.(Exports)
+		},
+	}, argsForToken("first-token"))
+	require.NoError(t, err)
+	require.Equal(t, rivertypes.Secret("unwrapped with first-token"), getExports().Data["secret_id"])
+
+	// The secret wrapped by the new token is unwrapped instead of exporting
+	// the secret unwrapped with the previous one.
+	require.NoError(t, c.Update(argsForToken("second-token")))
+	require.Equal(t, rivertypes.Secret("unwrapped with second-token"), getExports().Data["secret_id"])
+}
+
+func Test_Unwrap_Invalid(t *testing.T) {
+	tt := []struct {
+		name, cfg, expectErr string
+	}{
+		{
+			name: "without auth.token",
+			cfg: `
+				auth.userpass {
+					username = "user"
+					password = "password"
+				}
+			`,
+			expectErr: "unwrap requires the wrapping token to be set in auth.token",
+		},
+		{
+			name: "with path",
+			cfg: `
+				path = "secret/test"
+				auth.token {
+					token = "wrapping-token"
+				}
+			`,
+			expectErr: "path, paths, and merge_paths can't be used with unwrap",
+		},
+		{
+			name: "with reread_frequency",
+			cfg: `
+				reread_frequency = "1m"
+				auth.token {
+					token = "wrapping-token"
+				}
+			`,
+			expectErr: "reread_frequency can't be used with unwrap, since wrapping tokens can only be unwrapped once",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := fmt.Sprintf(`
+				server = "http://127.0.0.1:8200"
+				unwrap = true
+				%s
+			`, tc.cfg)
+
+			var args Arguments
+			require.EqualError(t, river.Unmarshal([]byte(cfg), &args), tc.expectErr)
+		})
+	}
+}
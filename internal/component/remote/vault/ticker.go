@@ -1,43 +1,100 @@
 package vault
 
 import (
+	"math/rand"
+	"sync"
 	"time"
 )
 
-// ticker is a wrapper around time.Ticker which allows the tick time to be 0.
-// ticker is not goroutine safe; do not call Chan at the same time as Reset.
+// ticker is a wrapper around time.Ticker which allows the tick time to be 0
+// and the time between ticks to be randomized.
 type ticker struct {
-	ch <-chan time.Time
-
+	mut   sync.Mutex
+	ch    <-chan time.Time
 	inner *time.Ticker
+
+	interval time.Duration
+	jitter   float64 // Fraction of interval to randomize each tick by.
+	rand     *rand.Rand
 }
 
-func newTicker(d time.Duration) *ticker {
-	var t ticker
-	t.Reset(d)
+// newTicker creates a new ticker which ticks every d, randomized by up to the
+// jitter fraction of d. src is used to randomize ticks.
+func newTicker(d time.Duration, jitter float64, src rand.Source) *ticker {
+	t := ticker{rand: rand.New(src)}
+	t.Reset(d, jitter)
 
 	return &t
 }
 
-func (t *ticker) Chan() <-chan time.Time { return t.ch }
+// Chan returns the channel ticks are sent on. It returns nil while the ticker
+// is stopped, and stopping or resetting the ticker may replace the channel, so
+// callers must call Chan again afterwards instead of receiving from a channel
+// returned earlier, which may never receive a tick again.
+func (t *ticker) Chan() <-chan time.Time {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	return t.ch
+}
+
+// Reset changes the ticker to tick every d, randomized by up to the jitter
+// fraction of d. A d of 0 stops the ticker.
+func (t *ticker) Reset(d time.Duration, jitter float64) {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	t.interval, t.jitter = d, jitter
 
-func (t *ticker) Reset(d time.Duration) {
 	if d == 0 {
-		t.Stop()
+		t.stop()
 		return
 	}
 
 	if t.inner == nil {
-		t.inner = time.NewTicker(d)
+		t.inner = time.NewTicker(t.nextInterval())
 		t.ch = t.inner.C
 	} else {
-		t.inner.Reset(d)
+		t.inner.Reset(t.nextInterval())
 	}
 }
 
+// Ticked must be called after receiving a tick to randomize the time until
+// the next tick.
+func (t *ticker) Ticked() {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	if t.inner != nil && t.jitter > 0 {
+		t.inner.Reset(t.nextInterval())
+	}
+}
+
+// nextInterval returns the interval randomized within
+// [interval*(1-jitter), interval*(1+jitter)).
+func (t *ticker) nextInterval() time.Duration {
+	if t.jitter == 0 {
+		return t.interval
+	}
+
+	offset := (2*t.rand.Float64() - 1) * t.jitter * float64(t.interval)
+	return max(t.interval+time.Duration(offset), 1)
+}
+
 func (t *ticker) Stop() {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	t.stop()
+}
+
+// stop stops the ticker. Chan returns nil afterwards, so that a tick which
+// was sent before stopping is never received. The previous channel isn't
+// closed, so receiving from it after stop blocks forever.
+func (t *ticker) stop() {
 	if t.inner != nil {
 		t.inner.Stop()
 		t.inner = nil
 	}
+	t.ch = nil
 }
@@ -0,0 +1,51 @@
+package vault
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ticker_Jitter(t *testing.T) {
+	const (
+		interval = time.Minute
+		jitter   = 0.25
+	)
+
+	tick := newTicker(interval, jitter, rand.NewSource(1))
+	defer tick.Stop()
+
+	var (
+		minInterval = time.Duration(float64(interval) * (1 - jitter))
+		maxInterval = time.Duration(float64(interval) * (1 + jitter))
+
+		seen = make(map[time.Duration]struct{})
+	)
+	for i := 0; i < 1000; i++ {
+		next := tick.nextInterval()
+		require.GreaterOrEqual(t, next, minInterval)
+		require.Less(t, next, maxInterval)
+		seen[next] = struct{}{}
+	}
+	require.Greater(t, len(seen), 1, "intervals were not randomized")
+
+	// The same source yields the same intervals.
+	var (
+		a = newTicker(interval, jitter, rand.NewSource(2))
+		b = newTicker(interval, jitter, rand.NewSource(2))
+	)
+	defer a.Stop()
+	defer b.Stop()
+	for i := 0; i < 10; i++ {
+		require.Equal(t, a.nextInterval(), b.nextInterval())
+	}
+}
+
+func Test_ticker_NoJitter(t *testing.T) {
+	tick := newTicker(time.Minute, 0, rand.NewSource(1))
+	defer tick.Stop()
+
+	require.Equal(t, time.Minute, tick.nextInterval())
+}
@@ -2,16 +2,26 @@ package vault
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
+	"net/http"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-kit/log"
 	"github.com/grafana/agent/internal/component"
+	"github.com/grafana/agent/internal/component/common/config"
 	"github.com/grafana/agent/internal/featuregate"
 	"github.com/grafana/agent/internal/flow/logging/level"
+	"github.com/grafana/agent/internal/useragent"
+	"github.com/grafana/dskit/backoff"
 	"github.com/grafana/river/rivertypes"
 	"github.com/oklog/run"
+	promconfig "github.com/prometheus/common/config"
 
 	vault "github.com/hashicorp/vault/api"
 )
@@ -34,11 +44,39 @@ type Arguments struct {
 	Server    string `river:"server,attr"`
 	Namespace string `river:"namespace,attr,optional"`
 
-	Path string `river:"path,attr"`
+	Path       string   `river:"path,attr,optional"`
+	Paths      []string `river:"paths,attr,optional"`
+	MergePaths []string `river:"merge_paths,attr,optional"`
+	Merge      bool     `river:"merge,attr,optional"`
+	Engine     string   `river:"engine,attr,optional"`
+	Version    int      `river:"version,attr,optional"`
+	Keys       []string `river:"keys,attr,optional"`
+	Unwrap     bool     `river:"unwrap,attr,optional"`
+	Recursive  bool     `river:"recursive,attr,optional"`
+	MaxSecrets int      `river:"max_secrets,attr,optional"`
+
+	RequiredKeys []string                     `river:"required_keys,attr,optional"`
+	DefaultKeys  map[string]rivertypes.Secret `river:"default_keys,attr,optional"`
+
+	TransitKey string `river:"key,attr,optional"`
+	Ciphertext string `river:"ciphertext,attr,optional"`
+
+	Role string `river:"role,attr,optional"`
+
+	ExportFormat string `river:"export_format,attr,optional"`
+
+	OnInitialError string                       `river:"on_initial_error,attr,optional"`
+	FailOpenData   map[string]rivertypes.Secret `river:"fail_open_data,attr,optional"`
 
 	RereadFrequency time.Duration `river:"reread_frequency,attr,optional"`
+	RereadJitter    float64       `river:"reread_jitter,attr,optional"`
+	MaxRetries      int           `river:"max_retries,attr,optional"`
 
-	ClientOptions ClientOptions `river:"client_options,block,optional"`
+	UserAgent      string        `river:"user_agent,attr,optional"`
+	RequestTimeout time.Duration `river:"request_timeout,attr,optional"`
+
+	ClientOptions ClientOptions     `river:"client_options,block,optional"`
+	TLSConfig     *config.TLSConfig `river:"tls_config,block,optional"`
 
 	// The user *must* provide exactly one Auth blocks. This must be a slice
 	// because the enum flag requires a slice and being tagged as optional.
@@ -50,6 +88,11 @@ type Arguments struct {
 
 // DefaultArguments holds default settings for Arguments.
 var DefaultArguments = Arguments{
+	Engine:         engineKVv2,
+	MaxSecrets:     100,
+	ExportFormat:   exportFormatMap,
+	OnInitialError: onInitialErrorError,
+
 	ClientOptions: ClientOptions{
 		MinRetryWait: 1000 * time.Millisecond,
 		MaxRetryWait: 1500 * time.Millisecond,
@@ -58,6 +101,9 @@ var DefaultArguments = Arguments{
 	},
 }
 
+// defaultUserAgent is sent to Vault when user_agent isn't set.
+var defaultUserAgent = useragent.Get()
+
 // client creates a Vault client from the arguments.
 func (a *Arguments) client() (*vault.Client, error) {
 	cfg := vault.DefaultConfig()
@@ -67,6 +113,14 @@ func (a *Arguments) client() (*vault.Client, error) {
 	cfg.MaxRetries = a.ClientOptions.MaxRetries
 	cfg.Timeout = a.ClientOptions.Timeout
 
+	if a.TLSConfig != nil {
+		tlsConfig, err := promconfig.NewTLSConfig(a.TLSConfig.Convert())
+		if err != nil {
+			return nil, fmt.Errorf("tls_config: %w", err)
+		}
+		cfg.HttpClient.Transport.(*http.Transport).TLSClientConfig = tlsConfig
+	}
+
 	cli, err := vault.NewClient(cfg)
 	if err != nil {
 		return cli, err
@@ -75,6 +129,12 @@ func (a *Arguments) client() (*vault.Client, error) {
 	if a.Namespace != "" {
 		cli.SetNamespace(a.Namespace)
 	}
+
+	userAgent := a.UserAgent
+	if userAgent == "" {
+		userAgent = defaultUserAgent
+	}
+	cli.AddHeader("User-Agent", userAgent)
 	return cli, nil
 }
 
@@ -91,6 +151,123 @@ func (a *Arguments) Validate() error {
 		return fmt.Errorf("exactly one auth.* block must be specified; found %d", len(a.Auth))
 	}
 
+	if a.Unwrap {
+		if err := a.validateUnwrap(); err != nil {
+			return err
+		}
+	} else if len(a.MergePaths) > 0 {
+		if err := a.validateMergePaths(); err != nil {
+			return err
+		}
+	} else if a.Path == "" && len(a.Paths) == 0 {
+		return fmt.Errorf("exactly one of path or paths must be specified; found none")
+	} else if a.Path != "" && len(a.Paths) > 0 {
+		return fmt.Errorf("exactly one of path or paths must be specified; found both")
+	}
+
+	if a.Recursive {
+		if err := a.validateRecursive(); err != nil {
+			return err
+		}
+	}
+	if a.MaxSecrets < 1 {
+		return fmt.Errorf("max_secrets must be greater than 0")
+	}
+
+	for _, key := range a.RequiredKeys {
+		if _, ok := a.DefaultKeys[key]; ok {
+			return fmt.Errorf("key %q can't be set in both required_keys and default_keys", key)
+		}
+	}
+
+	if a.Merge && len(a.MergePaths) == 0 {
+		return fmt.Errorf("merge can only be used with merge_paths")
+	}
+
+	seenPaths := make(map[string]struct{}, len(a.Paths))
+	for _, path := range a.Paths {
+		if path == "" {
+			return fmt.Errorf("paths must not contain empty paths")
+		} else if _, ok := seenPaths[path]; ok {
+			return fmt.Errorf("path %q specified more than once in paths", path)
+		}
+		seenPaths[path] = struct{}{}
+	}
+
+	if a.Engine != engineTransit && (a.TransitKey != "" || a.Ciphertext != "") {
+		return fmt.Errorf("key and ciphertext can only be used with the %s engine", engineTransit)
+	} else if a.Engine != engineDatabase && a.Role != "" {
+		return fmt.Errorf("role can only be used with the %s engine", engineDatabase)
+	}
+
+	switch a.Engine {
+	case engineKVv1, engineKVv2:
+		// no-op
+	case engineTransit:
+		if a.TransitKey == "" || a.Ciphertext == "" {
+			return fmt.Errorf("the %s engine requires key and ciphertext to be set", engineTransit)
+		} else if len(a.Paths) > 0 {
+			return fmt.Errorf("the %s engine can't be used with paths", engineTransit)
+		}
+	case engineDatabase:
+		if a.Role == "" {
+			return fmt.Errorf("the %s engine requires role to be set", engineDatabase)
+		} else if len(a.Paths) > 0 {
+			return fmt.Errorf("the %s engine can't be used with paths", engineDatabase)
+		}
+	default:
+		return fmt.Errorf("unrecognized engine %q, expected one of %s,%s,%s,%s", a.Engine, engineKVv1, engineKVv2, engineTransit, engineDatabase)
+	}
+
+	if a.Version < 0 {
+		return fmt.Errorf("version must not be negative")
+	} else if a.Version > 0 && a.Engine != engineKVv2 {
+		return fmt.Errorf("version can only be used with the %s engine", engineKVv2)
+	} else if a.Version > 0 && len(a.Paths) > 0 {
+		return fmt.Errorf("version can't be used with paths")
+	}
+
+	switch a.ExportFormat {
+	case exportFormatMap:
+		// no-op
+	case exportFormatStructured:
+		if len(a.Paths) > 0 {
+			return fmt.Errorf("export_format %q can't be used with paths", exportFormatStructured)
+		}
+	default:
+		return fmt.Errorf("unrecognized export_format %q, expected one of %s,%s", a.ExportFormat, exportFormatMap, exportFormatStructured)
+	}
+
+	switch a.OnInitialError {
+	case onInitialErrorError, onInitialErrorFailClosed, onInitialErrorFailOpen:
+		// no-op
+	default:
+		return fmt.Errorf("unrecognized on_initial_error %q, expected one of %s,%s,%s", a.OnInitialError, onInitialErrorError, onInitialErrorFailClosed, onInitialErrorFailOpen)
+	}
+	if a.FailOpenData != nil && a.OnInitialError != onInitialErrorFailOpen {
+		return fmt.Errorf("fail_open_data can only be used when on_initial_error is %q", onInitialErrorFailOpen)
+	}
+
+	if a.Auth[0].AuthCert != nil && !a.hasClientCertificate() {
+		return fmt.Errorf("auth.cert requires a client certificate to be configured in tls_config")
+	}
+
+	if a.RereadFrequency < 0 {
+		return fmt.Errorf("reread_frequency must not be negative")
+	}
+
+	if a.RereadJitter < 0 || a.RereadJitter >= 1 {
+		return fmt.Errorf("reread_jitter must be at least 0 and less than 1")
+	}
+
+	if a.MaxRetries < 0 {
+		return fmt.Errorf("max_retries must not be negative")
+	}
+
+	if a.RequestTimeout < 0 {
+		return fmt.Errorf("request_timeout must not be negative")
+	}
+
 	if a.ClientOptions.Timeout == 0 {
 		return fmt.Errorf("client_options.timeout must be greater than 0")
 	}
@@ -98,6 +275,117 @@ func (a *Arguments) Validate() error {
 	return nil
 }
 
+// validateUnwrap validates the arguments used along with unwrap.
+func (a *Arguments) validateUnwrap() error {
+	switch {
+	case a.Auth[0].AuthToken == nil:
+		return fmt.Errorf("unwrap requires the wrapping token to be set in auth.token")
+	case a.Path != "" || len(a.Paths) > 0 || len(a.MergePaths) > 0:
+		return fmt.Errorf("path, paths, and merge_paths can't be used with unwrap")
+	case a.Engine == engineTransit || a.Engine == engineDatabase:
+		return fmt.Errorf("the %s engine can't be used with unwrap", a.Engine)
+	case a.Version > 0:
+		return fmt.Errorf("version can't be used with unwrap")
+	case a.RereadFrequency > 0:
+		return fmt.Errorf("reread_frequency can't be used with unwrap, since wrapping tokens can only be unwrapped once")
+	}
+	return nil
+}
+
+// validateMergePaths validates the arguments used along with merge_paths.
+func (a *Arguments) validateMergePaths() error {
+	switch {
+	case a.Path != "" || len(a.Paths) > 0:
+		return fmt.Errorf("merge_paths can't be used with path or paths")
+	case a.Engine == engineTransit || a.Engine == engineDatabase:
+		return fmt.Errorf("the %s engine can't be used with merge_paths", a.Engine)
+	case a.Version > 0:
+		return fmt.Errorf("version can't be used with merge_paths")
+	}
+
+	seenPaths := make(map[string]struct{}, len(a.MergePaths))
+	for _, path := range a.MergePaths {
+		if path == "" {
+			return fmt.Errorf("merge_paths must not contain empty paths")
+		} else if _, ok := seenPaths[path]; ok {
+			return fmt.Errorf("path %q specified more than once in merge_paths", path)
+		}
+		seenPaths[path] = struct{}{}
+	}
+	return nil
+}
+
+// validateRecursive validates the arguments used along with recursive.
+func (a *Arguments) validateRecursive() error {
+	switch {
+	case a.Path == "":
+		return fmt.Errorf("recursive can only be used with path")
+	case a.Engine != engineKVv2:
+		return fmt.Errorf("recursive can only be used with the %s engine", engineKVv2)
+	case a.Version > 0:
+		return fmt.Errorf("version can't be used with recursive")
+	case a.ExportFormat == exportFormatStructured:
+		return fmt.Errorf("export_format %q can't be used with recursive", exportFormatStructured)
+	}
+	return nil
+}
+
+// hasClientCertificate returns true if a TLS client certificate is
+// configured.
+func (a *Arguments) hasClientCertificate() bool {
+	if a.TLSConfig == nil {
+		return false
+	}
+	return a.TLSConfig.Cert != "" || a.TLSConfig.CertFile != ""
+}
+
+const (
+	// exportFormatMap exports the secret as a flat map of secrets.
+	exportFormatMap = "map"
+
+	// exportFormatStructured additionally exports the secret with nested JSON
+	// values decoded into nested objects and arrays.
+	exportFormatStructured = "structured"
+)
+
+const (
+	// onInitialErrorError makes the component fail to start when the initial
+	// read fails.
+	onInitialErrorError = "error"
+
+	// onInitialErrorFailClosed starts the component without exports when the
+	// initial read fails, and keeps retrying the read.
+	onInitialErrorFailClosed = "fail_closed"
+
+	// onInitialErrorFailOpen starts the component exporting fail_open_data
+	// when the initial read fails, and keeps retrying the read.
+	onInitialErrorFailOpen = "fail_open"
+)
+
+const (
+	// retryMinBackoff is the initial delay before retrying a failed read.
+	retryMinBackoff = time.Second
+
+	// retryMaxBackoff caps the delay between retries of failed reads when
+	// reread_frequency is not set.
+	retryMaxBackoff = 5 * time.Minute
+)
+
+// retryConfig returns the backoff config used for retrying failed reads. The
+// delay between retries is capped at the reread frequency.
+func (a *Arguments) retryConfig() backoff.Config {
+	maxBackoff := a.RereadFrequency
+	if maxBackoff <= 0 {
+		maxBackoff = retryMaxBackoff
+	}
+
+	return backoff.Config{
+		MinBackoff: min(retryMinBackoff, maxBackoff),
+		MaxBackoff: maxBackoff,
+		MaxRetries: a.MaxRetries,
+	}
+}
+
 func (a *Arguments) authMethod() authMethod {
 	if len(a.Auth) != 1 {
 		panic(fmt.Sprintf("remote.vault: found %d auth types, expected 1", len(a.Auth)))
@@ -106,8 +394,16 @@ func (a *Arguments) authMethod() authMethod {
 }
 
 func (a *Arguments) secretStore(cli *vault.Client) secretStore {
-	// TODO(rfratto): support different stores (like a logical store).
-	return &kvStore{c: cli}
+	switch a.Engine {
+	case engineKVv1:
+		return &logicalStore{c: cli}
+	case engineTransit:
+		return &transitStore{c: cli, key: a.TransitKey, ciphertext: a.Ciphertext}
+	case engineDatabase:
+		return &databaseStore{c: cli, role: a.Role}
+	default:
+		return &kvStore{c: cli, version: a.Version}
+	}
 }
 
 // ClientOptions sets extra options on the Client.
@@ -127,6 +423,21 @@ type Exports struct {
 	// However, it seems that most secrets engines don't actually return
 	// arbitrary data, so this limitation shouldn't cause any issues in practice.
 	Data map[string]rivertypes.Secret `river:"data,attr"`
+
+	// PathsData holds the data of every secret read when the paths argument is
+	// used, keyed by the path of the secret. Data is empty in that case.
+	PathsData map[string]map[string]rivertypes.Secret `river:"paths_data,attr,optional"`
+
+	// Tree holds the data of every secret under path when recursive is set.
+	// Each folder is exported as an object keyed by the names of its
+	// subfolders and secrets, and each secret as an object of its keys. Data
+	// is empty in that case.
+	Tree map[string]any `river:"tree,attr,optional"`
+
+	// Structured holds the data of the secret when export_format is
+	// "structured". Values which are JSON objects or arrays are exported as
+	// nested objects and arrays; all other values are exported as secrets.
+	Structured map[string]any `river:"structured,attr,optional"`
 }
 
 // Component implements the remote.vault component.
@@ -140,6 +451,21 @@ type Component struct {
 
 	secretManager *tokenManager
 	authManager   *tokenManager
+
+	pathsMut    sync.Mutex
+	pathsData   map[string]map[string]rivertypes.Secret // Last data read from each of args.Paths.
+	pathsHealth component.Health                        // Health of the last read of args.Paths.
+
+	// failOpen is true while fail_open_data is exported because no read of the
+	// secret succeeded yet.
+	failOpen atomic.Bool
+
+	versionMut    sync.Mutex
+	cachedSecret  *vault.Secret // Last secret read from args.Path, if its version is known.
+	cachedVersion int           // Version of cachedSecret.
+
+	unwrapMut sync.Mutex
+	unwrapped *vault.Secret // Secret unwrapped when args.Unwrap is set.
 }
 
 var (
@@ -173,6 +499,7 @@ func (c *Component) Run(ctx context.Context) error {
 	var rg run.Group
 
 	rg.Add(func() error {
+		defer c.secretManager.ClearLeaseTTL()
 		c.secretManager.Run(ctx)
 		return nil
 	}, func(_ error) {
@@ -180,13 +507,43 @@ func (c *Component) Run(ctx context.Context) error {
 	})
 
 	rg.Add(func() error {
+		defer c.authManager.ClearLeaseTTL()
 		c.authManager.Run(ctx)
 		return nil
 	}, func(_ error) {
 		cancel()
 	})
 
-	return rg.Run()
+	err := rg.Run()
+	c.clearSecrets()
+	return err
+}
+
+// clearSecrets drops the secrets and the auth token held by the component
+// once it stops running. Exported values are shared with other components, so
+// they're left untouched.
+func (c *Component) clearSecrets() {
+	c.secretManager.Clear()
+	c.authManager.Clear()
+
+	c.resetCachedSecret()
+
+	c.unwrapMut.Lock()
+	clearSecret(c.unwrapped)
+	c.unwrapped = nil
+	c.unwrapMut.Unlock()
+
+	c.pathsMut.Lock()
+	defer c.pathsMut.Unlock()
+	c.pathsData = nil
+}
+
+// resetCachedSecret forces the next read of the secret to be a full read.
+func (c *Component) resetCachedSecret() {
+	c.versionMut.Lock()
+	defer c.versionMut.Unlock()
+	c.cachedSecret = nil
+	c.cachedVersion = 0
 }
 
 // Update updates the remote.vault component. It will try to immediately read
@@ -200,9 +557,22 @@ func (c *Component) Update(args component.Arguments) error {
 	}
 
 	c.mut.Lock()
+	oldArgs := c.args
 	c.args = newArgs
 	c.mut.Unlock()
 
+	// The new arguments may export the secret differently, so it must be read
+	// and exported again.
+	c.resetCachedSecret()
+
+	// The unwrapped secret is only kept while the same wrapping token is used.
+	if !sameWrappingToken(oldArgs, newArgs) {
+		c.unwrapMut.Lock()
+		clearSecret(c.unwrapped)
+		c.unwrapped = nil
+		c.unwrapMut.Unlock()
+	}
+
 	// Configure the token manager for authentication tokens and secrets.
 	// authManager *must* be configured first to ensure that the client is
 	// authenticated to Vault when retrieving the secret.
@@ -211,18 +581,24 @@ func (c *Component) Update(args component.Arguments) error {
 		// NOTE(rfratto): we pass 0 for the refresh interval because we don't
 		// support refreshing the auth token on an interval.
 		mgr, err := newTokenManager(tokenManagerOptions{
-			Log:    log.With(c.log, "token_type", "auth"),
-			Client: newClient,
-			Getter: c.getAuthToken,
+			Log:         log.With(c.log, "token_type", "auth"),
+			Client:      newClient,
+			Getter:      c.getAuthToken,
+			RetryConfig: newArgs.retryConfig(),
+
+			AllowInitialError: newArgs.OnInitialError != onInitialErrorError,
 
 			ReadCounter:    c.metrics.authTotal,
 			RefreshCounter: c.metrics.authLeaseRenewalTotal,
+			RenewalResults: c.metrics.tokenRenewalsTotal,
+			LeaseTTL:       c.metrics.tokenTTL,
 		})
 		if err != nil {
 			return err
 		}
 		c.authManager = mgr
 	} else {
+		c.authManager.SetRetryConfig(newArgs.retryConfig())
 		c.authManager.SetClient(newClient)
 	}
 
@@ -232,59 +608,445 @@ func (c *Component) Update(args component.Arguments) error {
 			Client:          newClient,
 			Getter:          c.getSecret,
 			RefreshInterval: newArgs.RereadFrequency,
+			RefreshJitter:   newArgs.RereadJitter,
+			RetryConfig:     newArgs.retryConfig(),
+
+			AllowInitialError: newArgs.OnInitialError != onInitialErrorError,
 
 			ReadCounter:    c.metrics.secretReadTotal,
 			RefreshCounter: c.metrics.secretLeaseRenewalTotal,
+			LeaseTTL:       c.metrics.secretLeaseTTL,
 		})
 		if err != nil {
 			return err
 		}
 		c.secretManager = mgr
+
+		if mgr.failing() {
+			c.handleInitialError(newArgs)
+		}
 	} else {
+		c.secretManager.SetRetryConfig(newArgs.retryConfig())
 		c.secretManager.SetClient(newClient)
-		c.secretManager.SetRefreshInterval(newArgs.RereadFrequency)
+		c.secretManager.SetRefreshInterval(newArgs.RereadFrequency, newArgs.RereadJitter)
 	}
 
 	return nil
 }
 
+// handleInitialError exports data according to on_initial_error after the
+// initial read of the secret failed. The read is retried once the component
+// runs, and replaces these exports when it succeeds.
+func (c *Component) handleInitialError(args Arguments) {
+	switch args.OnInitialError {
+	case onInitialErrorFailClosed:
+		level.Warn(c.log).Log("msg", "initial read of the secret failed, not exporting any data until a read succeeds")
+
+	case onInitialErrorFailOpen:
+		level.Warn(c.log).Log("msg", "initial read of the secret failed, exporting fail_open_data until a read succeeds", "keys", len(args.FailOpenData))
+
+		data := make(map[string]rivertypes.Secret, len(args.FailOpenData))
+		for key, value := range args.FailOpenData {
+			data[key] = value
+		}
+		c.opts.OnStateChange(Exports{Data: data})
+		c.failOpen.Store(true)
+	}
+}
+
 func (c *Component) getAuthToken(ctx context.Context, cli *vault.Client) (*vault.Secret, error) {
 	c.mut.RLock()
 	defer c.mut.RUnlock()
 
+	ctx, cancel := c.withRequestTimeout(ctx)
+	defer cancel()
+
 	authMethod := c.args.authMethod()
-	return authMethod.vaultAuthenticate(ctx, cli)
+	secret, err := authMethod.vaultAuthenticate(ctx, cli)
+	if isAuthFailure(err) {
+		return nil, fmt.Errorf("authentication failed: %w", err)
+	}
+	return secret, err
+}
+
+// withRequestTimeout returns a context which is canceled after the
+// request_timeout argument, if it's set. c.mut must be held when calling
+// withRequestTimeout.
+func (c *Component) withRequestTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.args.RequestTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, c.args.RequestTimeout)
+}
+
+// isAuthFailure returns true if err indicates that Vault rejected the
+// credentials used to log in. Vault responds with 400 Bad Request to logins
+// with invalid credentials for most auth methods.
+func isAuthFailure(err error) bool {
+	var respErr *vault.ResponseError
+	return errors.As(err, &respErr) && respErr.StatusCode == http.StatusBadRequest
 }
 
 func (c *Component) getSecret(ctx context.Context, cli *vault.Client) (*vault.Secret, error) {
 	c.mut.RLock()
 	defer c.mut.RUnlock()
 
-	store := c.args.secretStore(cli)
-	secret, err := store.Read(ctx, &c.args)
+	ctx, cancel := c.withRequestTimeout(ctx)
+	defer cancel()
+
+	if len(c.args.Paths) > 0 {
+		return c.getPathsSecret(ctx, cli)
+	} else if len(c.args.MergePaths) > 0 {
+		return c.getMergedSecret(ctx, cli)
+	} else if c.args.Unwrap {
+		return c.getUnwrappedSecret(ctx, cli)
+	} else if c.args.Recursive {
+		return c.getRecursiveSecret(ctx, cli)
+	}
+
+	// Secrets whose version can be looked up are only read and exported again
+	// when their version changes.
+	version, versioned := c.currentVersion(ctx, cli)
+	if versioned {
+		c.versionMut.Lock()
+		cached, cachedVersion := c.cachedSecret, c.cachedVersion
+		c.versionMut.Unlock()
+
+		if cached != nil && cachedVersion == version {
+			level.Debug(c.log).Log("msg", "secret version unchanged, skipping read", "version", version)
+			return cached, nil
+		}
+	}
+
+	secret, err := c.readSecret(ctx, cli, c.args.Path)
 	if err != nil {
 		return nil, err
 	}
 
-	// Export the secret so other components can use it.
-	c.exportSecret(secret)
+	if versioned {
+		c.versionMut.Lock()
+		c.cachedSecret, c.cachedVersion = secret, version
+		c.versionMut.Unlock()
+	}
 
+	c.exportSecret(secret)
 	return secret, nil
 }
 
-// exportSecret converts the secret into exports and exports it to the
-// controller.
+// exportSecret exports the data of secret so other components can use it.
+// c.mut must be held when calling exportSecret.
 func (c *Component) exportSecret(secret *vault.Secret) {
-	newExports := Exports{
+	exports := Exports{
+		Data: c.convertData(secret.Data),
+	}
+	if c.args.ExportFormat == exportFormatStructured {
+		exports.Structured = convertStructured(secret.Data)
+	}
+	c.opts.OnStateChange(exports)
+	c.failOpen.Store(false)
+}
+
+// sameWrappingToken returns true if the secret unwrapped with a and b is the
+// same, that is, if both unwrap the same wrapping token from the same server.
+func sameWrappingToken(a, b Arguments) bool {
+	if !a.Unwrap || !b.Unwrap {
+		return false
+	}
+	return a.Server == b.Server &&
+		a.Namespace == b.Namespace &&
+		a.Path == b.Path &&
+		a.Auth[0].AuthToken.Token == b.Auth[0].AuthToken.Token
+}
+
+// getUnwrappedSecret unwraps the response wrapped by the token used to
+// authenticate and exports its data. Wrapping tokens can only be unwrapped
+// once, so the unwrapped secret is kept and exported again by later calls.
+//
+// The returned secret has no lease, since leases can't be renewed with a
+// wrapping token. c.mut must be held when calling getUnwrappedSecret.
+func (c *Component) getUnwrappedSecret(ctx context.Context, cli *vault.Client) (*vault.Secret, error) {
+	c.unwrapMut.Lock()
+	defer c.unwrapMut.Unlock()
+
+	if c.unwrapped == nil {
+		wrapped, err := cli.Logical().UnwrapWithContext(ctx, "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to unwrap secret: %w", err)
+		} else if wrapped == nil {
+			return nil, fmt.Errorf("failed to unwrap secret: %w", vault.ErrSecretNotFound)
+		}
+		c.unwrapped = &vault.Secret{Data: wrapped.Data, Warnings: wrapped.Warnings}
+	}
+
+	// The token manager clears the data of the secrets it drops, so it gets a
+	// copy of the unwrapped secret.
+	secret := &vault.Secret{Data: maps.Clone(c.unwrapped.Data), Warnings: c.unwrapped.Warnings}
+	if err := c.args.filterKeys(secret); err != nil {
+		return nil, err
+	}
+
+	c.exportSecret(secret)
+	return secret, nil
+}
+
+// currentVersion looks up the current version of the secret at c.args.Path.
+// It returns false if the secrets engine doesn't support versions or if the
+// version can't be read, in which case the secret must be fully read. c.mut
+// must be held when calling currentVersion.
+func (c *Component) currentVersion(ctx context.Context, cli *vault.Client) (int, bool) {
+	store, ok := c.args.secretStore(cli).(versionedStore)
+	if !ok {
+		return 0, false
+	}
+
+	version, ok, err := store.CurrentVersion(ctx, c.args.Path)
+	if err != nil {
+		// Reading metadata requires its own permission, which tokens allowed to
+		// read the secret don't necessarily have.
+		level.Debug(c.log).Log("msg", "failed to read secret version, falling back to reading the secret", "err", err)
+		return 0, false
+	}
+	return version, ok
+}
+
+// getPathsSecret reads every secret in c.args.Paths. Secrets which can't be
+// read keep exporting the last data read for them, and the failure is
+// reported in the health of the component. An error is only returned if no
+// secret could be read.
+//
+// The returned secret has no lease, so the secrets are only reread on
+// reread_frequency. c.mut must be held when calling getPathsSecret.
+func (c *Component) getPathsSecret(ctx context.Context, cli *vault.Client) (*vault.Secret, error) {
+	c.pathsMut.Lock()
+	defer c.pathsMut.Unlock()
+
+	var (
+		combined  = &vault.Secret{}
+		pathsData = make(map[string]map[string]rivertypes.Secret, len(c.args.Paths))
+		failures  []string
+	)
+
+	for _, path := range c.args.Paths {
+		secret, err := c.readSecret(ctx, cli, path)
+		if err != nil {
+			level.Warn(c.log).Log("msg", "failed to read secret", "path", path, "err", err)
+			failures = append(failures, fmt.Sprintf("%s: %s", path, err))
+
+			if data, ok := c.pathsData[path]; ok {
+				pathsData[path] = data
+			}
+			continue
+		}
+
+		combined.Warnings = append(combined.Warnings, secret.Warnings...)
+		pathsData[path] = c.convertData(secret.Data)
+	}
+
+	c.pathsHealth = component.Health{
+		Health:     component.HealthTypeHealthy,
+		Message:    "read all paths",
+		UpdateTime: time.Now(),
+	}
+	if len(failures) > 0 {
+		c.pathsHealth = component.Health{
+			Health:     component.HealthTypeUnhealthy,
+			Message:    fmt.Sprintf("failed to read %d of %d paths: %s", len(failures), len(c.args.Paths), strings.Join(failures, "; ")),
+			UpdateTime: time.Now(),
+		}
+	}
+
+	if len(failures) == len(c.args.Paths) {
+		return nil, fmt.Errorf("failed to read any path: %s", strings.Join(failures, "; "))
+	}
+
+	c.pathsData = pathsData
+	c.opts.OnStateChange(Exports{
+		Data:      make(map[string]rivertypes.Secret),
+		PathsData: pathsData,
+	})
+	c.failOpen.Store(false)
+
+	return combined, nil
+}
+
+// getMergedSecret reads every secret in c.args.MergePaths and exports their
+// data merged into a single map. Keys set by more than one secret take the
+// value of the last one when merge is set, and fail the read otherwise. The
+// read fails if any of the secrets can't be read, so that partially merged
+// data is never exported.
+//
+// The returned secret has no lease, so the secrets are only reread on
+// reread_frequency. c.mut must be held when calling getMergedSecret.
+func (c *Component) getMergedSecret(ctx context.Context, cli *vault.Client) (*vault.Secret, error) {
+	var (
+		store   = c.args.secretStore(cli)
+		merged  = &vault.Secret{Data: make(map[string]interface{})}
+		sources = make(map[string]string) // Path which set each key.
+	)
+
+	for _, path := range c.args.MergePaths {
+		secret, err := store.Read(ctx, path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+
+		merged.Warnings = append(merged.Warnings, secret.Warnings...)
+		for key, value := range secret.Data {
+			if prev, ok := sources[key]; ok && !c.args.Merge {
+				return nil, fmt.Errorf("key %q is set by both %s and %s", key, prev, path)
+			}
+			merged.Data[key] = value
+			sources[key] = path
+		}
+	}
+
+	// Keys are selected after merging, so that each of them only needs to be
+	// set by one of the secrets.
+	if err := c.args.filterKeys(merged); err != nil {
+		return nil, err
+	}
+
+	c.exportSecret(merged)
+	return merged, nil
+}
+
+// getRecursiveSecret reads every secret under c.args.Path and exports their
+// data as a tree of the folders they're in. The secrets are listed again on
+// every read, so that added and removed secrets are picked up. The read fails
+// if any of the secrets can't be read, so that a partial tree is never
+// exported.
+//
+// The returned secret has no lease, so the secrets are only reread on
+// reread_frequency. c.mut must be held when calling getRecursiveSecret.
+func (c *Component) getRecursiveSecret(ctx context.Context, cli *vault.Client) (*vault.Secret, error) {
+	store := &kvStore{c: cli}
+	paths, err := store.List(ctx, c.args.Path, c.args.MaxSecrets)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets under %s: %w", c.args.Path, err)
+	}
+
+	var (
+		combined = &vault.Secret{}
+		tree     = make(map[string]any)
+	)
+	for _, path := range paths {
+		secret, err := c.readSecret(ctx, cli, strings.TrimSuffix(c.args.Path, "/")+"/"+path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		combined.Warnings = append(combined.Warnings, secret.Warnings...)
+
+		if err := addToTree(tree, path, c.convertData(secret.Data)); err != nil {
+			return nil, err
+		}
+	}
+
+	c.opts.OnStateChange(Exports{
 		Data: make(map[string]rivertypes.Secret),
+		Tree: tree,
+	})
+	c.failOpen.Store(false)
+
+	return combined, nil
+}
+
+// addToTree adds data to tree under the folders of the relative path of its
+// secret. KV v2 allows a secret and a folder to have the same name, which
+// can't both be represented in the tree.
+func addToTree(tree map[string]any, path string, data map[string]rivertypes.Secret) error {
+	var (
+		parts  = strings.Split(path, "/")
+		folder = tree
+	)
+	for i, name := range parts[:len(parts)-1] {
+		switch sub := folder[name].(type) {
+		case nil:
+			next := make(map[string]any)
+			folder[name] = next
+			folder = next
+		case map[string]any:
+			folder = sub
+		default:
+			return fmt.Errorf("secret %s has the same name as a folder", strings.Join(parts[:i+1], "/"))
+		}
+	}
+
+	name := parts[len(parts)-1]
+	if _, ok := folder[name]; ok {
+		return fmt.Errorf("secret %s has the same name as a folder", path)
+	}
+	folder[name] = data
+	return nil
+}
+
+// readSecret reads the secret at path and filters it down to the configured
+// keys. c.mut must be held when calling readSecret.
+func (c *Component) readSecret(ctx context.Context, cli *vault.Client, path string) (*vault.Secret, error) {
+	store := c.args.secretStore(cli)
+	secret, err := store.Read(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.args.filterKeys(secret); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// filterKeys checks that the required keys are set in the data of secret,
+// sets the default keys which are missing from it, and filters it down to the
+// selected keys.
+func (a *Arguments) filterKeys(secret *vault.Secret) error {
+	for _, key := range a.RequiredKeys {
+		if _, ok := secret.Data[key]; !ok {
+			return fmt.Errorf("required key %q not found in secret", key)
+		}
+	}
+
+	if len(a.DefaultKeys) > 0 && secret.Data == nil {
+		secret.Data = make(map[string]interface{}, len(a.DefaultKeys))
+	}
+	for key, value := range a.DefaultKeys {
+		if _, ok := secret.Data[key]; !ok {
+			secret.Data[key] = string(value)
+		}
+	}
+
+	return selectKeys(secret, a.Keys)
+}
+
+// selectKeys filters the data of secret down to the provided keys. An error is
+// returned if any of the keys are missing from the secret. If keys is empty,
+// secret is left unmodified.
+func selectKeys(secret *vault.Secret, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	selected := make(map[string]interface{}, len(keys))
+	for _, key := range keys {
+		value, ok := secret.Data[key]
+		if !ok {
+			return fmt.Errorf("key %q not found in secret", key)
+		}
+		selected[key] = value
 	}
 
-	for key, value := range secret.Data {
+	secret.Data = selected
+	return nil
+}
+
+// convertData converts the data of a secret into values which can be
+// exported.
+func (c *Component) convertData(data map[string]interface{}) map[string]rivertypes.Secret {
+	converted := make(map[string]rivertypes.Secret, len(data))
+
+	for key, value := range data {
 		switch value := value.(type) {
 		case string:
-			newExports.Data[key] = rivertypes.Secret(value)
+			converted[key] = rivertypes.Secret(value)
 		case []byte:
-			newExports.Data[key] = rivertypes.Secret(value)
+			converted[key] = rivertypes.Secret(value)
 
 		default:
 			// Non-string secrets are ignored.
@@ -296,28 +1058,105 @@ func (c *Component) exportSecret(secret *vault.Secret) {
 		}
 	}
 
-	c.opts.OnStateChange(newExports)
+	return converted
+}
+
+// convertStructured converts the data of a secret into nested values which
+// can be exported. String values which hold a JSON object or array are
+// decoded, and every scalar value is exported as a secret.
+func convertStructured(data map[string]interface{}) map[string]any {
+	converted := make(map[string]any, len(data))
+	for key, value := range data {
+		converted[key] = convertStructuredValue(value)
+	}
+	return converted
+}
+
+func convertStructuredValue(value interface{}) any {
+	switch value := value.(type) {
+	case nil:
+		return nil
+	case map[string]interface{}:
+		return convertStructured(value)
+	case []interface{}:
+		converted := make([]any, 0, len(value))
+		for _, elem := range value {
+			converted = append(converted, convertStructuredValue(elem))
+		}
+		return converted
+	case []byte:
+		return convertStructuredValue(string(value))
+	case string:
+		trimmed := strings.TrimSpace(value)
+		if strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+			var decoded interface{}
+			dec := json.NewDecoder(strings.NewReader(trimmed))
+			dec.UseNumber()
+			if err := dec.Decode(&decoded); err == nil && !dec.More() {
+				return convertStructuredValue(decoded)
+			}
+		}
+		return rivertypes.Secret(value)
+	default:
+		// Numbers and booleans are exported as secrets too, since their value
+		// may be sensitive.
+		return rivertypes.Secret(fmt.Sprint(value))
+	}
 }
 
 // CurrentHealth returns the current health of the remote.vault component. It
-// will be healthy as long as the latest read or renewal was successful.
+// will be healthy as long as the latest read or renewal was successful. When
+// paths is used, it will be unhealthy if any of the paths failed to be read.
 func (c *Component) CurrentHealth() component.Health {
-	return component.LeastHealthy(
+	health := component.LeastHealthy(
 		c.authManager.CurrentHealth(),
 		c.secretManager.CurrentHealth(),
 	)
+
+	c.mut.RLock()
+	usePaths := len(c.args.Paths) > 0
+	c.mut.RUnlock()
+
+	if usePaths {
+		c.pathsMut.Lock()
+		defer c.pathsMut.Unlock()
+
+		if c.pathsHealth.Health == component.HealthTypeUnhealthy {
+			health = component.LeastHealthy(health, c.pathsHealth)
+		}
+	}
+
+	if c.failOpen.Load() {
+		health.Health = component.HealthTypeUnhealthy
+		health.Message = fmt.Sprintf("exporting fail_open_data: %s", health.Message)
+	}
+	return health
 }
 
 // DebugInfo returns debug information about the remote.vault component. It
 // includes non-sensitive metadata about the current secret.
 func (c *Component) DebugInfo() interface{} {
+	c.mut.RLock()
+	var (
+		server     = c.args.Server
+		namespace  = c.args.Namespace
+		authMethod = c.args.Auth[0].name()
+	)
+	c.mut.RUnlock()
+
 	return debugInfo{
-		AuthToken: c.authManager.DebugInfo(),
-		Secret:    c.secretManager.DebugInfo(),
+		Server:     server,
+		Namespace:  namespace,
+		AuthMethod: authMethod,
+		AuthToken:  c.authManager.DebugInfo(),
+		Secret:     c.secretManager.DebugInfo(),
 	}
 }
 
 type debugInfo struct {
-	AuthToken secretInfo `river:"auth_token,block"`
-	Secret    secretInfo `river:"secret,block"`
+	Server     string     `river:"server,attr"`
+	Namespace  string     `river:"namespace,attr,optional"`
+	AuthMethod string     `river:"auth_method,attr"`
+	AuthToken  secretInfo `river:"auth_token,block"`
+	Secret     secretInfo `river:"secret,block"`
 }
@@ -0,0 +1,178 @@
+package vault
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// stubVault is a minimal fake of the Vault HTTP API. It allows testing
+// remote.vault without running a real Vault server.
+type stubVault struct {
+	srv *httptest.Server
+	mux *http.ServeMux
+}
+
+func newStubVault(t *testing.T) *stubVault {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	return &stubVault{srv: srv, mux: mux}
+}
+
+// newStubVaultTLS returns a stubVault which serves over TLS using the provided
+// config.
+func newStubVaultTLS(t *testing.T, tlsConfig *tls.Config) *stubVault {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	srv := httptest.NewUnstartedServer(mux)
+	srv.TLS = tlsConfig
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	return &stubVault{srv: srv, mux: mux}
+}
+
+// Address returns the address of the stub server.
+func (s *stubVault) Address() string { return s.srv.URL }
+
+// Handle registers a handler for the given Vault API path (e.g.,
+// auth/kubernetes/login).
+func (s *stubVault) Handle(path string, h http.HandlerFunc) {
+	s.mux.HandleFunc("/v1/"+path, h)
+}
+
+// HandleKVv2 registers handlers which serve data as the latest version of
+// the KV v2 secret at path in the given mount, along with its metadata. The
+// returned stubKVv2Secret can be used to change the secret.
+func (s *stubVault) HandleKVv2(mount, path string, data map[string]any) *stubKVv2Secret {
+	secret := &stubKVv2Secret{data: data, version: 1}
+	s.Handle(mount+"/data/"+path, secret.ServeHTTP)
+	s.Handle(mount+"/metadata/"+path, secret.serveMetadata)
+	return secret
+}
+
+func writeStubResponse(w http.ResponseWriter, resp map[string]any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// stubKVv2Secret serves the latest version of a KV v2 secret which can be
+// changed while a test is running.
+type stubKVv2Secret struct {
+	mut     sync.Mutex
+	data    map[string]any
+	version int
+	deleted bool
+	reads   int
+}
+
+// Set changes the data of the secret, creating a new version.
+func (s *stubKVv2Secret) Set(data map[string]any) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.data = data
+	s.version++
+	s.deleted = false
+}
+
+// Delete soft deletes the latest version of the secret.
+func (s *stubKVv2Secret) Delete() {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.deleted = true
+}
+
+// versionMetadata returns the metadata of the latest version of the secret.
+func (s *stubKVv2Secret) versionMetadata() map[string]any {
+	md := map[string]any{"version": s.version, "deletion_time": "", "destroyed": false}
+	if s.deleted {
+		md["deletion_time"] = "2024-01-01T00:00:00Z"
+	}
+	return md
+}
+
+// Reads returns how many times the data of the secret has been read.
+func (s *stubKVv2Secret) Reads() int {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return s.reads
+}
+
+func (s *stubKVv2Secret) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.reads++
+
+	data := s.data
+	if s.deleted {
+		// Vault responds with the metadata of deleted versions, but without
+		// their data.
+		data = nil
+		w.WriteHeader(http.StatusNotFound)
+	}
+	writeStubResponse(w, map[string]any{
+		"data": map[string]any{
+			"data":     data,
+			"metadata": s.versionMetadata(),
+		},
+	})
+}
+
+func (s *stubKVv2Secret) serveMetadata(w http.ResponseWriter, r *http.Request) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	writeStubResponse(w, map[string]any{
+		"data": map[string]any{
+			"current_version": s.version,
+			"versions": map[string]any{
+				strconv.Itoa(s.version): s.versionMetadata(),
+			},
+		},
+	})
+}
+
+// HandleKVv2List registers a handler which lists keys as the content of the
+// KV v2 folder at path in the given mount. Keys of subfolders must end with a
+// slash. The returned stubKVv2List can be used to change the listed keys.
+func (s *stubVault) HandleKVv2List(mount, path string, keys ...string) *stubKVv2List {
+	list := &stubKVv2List{keys: keys}
+	s.Handle(mount+"/metadata/"+path, list.ServeHTTP)
+	return list
+}
+
+// stubKVv2List serves the keys of a KV v2 folder which can be changed while a
+// test is running.
+type stubKVv2List struct {
+	mut  sync.Mutex
+	keys []string
+}
+
+// Set changes the keys of the folder.
+func (s *stubKVv2List) Set(keys ...string) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.keys = keys
+}
+
+func (s *stubKVv2List) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if len(s.keys) == 0 {
+		http.Error(w, `{"errors":[]}`, http.StatusNotFound)
+		return
+	}
+	writeStubResponse(w, map[string]any{
+		"data": map[string]any{"keys": s.keys},
+	})
+}
@@ -3,6 +3,8 @@
 package vault
 
 import (
+	"context"
+	"encoding/base64"
 	"fmt"
 	stdlog "log"
 	"testing"
@@ -128,20 +130,178 @@ func Test_PollSecrets(t *testing.T) {
 		})
 		require.NoError(t, err)
 
-		require.NoError(t, ctrl.WaitExports(time.Minute))
+		waitCtx, cancel := context.WithTimeout(ctx, time.Minute)
+		defer cancel()
 
-		var (
-			expectExports = Exports{
-				Data: map[string]rivertypes.Secret{
-					"key": rivertypes.Secret("newvalue"),
-				},
-			}
-			actualExports = ctrl.Exports().(Exports)
-		)
-		require.Equal(t, expectExports, actualExports)
+		require.NoError(t, ctrl.WaitExportsMatch(waitCtx, func(e any) bool {
+			return e.(Exports).Data["key"] == rivertypes.Secret("newvalue")
+		}))
 	}
 }
 
+func Test_GetSecrets_KVv1(t *testing.T) {
+	var (
+		ctx = componenttest.TestContext(t)
+		l   = util.TestLogger(t)
+	)
+
+	cli := getTestVaultServer(t)
+
+	// Mount a KV v1 engine and store a secret in it to use from the component.
+	require.NoError(t, cli.Sys().MountWithContext(ctx, "kv", &vaultapi.MountInput{
+		Type:    "kv",
+		Options: map[string]string{"version": "1"},
+	}))
+	require.NoError(t, cli.KVv1("kv").Put(ctx, "test", map[string]any{
+		"key": "value",
+	}))
+
+	cfg := fmt.Sprintf(`
+		server = "%s"
+		path   = "kv/test"
+		engine = "kv_v1"
+
+		reread_frequency = "0s"
+
+		auth.token {
+			token = "%s"
+		}
+	`, cli.Address(), cli.Token())
+
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(cfg), &args))
+
+	ctrl, err := componenttest.NewControllerFromID(l, "remote.vault")
+	require.NoError(t, err)
+
+	go func() {
+		require.NoError(t, ctrl.Run(ctx, args))
+	}()
+
+	require.NoError(t, ctrl.WaitRunning(time.Minute))
+	require.NoError(t, ctrl.WaitExports(time.Minute))
+
+	var (
+		expectExports = Exports{
+			Data: map[string]rivertypes.Secret{
+				"key": rivertypes.Secret("value"),
+			},
+		}
+		actualExports = ctrl.Exports().(Exports)
+	)
+	require.Equal(t, expectExports, actualExports)
+}
+
+func Test_GetSecrets_PinnedVersion(t *testing.T) {
+	var (
+		ctx = componenttest.TestContext(t)
+		l   = util.TestLogger(t)
+	)
+
+	cli := getTestVaultServer(t)
+
+	// Write two versions of the secret and pin the first one.
+	for _, value := range []string{"v1", "v2"} {
+		_, err := cli.KVv2("secret").Put(ctx, "test", map[string]any{
+			"key": value,
+		})
+		require.NoError(t, err)
+	}
+
+	cfg := fmt.Sprintf(`
+		server  = "%s"
+		path    = "secret/test"
+		version = 1
+
+		reread_frequency = "0s"
+
+		auth.token {
+			token = "%s"
+		}
+	`, cli.Address(), cli.Token())
+
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(cfg), &args))
+
+	ctrl, err := componenttest.NewControllerFromID(l, "remote.vault")
+	require.NoError(t, err)
+
+	go func() {
+		require.NoError(t, ctrl.Run(ctx, args))
+	}()
+
+	require.NoError(t, ctrl.WaitRunning(time.Minute))
+	require.NoError(t, ctrl.WaitExports(time.Minute))
+
+	var (
+		expectExports = Exports{
+			Data: map[string]rivertypes.Secret{
+				"key": rivertypes.Secret("v1"),
+			},
+		}
+		actualExports = ctrl.Exports().(Exports)
+	)
+	require.Equal(t, expectExports, actualExports)
+}
+
+func Test_GetSecrets_Transit(t *testing.T) {
+	var (
+		ctx = componenttest.TestContext(t)
+		l   = util.TestLogger(t)
+	)
+
+	cli := getTestVaultServer(t)
+
+	// Mount a transit engine and encrypt a value with it to decrypt from the
+	// component.
+	require.NoError(t, cli.Sys().MountWithContext(ctx, "transit", &vaultapi.MountInput{
+		Type: "transit",
+	}))
+	_, err := cli.Logical().WriteWithContext(ctx, "transit/keys/test", nil)
+	require.NoError(t, err)
+	encrypted, err := cli.Logical().WriteWithContext(ctx, "transit/encrypt/test", map[string]any{
+		"plaintext": base64.StdEncoding.EncodeToString([]byte("value")),
+	})
+	require.NoError(t, err)
+
+	cfg := fmt.Sprintf(`
+		server     = "%s"
+		path       = "transit"
+		engine     = "transit"
+		key        = "test"
+		ciphertext = "%s"
+
+		reread_frequency = "0s"
+
+		auth.token {
+			token = "%s"
+		}
+	`, cli.Address(), encrypted.Data["ciphertext"], cli.Token())
+
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(cfg), &args))
+
+	ctrl, err := componenttest.NewControllerFromID(l, "remote.vault")
+	require.NoError(t, err)
+
+	go func() {
+		require.NoError(t, ctrl.Run(ctx, args))
+	}()
+
+	require.NoError(t, ctrl.WaitRunning(time.Minute))
+	require.NoError(t, ctrl.WaitExports(time.Minute))
+
+	var (
+		expectExports = Exports{
+			Data: map[string]rivertypes.Secret{
+				"plaintext": rivertypes.Secret("value"),
+			},
+		}
+		actualExports = ctrl.Exports().(Exports)
+	)
+	require.Equal(t, expectExports, actualExports)
+}
+
 func getTestVaultServer(t *testing.T) *vaultapi.Client {
 	// TODO: this is broken with go 1.20.6
 	// waiting on https://github.com/testcontainers/testcontainers-go/issues/1359
@@ -33,11 +33,13 @@ func (s *ScrapeConfigBuilder) AppendPushAPI() {
 }
 
 func toLokiApiArguments(config *scrapeconfig.PushTargetConfig, forwardTo []loki.LogsReceiver) api.Arguments {
-	return api.Arguments{
-		ForwardTo:            forwardTo,
-		RelabelRules:         make(relabel.Rules, 0),
-		Labels:               convertPromLabels(config.Labels),
-		UseIncomingTimestamp: config.KeepTimestamp,
-		Server:               common.WeaveWorksServerToFlowServer(config.Server),
-	}
+	var args api.Arguments
+	args.SetToDefault()
+
+	args.ForwardTo = forwardTo
+	args.RelabelRules = make(relabel.Rules, 0)
+	args.Labels = convertPromLabels(config.Labels)
+	args.UseIncomingTimestamp = config.KeepTimestamp
+	args.Server = common.WeaveWorksServerToFlowServer(config.Server)
+	return args
 }
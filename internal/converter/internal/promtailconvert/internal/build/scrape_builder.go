@@ -78,6 +78,9 @@ func (s *ScrapeConfigBuilder) AppendLokiSourceFile(watchConfig *file.WatchConfig
 		DecompressionConfig: convertDecompressionConfig(s.cfg.DecompressionCfg),
 		FileWatch:           convertFileWatchConfig(watchConfig),
 		LegacyPositionsFile: positionsCfg.PositionsFile,
+		// positions_cleanup_grace_period doesn't exist in static mode or
+		// promtail, so it's set to the default to not emit it.
+		PositionsCleanupGracePeriod: lokisourcefile.DefaultArguments.PositionsCleanupGracePeriod,
 	}
 	overrideHook := func(val interface{}) interface{} {
 		if _, ok := val.([]discovery.Target); ok {
@@ -253,6 +256,7 @@ func convertFileWatchConfig(watchConfig *file.WatchConfig) lokisourcefile.FileWa
 	return lokisourcefile.FileWatch{
 		MinPollFrequency: watchConfig.MinPollFrequency,
 		MaxPollFrequency: watchConfig.MaxPollFrequency,
+		Detector:         lokisourcefile.DefaultArguments.FileWatch.Detector,
 	}
 }
 
@@ -104,6 +104,23 @@ func (c *Controller) WaitExports(timeout time.Duration) error {
 	}
 }
 
+// WaitExportsMatch blocks until the most recent Exports satisfy predicate or
+// ctx is canceled. predicate is called with a snapshot of the Exports each
+// time they change, without holding any locks of the Controller.
+func (c *Controller) WaitExportsMatch(ctx context.Context, predicate func(any) bool) error {
+	for {
+		if exports := c.Exports(); exports != nil && predicate(exports) {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("exports never matched: %w", ctx.Err())
+		case <-c.exportsCh:
+		}
+	}
+}
+
 // Exports gets the most recent exports for a component.
 func (c *Controller) Exports() component.Exports {
 	c.exportsMut.Lock()
@@ -0,0 +1,65 @@
+package componenttest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/internal/component"
+	"github.com/grafana/agent/internal/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestController_WaitExportsMatch(t *testing.T) {
+	ctrl := NewControllerFromReg(util.TestLogger(t), component.Registration{
+		Name: "testcomponents.exports",
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return &exportsComponent{opts: opts}, nil
+		},
+	})
+
+	go func() {
+		require.NoError(t, ctrl.Run(TestContext(t), 0))
+	}()
+	require.NoError(t, ctrl.WaitRunning(time.Minute))
+
+	t.Run("matches", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		go func() {
+			for i := 1; i <= 3; i++ {
+				require.NoError(t, ctrl.Update(i))
+			}
+		}()
+
+		require.NoError(t, ctrl.WaitExportsMatch(ctx, func(e any) bool {
+			return e.(int) == 3
+		}))
+	})
+
+	t.Run("deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		err := ctrl.WaitExportsMatch(ctx, func(e any) bool {
+			return e.(int) == 4
+		})
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
+
+// exportsComponent exports the arguments it's updated with.
+type exportsComponent struct {
+	opts component.Options
+}
+
+func (c *exportsComponent) Run(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (c *exportsComponent) Update(args component.Arguments) error {
+	c.opts.OnStateChange(args)
+	return nil
+}
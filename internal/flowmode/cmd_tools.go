@@ -3,6 +3,7 @@ package flowmode
 import (
 	"fmt"
 
+	"github.com/grafana/agent/internal/component/loki/source/file"
 	"github.com/grafana/agent/internal/component/prometheus/remotewrite"
 	"github.com/spf13/cobra"
 )
@@ -15,6 +16,7 @@ func toolsCommand() *cobra.Command {
 	}
 
 	cmd.AddCommand(
+		getTools("loki.source.file", file.InstallTools),
 		getTools("prometheus.remote_write", remotewrite.InstallTools),
 	)
 
@@ -39,6 +39,7 @@ func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
 //  3. No InstanceConfig may have an empty name.
 //  4. If InstanceConfig positions path is empty, shared PositionsDirectory
 //     must not be empty.
+//  5. No two scrape configs of an InstanceConfig may have the same job name.
 //
 // Defaults:
 //
@@ -72,6 +73,16 @@ func (c *Config) ApplyDefaults() error {
 		}
 		positions[ic.PositionsConfig.PositionsFile] = ic.Name
 
+		// Targets are grouped by job name, so scrape configs sharing a job name
+		// would silently have their targets merged.
+		jobs := map[string]int{} // job name -> index of scrape config using it
+		for scIdx, sc := range ic.ScrapeConfig {
+			if orig, ok := jobs[sc.JobName]; ok {
+				return fmt.Errorf("Loki config %s has scrape configs at index %d and %d with the same job name %q", ic.Name, orig, scIdx, sc.JobName)
+			}
+			jobs[sc.JobName] = scIdx
+		}
+
 		if len(ic.ClientConfigs) == 0 {
 			ic.ClientConfigs = c.Global.ClientConfigs
 		}
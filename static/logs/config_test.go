@@ -90,6 +90,34 @@ func TestConfig_ApplyDefaults_Validations(t *testing.T) {
 				- name: config-b
 		  `),
 		},
+		{
+			name: "scrape configs with same job name",
+			err:  fmt.Errorf(`Loki config config-a has scrape configs at index 0 and 2 with the same job name "varlogs"`),
+			cfg: untab(`
+				positions_directory: /tmp
+				configs:
+				- name: config-a
+				  scrape_configs:
+				  - job_name: varlogs
+				  - job_name: syslog
+				  - job_name: varlogs
+				- name: config-b
+		  `),
+		},
+		{
+			name: "same job name in different configs",
+			err:  nil,
+			cfg: untab(`
+				positions_directory: /tmp
+				configs:
+				- name: config-a
+				  scrape_configs:
+				  - job_name: varlogs
+				- name: config-b
+				  scrape_configs:
+				  - job_name: varlogs
+		  `),
+		},
 		{
 			name: "global filewatcher",
 			err:  nil,